@@ -0,0 +1,48 @@
+// regenerate-images rebuilds the thumbnail, card and zoom sizes of every stored product
+// and category image from the uploaded originals.
+//
+//	go run ./cmd/regenerate-images
+package main
+
+import (
+	"context"
+	"log"
+
+	"go-ecommerce-app/config"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"go-ecommerce-app/pkg/storage"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+
+	cfg, err := config.SetupEnv()
+	if err != nil {
+		log.Fatalf("config file is not loaded properly %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.Dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("database connection failed: %v", err)
+	}
+
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("storage setup failed: %v", err)
+	}
+
+	svc := service.CatalogService{
+		CatalogRepo: repository.NewCatalogRepository(db),
+		Config:      cfg,
+		Storage:     store,
+	}
+
+	n, err := svc.RegenerateImageSizes(context.Background())
+	if err != nil {
+		log.Fatalf("regenerating images stopped after %d images: %v", n, err)
+	}
+	log.Printf("regenerated sizes of %d images", n)
+}
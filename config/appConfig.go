@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"github.com/joho/godotenv"
 	"os"
+	"strings"
+	"time"
 )
 
 type AppConfig struct {
+	AppEnv                string // dev enables development only features, e.g. the capture mailer
 	ServerPort            string
 	Dsn                   string
 	AppSecret             string
@@ -14,12 +17,44 @@ type AppConfig struct {
 	TwilioAuthToken       string
 	TwilioFromPhoneNumber string
 	StripeSecret          string
+	StripeWebhookSecret   string
 	PubKey                string
+	PaymentProvider       string // stripe (default) or fake
+	FakePaymentOutcome    string // status the fake provider resolves intents to
+	StockReservationTTL   time.Duration
+	StorageProvider       string // local (default) or s3
+	StorageLocalDir       string
+	StoragePublicUrl      string // base url local files are served from
+	S3Endpoint            string
+	S3Region              string
+	S3Bucket              string
+	S3AccessKey           string
+	S3SecretKey           string
+	S3PublicUrl           string
+	CancelBuyerWindow     time.Duration // how long after checkout buyers may cancel, 0 for no limit
+	CancelSellerWindow    time.Duration // how long after checkout sellers may reject, 0 for no limit
+	ReturnWindow          time.Duration // how long after delivery buyers may ask for a return, 0 for no limit
+	AccessTokenTTL        time.Duration
+	RefreshTokenTTL       time.Duration // how long a session may stay idle before logging in again
+	EmailProvider         string        // smtp, or capture in dev
+	EmailFrom             string
+	EmailCaptureDir       string // capture only, where emails are written as .eml files
+	SMTPHost              string
+	SMTPPort              string
+	SMTPUsername          string
+	SMTPPassword          string
+	AdminEmails           []string // users promoted to admin at startup once their email is verified
+}
+
+// IsDev reports whether the app runs in development, APP_ENV=dev
+func (c AppConfig) IsDev() bool {
+	return c.AppEnv == "dev"
 }
 
 func SetupEnv() (cfg AppConfig, err error) {
 
-	if os.Getenv("APP_ENV") == "dev" {
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "dev" {
 		godotenv.Load()
 	}
 
@@ -27,12 +62,95 @@ func SetupEnv() (cfg AppConfig, err error) {
 	Dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v", os.Getenv("DB_HOST"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), os.Getenv("DB_PORT"))
 	appSecret := os.Getenv("APP_SECRET")
 
-	return AppConfig{ServerPort: httpPort, Dsn: Dsn, AppSecret: appSecret,
+	// how long checkout holds stock for an unpaid payment, e.g. "30m"
+	reservationTTL, err := time.ParseDuration(os.Getenv("STOCK_RESERVATION_TTL"))
+	if err != nil || reservationTTL <= 0 {
+		reservationTTL = 30 * time.Minute
+	}
+
+	// cancellation rules, e.g. "2h"; "0" lifts the limit
+	buyerCancelWindow, err := time.ParseDuration(os.Getenv("CANCEL_BUYER_WINDOW"))
+	if err != nil || buyerCancelWindow < 0 {
+		buyerCancelWindow = 24 * time.Hour
+	}
+	sellerCancelWindow, err := time.ParseDuration(os.Getenv("CANCEL_SELLER_WINDOW"))
+	if err != nil || sellerCancelWindow < 0 {
+		sellerCancelWindow = 0
+	}
+
+	returnWindow, err := time.ParseDuration(os.Getenv("RETURN_WINDOW"))
+	if err != nil || returnWindow < 0 {
+		returnWindow = 14 * 24 * time.Hour
+	}
+
+	// token lifetimes, e.g. "15m" and "720h"
+	accessTTL, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL"))
+	if err != nil || accessTTL <= 0 {
+		accessTTL = 15 * time.Minute
+	}
+	refreshTTL, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL"))
+	if err != nil || refreshTTL <= 0 {
+		refreshTTL = 30 * 24 * time.Hour
+	}
+
+	storageDir := os.Getenv("STORAGE_LOCAL_DIR")
+	if storageDir == "" {
+		storageDir = "./uploads"
+	}
+	storageUrl := os.Getenv("STORAGE_PUBLIC_URL")
+	if storageUrl == "" {
+		storageUrl = "/uploads"
+	}
+
+	smtpPort := os.Getenv("SMTP_PORT")
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+	captureDir := os.Getenv("EMAIL_CAPTURE_DIR")
+	if captureDir == "" {
+		captureDir = "./mail"
+	}
+
+	// comma separated, e.g. "owner@example.com,ops@example.com"
+	var adminEmails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			adminEmails = append(adminEmails, email)
+		}
+	}
+
+	return AppConfig{AppEnv: appEnv, ServerPort: httpPort, Dsn: Dsn, AppSecret: appSecret,
 		TwilioAccountSid:      os.Getenv("TWILIO_ACCOUNT_SID"),
 		TwilioAuthToken:       os.Getenv("TWILIO_AUTH_TOKEN"),
 		TwilioFromPhoneNumber: os.Getenv("TWILIO_FROM_PHONE_NUMBER"),
 		StripeSecret:          os.Getenv("STRIPE_SECRET"),
+		StripeWebhookSecret:   os.Getenv("STRIPE_WEBHOOK_SECRET"),
 		PubKey:                os.Getenv("STRIPE_PUB_KEY"),
+		PaymentProvider:       os.Getenv("PAYMENT_PROVIDER"),
+		FakePaymentOutcome:    os.Getenv("PAYMENT_FAKE_OUTCOME"),
+		StockReservationTTL:   reservationTTL,
+		StorageProvider:       os.Getenv("STORAGE_PROVIDER"),
+		StorageLocalDir:       storageDir,
+		StoragePublicUrl:      storageUrl,
+		S3Endpoint:            os.Getenv("S3_ENDPOINT"),
+		S3Region:              os.Getenv("S3_REGION"),
+		S3Bucket:              os.Getenv("S3_BUCKET"),
+		S3AccessKey:           os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:           os.Getenv("S3_SECRET_KEY"),
+		S3PublicUrl:           os.Getenv("S3_PUBLIC_URL"),
+		CancelBuyerWindow:     buyerCancelWindow,
+		CancelSellerWindow:    sellerCancelWindow,
+		ReturnWindow:          returnWindow,
+		AccessTokenTTL:        accessTTL,
+		RefreshTokenTTL:       refreshTTL,
+		EmailProvider:         os.Getenv("EMAIL_PROVIDER"),
+		EmailFrom:             os.Getenv("EMAIL_FROM"),
+		EmailCaptureDir:       captureDir,
+		SMTPHost:              os.Getenv("SMTP_HOST"),
+		SMTPPort:              smtpPort,
+		SMTPUsername:          os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:          os.Getenv("SMTP_PASSWORD"),
+		AdminEmails:           adminEmails,
 	}, nil
 }
 
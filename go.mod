@@ -3,12 +3,14 @@ module go-ecommerce-app
 go 1.24.0
 
 require (
+	github.com/HugoSmits86/nativewebp v1.2.0
 	github.com/gofiber/fiber/v2 v2.52.10
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/joho/godotenv v1.5.1
 	github.com/stripe/stripe-go/v78 v78.12.0
 	github.com/twilio/twilio-go v1.28.8
 	golang.org/x/crypto v0.45.0
+	golang.org/x/image v0.33.0
 	gorm.io/driver/postgres v1.6.0
 	gorm.io/gorm v1.31.1
 )
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CancellationHandler struct {
+	Svc *service.CancellationService
+}
+
+func SetupCancellationRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	orderSvc := service.NewOrderService(repository.NewOrderRepository(rh.DB), rh.Auth)
+	handler := CancellationHandler{
+		Svc: service.NewCancellationService(
+			repository.NewCancellationRepository(rh.DB),
+			orderSvc,
+			service.NewRefundService(
+				repository.NewRefundRepository(rh.DB),
+				repository.NewTransactionRepository(rh.DB),
+				orderSvc,
+				rh.Pc,
+				rh.Auth,
+			),
+			service.NewInventoryService(repository.NewInventoryRepository(rh.DB), rh.Config),
+			repository.NewUserRepository(rh.DB),
+			newNotificationService(rh),
+			rh.Config,
+			rh.Auth,
+		),
+	}
+
+	// :id is the order id on both sides
+	buyerRoutes := app.Group("/buyer", rh.Auth.Authorize)
+	buyerRoutes.Post("/orders/:id/cancel", handler.BuyerCancel)
+	buyerRoutes.Post("/orders/:id/sub-orders/:subOrderId/cancel", handler.BuyerCancel)
+	buyerRoutes.Get("/orders/:id/cancellations", handler.BuyerGetCancellations)
+
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	fulfil := rh.Auth.Require(domain.PermOrdersFulfil)
+	// sellers reject their own sub-order of the order
+	sellerRoutes.Post("/orders/:id/cancel", fulfil, handler.SellerCancel)
+	sellerRoutes.Get("/orders/:id/cancellations", fulfil, handler.SellerGetCancellations)
+}
+
+func (h *CancellationHandler) BuyerCancel(ctx *fiber.Ctx) error {
+	return h.cancel(ctx, domain.OrderActorBuyer)
+}
+
+func (h *CancellationHandler) SellerCancel(ctx *fiber.Ctx) error {
+	return h.cancel(ctx, domain.OrderActorSeller)
+}
+
+func (h *CancellationHandler) BuyerGetCancellations(ctx *fiber.Ctx) error {
+	return h.getCancellations(ctx, domain.OrderActorBuyer)
+}
+
+func (h *CancellationHandler) SellerGetCancellations(ctx *fiber.Ctx) error {
+	return h.getCancellations(ctx, domain.OrderActorSeller)
+}
+
+func (h *CancellationHandler) cancel(ctx *fiber.Ctx, actor domain.OrderActor) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+	subOrderId := 0
+	if ctx.Params("subOrderId") != "" {
+		subOrderId, err = strconv.Atoi(ctx.Params("subOrderId"))
+		if err != nil || subOrderId < 1 {
+			return rest.BadRequestError(ctx, "sub-order id is not valid")
+		}
+	}
+
+	req := dto.CancelOrderRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Reason == "" {
+		return rest.BadRequestError(ctx, "cancel order request is not valid")
+	}
+
+	cancellations, err := h.Svc.Cancel(user, actor, uint(id), uint(subOrderId), req)
+	if err != nil {
+		// the order may already be cancelled when the refund is what failed
+		if len(cancellations) > 0 {
+			return rest.InternalError(ctx, err)
+		}
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	}
+	return rest.SuccessResponse(ctx, "order cancelled", cancellations)
+}
+
+func (h *CancellationHandler) getCancellations(ctx *fiber.Ctx, actor domain.OrderActor) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	cancellations, err := h.Svc.GetCancellations(user, actor, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "order cancellations", cancellations)
+}
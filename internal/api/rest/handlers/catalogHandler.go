@@ -7,6 +7,7 @@ import (
 	"go-ecommerce-app/internal/repository"
 	"go-ecommerce-app/internal/service"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -24,6 +25,7 @@ func SetupCatalogRoutes(rh *rest.RestHandler) {
 		CatalogRepo: repository.NewCatalogRepository(rh.DB),
 		Auth:        rh.Auth,
 		Config:      rh.Config,
+		Storage:     rh.Store,
 	}
 	handler := CatalogHandler{
 		svc: svc,
@@ -38,25 +40,54 @@ func SetupCatalogRoutes(rh *rest.RestHandler) {
 	app.Get("/catagories/:id", handler.GetCategoriesById)
 
 	// private
-	// manage product and categories
-	selRoutes := app.Group("/seller", rh.Auth.AuthorizeSeller)
-	// Categories
-	selRoutes.Post("/categories", handler.CreateCategories)
-	selRoutes.Patch("/categories/:id", handler.EditCategory)
-	selRoutes.Delete("/categories/:id", handler.DeleteCategory)
+	// categories are global and managed by admins only
+	adminRoutes := app.Group("/admin", rh.Auth.Authorize)
+	categories := rh.Auth.Require(domain.PermCategoriesManage)
+	adminRoutes.Post("/categories", categories, handler.CreateCategories)
+	adminRoutes.Patch("/categories/:id", categories, handler.EditCategory)
+	adminRoutes.Post("/categories/:id/image", categories, handler.UploadCategoryImage)
+	adminRoutes.Delete("/categories/:id", categories, handler.DeleteCategory)
+
+	// sellers manage their own products
+	selRoutes := app.Group("/seller", rh.Auth.Authorize)
+	products := rh.Auth.Require(domain.PermProductsManage)
 
 	// Products
-	selRoutes.Post("/products", handler.CreateProducts)
-	selRoutes.Get("/products", handler.GetProducts)
-	selRoutes.Get("/products/:id", handler.GetProduct)
-	selRoutes.Put("/products/:id", handler.EditProduct)
-	selRoutes.Patch("/products/:id", handler.UpdateStock) // update stock
-	selRoutes.Delete("/products/:id", handler.DeleteCategory)
+	selRoutes.Post("/products", products, handler.CreateProducts)
+	selRoutes.Get("/products", products, handler.GetSellerProducts)
+	selRoutes.Get("/products/:id", products, handler.GetProduct)
+	selRoutes.Put("/products/:id", products, handler.EditProduct)
+	selRoutes.Patch("/products/:id", products, handler.UpdateStock) // update stock
+	selRoutes.Get("/products/:id/stock-movements", products, handler.GetStockMovements)
+	selRoutes.Post("/products/:id/stock/rebuild", products, handler.RebuildStock)
+	selRoutes.Delete("/products/:id", products, handler.DeleteProduct)
+
+	// Variants
+	selRoutes.Put("/products/:id/options", products, handler.SetProductOptions)
+	selRoutes.Post("/products/:id/variants", products, handler.CreateVariant)
+	selRoutes.Put("/products/:id/variants/:variantId", products, handler.EditVariant)
+	selRoutes.Patch("/products/:id/variants/:variantId", products, handler.UpdateVariantStock) // update stock
+	selRoutes.Delete("/products/:id/variants/:variantId", products, handler.DeleteVariant)
+
+	// Images
+	selRoutes.Post("/products/:id/images", products, handler.UploadProductImages)
+	selRoutes.Put("/products/:id/images", products, handler.ReorderProductImages)
+	selRoutes.Patch("/products/:id/images/:imageId/primary", products, handler.SetPrimaryImage)
+	selRoutes.Delete("/products/:id/images/:imageId", products, handler.DeleteProductImage)
 
 }
 
+// GetCategories returns a flat list, or the nested category tree with ?tree=true
 func (h CatalogHandler) GetCategories(ctx *fiber.Ctx) error {
 
+	if ctx.QueryBool("tree") {
+		tree, err := h.svc.GetCategoryTree()
+		if err != nil {
+			return rest.ErrorMessage(ctx, 404, err)
+		}
+		return rest.SuccessResponse(ctx, "categories", tree)
+	}
+
 	cats, err := h.svc.GetCategories()
 	if err != nil {
 		return rest.ErrorMessage(ctx, 404, err)
@@ -112,9 +143,26 @@ func (h CatalogHandler) EditCategory(ctx *fiber.Ctx) error {
 	return rest.SuccessResponse(ctx, "edit category", updateCat)
 
 }
+
+// UploadCategoryImage takes the file in the "image" form field
+func (h CatalogHandler) UploadCategoryImage(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	file, err := ctx.FormFile("image")
+	if err != nil {
+		return rest.BadRequestError(ctx, "please upload the image as multipart form data")
+	}
+
+	cat, err := h.svc.UploadCategoryImage(id, file)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Category image uploaded successfully", cat)
+}
+
+// DeleteCategory accepts ?policy=refuse (default), reparent or cascade
 func (h CatalogHandler) DeleteCategory(ctx *fiber.Ctx) error {
 	id, _ := strconv.Atoi(ctx.Params("id"))
-	err := h.svc.DeleteCategory(id)
+	err := h.svc.DeleteCategory(id, dto.CategoryDeletePolicy(ctx.Query("policy")))
 	if err != nil {
 		return rest.InternalError(ctx, err)
 	}
@@ -152,14 +200,43 @@ func (h CatalogHandler) EditProduct(ctx *fiber.Ctx) error {
 	return rest.SuccessResponse(ctx, "Product created successfully", product)
 
 }
+
+// GetProducts lists products, e.g. /products?q=shirt&category_id=3&min_price=10&in_stock=true&sort=price_asc&page=2
 func (h CatalogHandler) GetProducts(ctx *fiber.Ctx) error {
-	products, err := h.svc.GetProducts()
+	products, err := h.svc.GetProducts(productFilter(ctx))
 	if err != nil {
-		return rest.ErrorMessage(ctx, 404, err)
+		return rest.BadRequestError(ctx, err.Error())
 	}
 
 	return rest.SuccessResponse(ctx, "products", products)
 }
+
+func (h CatalogHandler) GetSellerProducts(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	products, err := h.svc.GetSellerProducts(int(user.ID), productFilter(ctx))
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "products", products)
+}
+
+func productFilter(ctx *fiber.Ctx) dto.ProductFilter {
+	filter := dto.ProductFilter{
+		Query:      strings.TrimSpace(ctx.Query("q")),
+		CategoryId: uint(max(ctx.QueryInt("category_id"), 0)),
+		MinPrice:   ctx.QueryFloat("min_price"),
+		MaxPrice:   ctx.QueryFloat("max_price"),
+		SellerId:   uint(max(ctx.QueryInt("seller_id"), 0)),
+		InStock:    ctx.QueryBool("in_stock"),
+		Sort:       ctx.Query("sort"),
+	}
+	filter.Page = ctx.QueryInt("page", 1)
+	filter.Limit = ctx.QueryInt("limit", dto.DefaultPageLimit)
+	return filter
+}
+
 func (h CatalogHandler) GetProduct(ctx *fiber.Ctx) error {
 	id, _ := strconv.Atoi(ctx.Params("id"))
 	product, err := h.svc.GetProductsById(id)
@@ -183,16 +260,177 @@ func (h CatalogHandler) UpdateStock(ctx *fiber.Ctx) error {
 		Stock:  int(req.Stock),
 		UserId: int(user.ID),
 	}
-	updatedProduct, err := h.svc.UpdatedProductStock(product)
-	return rest.SuccessResponse(ctx, "Product created successfully", updatedProduct)
+	updatedProduct, err := h.svc.UpdatedProductStock(product, req.Reason)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Product stock updated successfully", updatedProduct)
 
 }
 
+func (h CatalogHandler) GetStockMovements(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	page := dto.PaginationRequest{
+		Page:  ctx.QueryInt("page", 1),
+		Limit: ctx.QueryInt("limit", dto.DefaultPageLimit),
+	}
+
+	movements, err := h.svc.GetStockMovements(id, user, page)
+	if err != nil {
+		return rest.ErrorMessage(ctx, 404, err)
+	}
+	return rest.SuccessResponse(ctx, "stock movements", movements)
+}
+
+func (h CatalogHandler) RebuildStock(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	product, err := h.svc.RebuildProductStock(id, user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Product stock rebuilt from ledger", product)
+}
+
 func (h CatalogHandler) DeleteProduct(ctx *fiber.Ctx) error {
 	id, _ := strconv.Atoi(ctx.Params("id"))
 	//need to provide user id to verify
 	user := h.svc.Auth.GetCurrentUser(ctx)
 
 	err := h.svc.DeleteProduct(id, user)
-	return rest.SuccessResponse(ctx, "Delete product", err)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Delete product", nil)
+}
+
+func (h CatalogHandler) SetProductOptions(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ProductOptionsRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "product options request is not valid")
+	}
+
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.SetProductOptions(id, user, req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Product options updated successfully", product)
+}
+
+func (h CatalogHandler) CreateVariant(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ProductVariantRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "create variant request is not valid")
+	}
+
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.CreateVariant(id, user, req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Variant created successfully", product)
+}
+
+func (h CatalogHandler) EditVariant(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	variantId, _ := strconv.Atoi(ctx.Params("variantId"))
+	req := dto.ProductVariantRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "edit variant request is not valid")
+	}
+
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.EditVariant(id, uint(variantId), user, req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Variant updated successfully", product)
+}
+
+func (h CatalogHandler) UpdateVariantStock(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	variantId, _ := strconv.Atoi(ctx.Params("variantId"))
+	req := dto.UpdateStockRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "update stock request is not valid")
+	}
+
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.UpdateVariantStock(id, uint(variantId), user, req)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Variant stock updated successfully", product)
+}
+
+func (h CatalogHandler) DeleteVariant(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	variantId, _ := strconv.Atoi(ctx.Params("variantId"))
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	if err := h.svc.DeleteVariant(id, uint(variantId), user); err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Delete variant", nil)
+}
+
+// UploadProductImages takes one or more files in the "images" form field
+func (h CatalogHandler) UploadProductImages(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		return rest.BadRequestError(ctx, "please upload images as multipart form data")
+	}
+
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.UploadProductImages(id, user, form.File["images"])
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Images uploaded successfully", product)
+}
+
+func (h CatalogHandler) ReorderProductImages(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ReorderImagesRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "reorder images request is not valid")
+	}
+
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.ReorderProductImages(id, req.ImageIds, user)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Images reordered successfully", product)
+}
+
+func (h CatalogHandler) SetPrimaryImage(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	imageId, _ := strconv.Atoi(ctx.Params("imageId"))
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	product, err := h.svc.SetPrimaryImage(id, uint(imageId), user)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Primary image updated successfully", product)
+}
+
+func (h CatalogHandler) DeleteProductImage(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	imageId, _ := strconv.Atoi(ctx.Params("imageId"))
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	product, err := h.svc.DeleteProductImage(id, uint(imageId), user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Delete image", product)
 }
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CouponHandler struct {
+	Svc *service.CouponService
+}
+
+func newCouponService(rh *rest.RestHandler) *service.CouponService {
+	return service.NewCouponService(
+		repository.NewCouponRepository(rh.DB),
+		repository.NewUserRepository(rh.DB),
+		repository.NewCatalogRepository(rh.DB),
+		rh.Auth,
+	)
+}
+
+func SetupCouponRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := CouponHandler{
+		Svc: newCouponService(rh),
+	}
+
+	// the coupon applied to the buyer's cart
+	userRoutes := app.Group("/users", rh.Auth.Authorize)
+	userRoutes.Post("/cart/coupon", handler.ApplyCoupon)
+	userRoutes.Delete("/cart/coupon", handler.RemoveCoupon)
+
+	adminRoutes := app.Group("/admin", rh.Auth.Authorize)
+	manage := rh.Auth.Require(domain.PermCouponsManage)
+	adminRoutes.Post("/coupons", manage, handler.CreateCoupon)
+	adminRoutes.Get("/coupons", manage, handler.GetCoupons)
+	adminRoutes.Patch("/coupons/:id", manage, handler.UpdateCoupon)
+	adminRoutes.Delete("/coupons/:id", manage, handler.DeactivateCoupon)
+}
+
+func (h *CouponHandler) ApplyCoupon(ctx *fiber.Ctx) error {
+	req := dto.ApplyCouponRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "please provide a coupon code")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	pricing, err := h.Svc.ApplyCoupon(user.ID, req.Code)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Coupon applied successfully", pricing)
+}
+
+func (h *CouponHandler) RemoveCoupon(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	if err := h.Svc.RemoveCoupon(user.ID); err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "Coupon removed", nil)
+}
+
+func (h *CouponHandler) CreateCoupon(ctx *fiber.Ctx) error {
+	req := dto.CouponRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "create coupon request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	coupon, err := h.Svc.CreateCoupon(user, req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Coupon created successfully", coupon)
+}
+
+func (h *CouponHandler) GetCoupons(ctx *fiber.Ctx) error {
+	page := dto.PaginationRequest{
+		Page:  ctx.QueryInt("page", 1),
+		Limit: ctx.QueryInt("limit", dto.DefaultPageLimit),
+	}
+
+	coupons, err := h.Svc.GetCoupons(page)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "coupons", coupons)
+}
+
+func (h *CouponHandler) UpdateCoupon(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.CouponRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "update coupon request is not valid")
+	}
+
+	coupon, err := h.Svc.UpdateCoupon(uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Coupon updated successfully", coupon)
+}
+
+func (h *CouponHandler) DeactivateCoupon(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	if err := h.Svc.DeactivateCoupon(uint(id)); err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Coupon deactivated", nil)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type NotificationHandler struct {
+	Svc *service.NotificationService
+}
+
+func newNotificationService(rh *rest.RestHandler) *service.NotificationService {
+	return service.NewNotificationService(repository.NewNotificationRepository(rh.DB), rh.Notifier, rh.Auth)
+}
+
+func SetupNotificationRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := NotificationHandler{
+		Svc: newNotificationService(rh),
+	}
+
+	userRoutes := app.Group("/users", rh.Auth.Authorize)
+	userRoutes.Get("/notifications", handler.GetNotifications)
+	userRoutes.Post("/notifications/:id/read", handler.MarkRead)
+	userRoutes.Get("/notification-preferences", handler.GetPreference)
+	userRoutes.Put("/notification-preferences", handler.SavePreference)
+
+	adminRoutes := app.Group("/admin", rh.Auth.Authorize)
+	manage := rh.Auth.Require(domain.PermNotificationsManage)
+	adminRoutes.Get("/notification-templates", manage, handler.GetTemplates)
+	adminRoutes.Put("/notification-templates", manage, handler.SaveTemplate)
+	adminRoutes.Delete("/notification-templates/:id", manage, handler.DeleteTemplate)
+	adminRoutes.Get("/notification-deliveries", manage, handler.GetDeliveries)
+}
+
+// GetNotifications accepts ?unread=true&page=1&limit=20
+func (h *NotificationHandler) GetNotifications(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	page := dto.PaginationRequest{
+		Page:  ctx.QueryInt("page", 1),
+		Limit: ctx.QueryInt("limit", dto.DefaultPageLimit),
+	}
+
+	notifications, err := h.Svc.GetNotifications(user.ID, ctx.QueryBool("unread"), page)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "notifications", notifications)
+}
+
+func (h *NotificationHandler) MarkRead(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "notification id is not valid")
+	}
+
+	if err := h.Svc.MarkRead(user.ID, uint(id)); err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "Notification marked read", nil)
+}
+
+func (h *NotificationHandler) GetPreference(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	pref, err := h.Svc.GetPreference(user.ID)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "notification preferences", pref)
+}
+
+func (h *NotificationHandler) SavePreference(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.NotificationPreferenceRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "notification preferences are not valid")
+	}
+
+	pref, err := h.Svc.SavePreference(user.ID, req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Notification preferences saved successfully", pref)
+}
+
+func (h *NotificationHandler) GetTemplates(ctx *fiber.Ctx) error {
+	templates, err := h.Svc.GetTemplates()
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "notification templates", templates)
+}
+
+func (h *NotificationHandler) SaveTemplate(ctx *fiber.Ctx) error {
+	req := dto.NotificationTemplateRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "notification template is not valid")
+	}
+
+	tpl, err := h.Svc.SaveTemplate(req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Notification template saved successfully", tpl)
+}
+
+func (h *NotificationHandler) DeleteTemplate(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "notification template id is not valid")
+	}
+
+	if err := h.Svc.DeleteTemplate(uint(id)); err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "Notification template deleted successfully", nil)
+}
+
+// GetDeliveries accepts ?status=failed&event=password_reset&channel=sms&user_id=1&page=1&limit=20
+func (h *NotificationHandler) GetDeliveries(ctx *fiber.Ctx) error {
+	page := dto.PaginationRequest{
+		Page:  ctx.QueryInt("page", 1),
+		Limit: ctx.QueryInt("limit", dto.DefaultPageLimit),
+	}
+	filter := dto.DeliveryFilter{
+		UserId:  uint(max(ctx.QueryInt("user_id"), 0)),
+		Event:   domain.NotificationEvent(ctx.Query("event")),
+		Channel: domain.NotificationChannel(ctx.Query("channel")),
+		Status:  domain.DeliveryStatus(ctx.Query("status")),
+	}
+
+	deliveries, err := h.Svc.GetDeliveries(filter, page)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "notification deliveries", deliveries)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrderHandler struct {
+	Svc *service.OrderService
+}
+
+func SetupOrderRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := OrderHandler{
+		Svc: service.NewOrderService(repository.NewOrderRepository(rh.DB), rh.Auth),
+	}
+
+	// :id is the order id on both sides
+	buyerRoutes := app.Group("/buyer", rh.Auth.Authorize)
+	buyerRoutes.Patch("/orders/:id/status", handler.BuyerUpdateStatus)
+	buyerRoutes.Patch("/orders/:id/sub-orders/:subOrderId/status", handler.BuyerUpdateSubOrderStatus)
+	buyerRoutes.Get("/orders/:id/history", handler.BuyerGetHistory)
+
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	fulfil := rh.Auth.Require(domain.PermOrdersFulfil)
+	// sellers act on their own sub-order of the order
+	sellerRoutes.Patch("/orders/:id/status", fulfil, handler.SellerUpdateStatus)
+	sellerRoutes.Get("/orders/:id/history", fulfil, handler.SellerGetHistory)
+}
+
+func (h *OrderHandler) BuyerUpdateStatus(ctx *fiber.Ctx) error {
+	return h.updateStatus(ctx, domain.OrderActorBuyer)
+}
+
+func (h *OrderHandler) SellerUpdateStatus(ctx *fiber.Ctx) error {
+	return h.updateStatus(ctx, domain.OrderActorSeller)
+}
+
+// BuyerUpdateSubOrderStatus moves the items of a single seller, e.g. to confirm their delivery
+func (h *OrderHandler) BuyerUpdateSubOrderStatus(ctx *fiber.Ctx) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+	subOrderId, err := strconv.Atoi(ctx.Params("subOrderId"))
+	if err != nil || subOrderId < 1 {
+		return rest.BadRequestError(ctx, "sub-order id is not valid")
+	}
+
+	req := dto.UpdateOrderStatusRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Status == "" {
+		return rest.BadRequestError(ctx, "update order status request is not valid")
+	}
+
+	order, err := h.Svc.UpdateSubOrderStatus(user, domain.OrderActorBuyer, uint(id), uint(subOrderId), req)
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	}
+	return rest.SuccessResponse(ctx, "order status updated", order)
+}
+
+func (h *OrderHandler) BuyerGetHistory(ctx *fiber.Ctx) error {
+	return h.getHistory(ctx, domain.OrderActorBuyer)
+}
+
+func (h *OrderHandler) SellerGetHistory(ctx *fiber.Ctx) error {
+	return h.getHistory(ctx, domain.OrderActorSeller)
+}
+
+func (h *OrderHandler) updateStatus(ctx *fiber.Ctx, actor domain.OrderActor) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	req := dto.UpdateOrderStatusRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Status == "" {
+		return rest.BadRequestError(ctx, "update order status request is not valid")
+	}
+
+	order, err := h.Svc.UpdateStatus(user, actor, uint(id), req)
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	}
+	return rest.SuccessResponse(ctx, "order status updated", order)
+}
+
+func (h *OrderHandler) getHistory(ctx *fiber.Ctx, actor domain.OrderActor) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	history, err := h.Svc.GetOrderHistory(user, actor, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "order history", history)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RefundHandler struct {
+	Svc *service.RefundService
+}
+
+func newRefundService(rh *rest.RestHandler) *service.RefundService {
+	return service.NewRefundService(
+		repository.NewRefundRepository(rh.DB),
+		repository.NewTransactionRepository(rh.DB),
+		service.NewOrderService(repository.NewOrderRepository(rh.DB), rh.Auth),
+		rh.Pc,
+		rh.Auth,
+	)
+}
+
+func SetupRefundRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := RefundHandler{
+		Svc: newRefundService(rh),
+	}
+
+	// :id is the order id
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	fulfil := rh.Auth.Require(domain.PermOrdersFulfil)
+	sellerRoutes.Post("/orders/:id/refunds", fulfil, handler.SellerCreateRefund)
+	sellerRoutes.Get("/orders/:id/refunds", fulfil, handler.SellerGetRefunds)
+
+	adminRoutes := app.Group("/admin", rh.Auth.Authorize)
+	refunds := rh.Auth.Require(domain.PermRefundsManage)
+	adminRoutes.Post("/orders/:id/refunds", refunds, handler.AdminCreateRefund)
+	adminRoutes.Get("/orders/:id/refunds", refunds, handler.AdminGetRefunds)
+}
+
+func (h *RefundHandler) SellerCreateRefund(ctx *fiber.Ctx) error {
+	return h.createRefund(ctx, domain.OrderActorSeller)
+}
+
+func (h *RefundHandler) SellerGetRefunds(ctx *fiber.Ctx) error {
+	return h.getRefunds(ctx, domain.OrderActorSeller)
+}
+
+func (h *RefundHandler) AdminCreateRefund(ctx *fiber.Ctx) error {
+	return h.createRefund(ctx, domain.OrderActorAdmin)
+}
+
+func (h *RefundHandler) AdminGetRefunds(ctx *fiber.Ctx) error {
+	return h.getRefunds(ctx, domain.OrderActorAdmin)
+}
+
+func (h *RefundHandler) createRefund(ctx *fiber.Ctx, actor domain.OrderActor) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	req := dto.CreateRefundRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "create refund request is not valid")
+	}
+
+	refund, err := h.Svc.CreateRefund(user, actor, uint(id), req)
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	}
+	return rest.SuccessResponse(ctx, "refund created", refund)
+}
+
+func (h *RefundHandler) getRefunds(ctx *fiber.Ctx, actor domain.OrderActor) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	refunds, err := h.Svc.GetRefunds(user, actor, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "refunds", refunds)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReturnHandler struct {
+	Svc *service.ReturnService
+}
+
+func SetupReturnRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	orderSvc := service.NewOrderService(repository.NewOrderRepository(rh.DB), rh.Auth)
+	handler := ReturnHandler{
+		Svc: service.NewReturnService(
+			repository.NewReturnRepository(rh.DB),
+			orderSvc,
+			service.NewRefundService(
+				repository.NewRefundRepository(rh.DB),
+				repository.NewTransactionRepository(rh.DB),
+				orderSvc,
+				rh.Pc,
+				rh.Auth,
+			),
+			rh.Store,
+			rh.Config,
+			rh.Auth,
+		),
+	}
+
+	// :id is the order id when opening a return and the return id otherwise
+	buyerRoutes := app.Group("/buyer", rh.Auth.Authorize)
+	buyerRoutes.Post("/orders/:id/returns", handler.RequestReturn)
+	buyerRoutes.Get("/returns", handler.BuyerGetReturns)
+	buyerRoutes.Get("/returns/:id", handler.BuyerGetReturn)
+	buyerRoutes.Patch("/returns/:id/status", handler.BuyerUpdateStatus)
+
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	fulfil := rh.Auth.Require(domain.PermOrdersFulfil)
+	sellerRoutes.Get("/returns", fulfil, handler.SellerGetReturns)
+	sellerRoutes.Get("/returns/:id", fulfil, handler.SellerGetReturn)
+	sellerRoutes.Patch("/returns/:id/status", fulfil, handler.SellerUpdateStatus)
+}
+
+// RequestReturn takes JSON, or multipart form data with up to 5 photos in the "photos" field
+func (h *ReturnHandler) RequestReturn(ctx *fiber.Ctx) error {
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	req := dto.CreateReturnRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.OrderItemId == 0 {
+		return rest.BadRequestError(ctx, "return request is not valid")
+	}
+
+	var photos []*multipart.FileHeader
+	if form, err := ctx.MultipartForm(); err == nil {
+		photos = form.File["photos"]
+	}
+
+	ret, err := h.Svc.RequestReturn(user, uint(id), req, photos)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Return requested successfully", ret)
+}
+
+func (h *ReturnHandler) BuyerGetReturns(ctx *fiber.Ctx) error {
+	return h.getReturns(ctx, domain.OrderActorBuyer)
+}
+
+func (h *ReturnHandler) SellerGetReturns(ctx *fiber.Ctx) error {
+	return h.getReturns(ctx, domain.OrderActorSeller)
+}
+
+func (h *ReturnHandler) BuyerGetReturn(ctx *fiber.Ctx) error {
+	return h.getReturn(ctx, domain.OrderActorBuyer)
+}
+
+func (h *ReturnHandler) SellerGetReturn(ctx *fiber.Ctx) error {
+	return h.getReturn(ctx, domain.OrderActorSeller)
+}
+
+func (h *ReturnHandler) BuyerUpdateStatus(ctx *fiber.Ctx) error {
+	return h.updateStatus(ctx, domain.OrderActorBuyer)
+}
+
+func (h *ReturnHandler) SellerUpdateStatus(ctx *fiber.Ctx) error {
+	return h.updateStatus(ctx, domain.OrderActorSeller)
+}
+
+// getReturns accepts ?status=requested&page=1&limit=20
+func (h *ReturnHandler) getReturns(ctx *fiber.Ctx, actor domain.OrderActor) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	page := dto.PaginationRequest{
+		Page:  ctx.QueryInt("page", 1),
+		Limit: ctx.QueryInt("limit", dto.DefaultPageLimit),
+	}
+
+	returns, err := h.Svc.GetReturns(user, actor, ctx.Query("status"), page)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "returns", returns)
+}
+
+func (h *ReturnHandler) getReturn(ctx *fiber.Ctx, actor domain.OrderActor) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "return id is not valid")
+	}
+
+	ret, err := h.Svc.GetReturn(user, actor, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "return", ret)
+}
+
+func (h *ReturnHandler) updateStatus(ctx *fiber.Ctx, actor domain.OrderActor) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "return id is not valid")
+	}
+
+	req := dto.UpdateReturnStatusRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Status == "" {
+		return rest.BadRequestError(ctx, "update return status request is not valid")
+	}
+
+	ret, err := h.Svc.UpdateStatus(user, actor, uint(id), req)
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	}
+	return rest.SuccessResponse(ctx, "return status updated", ret)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReviewHandler struct {
+	Svc *service.ReviewService
+}
+
+func SetupReviewRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := ReviewHandler{
+		Svc: service.NewReviewService(
+			repository.NewReviewRepository(rh.DB),
+			repository.NewCatalogRepository(rh.DB),
+			repository.NewUserRepository(rh.DB),
+			rh.Auth,
+		),
+	}
+
+	// public, :id is the product id
+	app.Get("/products/:id/reviews", handler.GetReviews)
+
+	buyerRoutes := app.Group("/buyer", rh.Auth.Authorize)
+	buyerRoutes.Post("/products/:id/reviews", handler.CreateReview)
+	buyerRoutes.Put("/products/:id/reviews", handler.UpdateReview)
+	buyerRoutes.Post("/reviews/:id/flag", handler.FlagReview)
+
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	reply := rh.Auth.Require(domain.PermReviewsReply)
+	sellerRoutes.Post("/reviews/:id/reply", reply, handler.ReplyToReview)
+}
+
+func (h *ReviewHandler) GetReviews(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+
+	filter := dto.ReviewFilter{Sort: ctx.Query("sort")}
+	filter.Page = ctx.QueryInt("page", 1)
+	filter.Limit = ctx.QueryInt("limit", dto.DefaultPageLimit)
+
+	reviews, err := h.Svc.GetReviews(uint(id), filter)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "reviews", reviews)
+}
+
+func (h *ReviewHandler) CreateReview(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ReviewRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "review request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	review, err := h.Svc.CreateReview(user, uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Review created successfully", review)
+}
+
+func (h *ReviewHandler) UpdateReview(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ReviewRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "review request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	review, err := h.Svc.UpdateReview(user, uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Review updated successfully", review)
+}
+
+func (h *ReviewHandler) FlagReview(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.FlagReviewRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "flag request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	if err := h.Svc.FlagReview(user, uint(id), req); err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Thanks, the review was reported", nil)
+}
+
+func (h *ReviewHandler) ReplyToReview(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ReviewReplyRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "reply request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	review, err := h.Svc.ReplyToReview(user, uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Reply saved successfully", review)
+}
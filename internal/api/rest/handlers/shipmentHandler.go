@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ShipmentHandler struct {
+	Svc *service.ShipmentService
+}
+
+func SetupShipmentRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := ShipmentHandler{
+		Svc: service.NewShipmentService(
+			repository.NewShipmentRepository(rh.DB),
+			service.NewOrderService(repository.NewOrderRepository(rh.DB), rh.Auth),
+			rh.Auth,
+		),
+	}
+
+	// :id is the order id, except for status updates where it is the shipment id
+	buyerRoutes := app.Group("/buyer", rh.Auth.Authorize)
+	buyerRoutes.Get("/orders/:id/shipments", handler.BuyerGetShipments)
+
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	fulfil := rh.Auth.Require(domain.PermOrdersFulfil)
+	sellerRoutes.Post("/orders/:id/shipments", fulfil, handler.CreateShipment)
+	sellerRoutes.Get("/orders/:id/shipments", fulfil, handler.SellerGetShipments)
+	sellerRoutes.Patch("/shipments/:id/status", fulfil, handler.UpdateShipmentStatus)
+}
+
+func (h *ShipmentHandler) CreateShipment(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	req := dto.CreateShipmentRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "create shipment request is not valid")
+	}
+
+	shipment, err := h.Svc.CreateShipment(user, uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Shipment created successfully", shipment)
+}
+
+func (h *ShipmentHandler) UpdateShipmentStatus(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "shipment id is not valid")
+	}
+
+	req := dto.UpdateShipmentStatusRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Status == "" {
+		return rest.BadRequestError(ctx, "update shipment status request is not valid")
+	}
+
+	shipment, err := h.Svc.UpdateStatus(user, uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipment status updated", shipment)
+}
+
+func (h *ShipmentHandler) BuyerGetShipments(ctx *fiber.Ctx) error {
+	return h.getShipments(ctx, domain.OrderActorBuyer)
+}
+
+func (h *ShipmentHandler) SellerGetShipments(ctx *fiber.Ctx) error {
+	return h.getShipments(ctx, domain.OrderActorSeller)
+}
+
+func (h *ShipmentHandler) getShipments(ctx *fiber.Ctx, actor domain.OrderActor) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	shipments, err := h.Svc.GetOrderShipments(user, actor, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "shipments", shipments)
+}
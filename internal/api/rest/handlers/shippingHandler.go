@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ShippingHandler struct {
+	Svc *service.ShippingService
+}
+
+func newShippingService(rh *rest.RestHandler) *service.ShippingService {
+	return service.NewShippingService(
+		repository.NewShippingRepository(rh.DB),
+		repository.NewUserRepository(rh.DB),
+		rh.Auth,
+	)
+}
+
+func SetupShippingRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := ShippingHandler{
+		Svc: newShippingService(rh),
+	}
+
+	// the buyer picks a method for every seller in the cart before paying
+	userRoutes := app.Group("/users", rh.Auth.Authorize)
+	userRoutes.Get("/cart/shipping", handler.GetShippingOptions)
+	userRoutes.Put("/cart/shipping", handler.SelectShipping)
+
+	sellerRoutes := app.Group("/seller", rh.Auth.Authorize)
+	manage := rh.Auth.Require(domain.PermShippingManage)
+	sellerRoutes.Post("/shipping-methods", manage, handler.CreateMethod)
+	sellerRoutes.Get("/shipping-methods", manage, handler.GetMethods)
+	sellerRoutes.Patch("/shipping-methods/:id", manage, handler.UpdateMethod)
+	sellerRoutes.Delete("/shipping-methods/:id", manage, handler.DeactivateMethod)
+}
+
+func (h *ShippingHandler) GetShippingOptions(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	options, err := h.Svc.GetShippingOptions(user.ID)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping options", options)
+}
+
+func (h *ShippingHandler) SelectShipping(ctx *fiber.Ctx) error {
+	req := dto.SelectShippingRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "please provide the shipping method ids")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	options, err := h.Svc.SelectShipping(user.ID, req.MethodIds)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Shipping method selected", options)
+}
+
+func (h *ShippingHandler) CreateMethod(ctx *fiber.Ctx) error {
+	req := dto.ShippingMethodRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "create shipping method request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	method, err := h.Svc.CreateMethod(user, req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Shipping method created successfully", method)
+}
+
+func (h *ShippingHandler) GetMethods(ctx *fiber.Ctx) error {
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	methods, err := h.Svc.GetSellerMethods(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "shipping methods", methods)
+}
+
+func (h *ShippingHandler) UpdateMethod(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ShippingMethodRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "update shipping method request is not valid")
+	}
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	method, err := h.Svc.UpdateMethod(user, uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Shipping method updated successfully", method)
+}
+
+func (h *ShippingHandler) DeactivateMethod(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+	if err := h.Svc.DeactivateMethod(user, uint(id)); err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Shipping method deactivated", nil)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TaxHandler struct {
+	Svc *service.TaxService
+}
+
+func newTaxService(rh *rest.RestHandler) *service.TaxService {
+	return service.NewTaxService(repository.NewTaxRepository(rh.DB))
+}
+
+func SetupTaxRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := TaxHandler{
+		Svc: newTaxService(rh),
+	}
+
+	adminRoutes := app.Group("/admin", rh.Auth.Authorize)
+	manage := rh.Auth.Require(domain.PermTaxesManage)
+	adminRoutes.Post("/tax-rates", manage, handler.CreateTaxRate)
+	adminRoutes.Get("/tax-rates", manage, handler.GetTaxRates)
+	adminRoutes.Patch("/tax-rates/:id", manage, handler.UpdateTaxRate)
+	adminRoutes.Delete("/tax-rates/:id", manage, handler.DeleteTaxRate)
+}
+
+func (h *TaxHandler) CreateTaxRate(ctx *fiber.Ctx) error {
+	req := dto.TaxRateRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "create tax rate request is not valid")
+	}
+
+	rate, err := h.Svc.CreateTaxRate(req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Tax rate created successfully", rate)
+}
+
+func (h *TaxHandler) GetTaxRates(ctx *fiber.Ctx) error {
+	page := dto.PaginationRequest{
+		Page:  ctx.QueryInt("page", 1),
+		Limit: ctx.QueryInt("limit", dto.DefaultPageLimit),
+	}
+
+	rates, err := h.Svc.GetTaxRates(page)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "tax rates", rates)
+}
+
+func (h *TaxHandler) UpdateTaxRate(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.TaxRateRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "update tax rate request is not valid")
+	}
+
+	rate, err := h.Svc.UpdateTaxRate(uint(id), req)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Tax rate updated successfully", rate)
+}
+
+func (h *TaxHandler) DeleteTaxRate(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	if err := h.Svc.DeleteTaxRate(uint(id)); err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "Tax rate deleted", nil)
+}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"go-ecommerce-app/config"
 	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
 	"go-ecommerce-app/internal/dto"
 	"go-ecommerce-app/internal/helper"
 	"go-ecommerce-app/internal/repository"
 	"go-ecommerce-app/internal/service"
 	"go-ecommerce-app/pkg/payment"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -19,6 +23,9 @@ import (
 type TransactionHandler struct {
 	Svc           *service.TransactionService
 	UserSvc       service.UserService
+	InventorySvc  *service.InventoryService
+	CouponSvc     *service.CouponService
+	RefundSvc     *service.RefundService
 	PaymentClient payment.PaymentClient
 	Config        config.AppConfig
 }
@@ -34,26 +41,40 @@ func SetupTransactionRoutes(as *rest.RestHandler) {
 
 	app := as.App
 	svc := initializeTransactionService(as.DB, as.Auth)
+	couponSvc := newCouponService(as)
 	useSvc := service.UserService{
 		UserRepo:    repository.NewUserRepository(as.DB),
 		CatalogRepo: repository.NewCatalogRepository(as.DB),
 		Auth:        as.Auth,
 		Config:      as.Config,
+		Coupons:     couponSvc,
+		Taxes:       newTaxService(as),
+		Shipping:    newShippingService(as),
 	}
 	handler := TransactionHandler{
 		Svc:           svc,
 		PaymentClient: as.Pc,
 		UserSvc:       useSvc,
+		InventorySvc:  service.NewInventoryService(repository.NewInventoryRepository(as.DB), as.Config),
+		CouponSvc:     couponSvc,
+		RefundSvc:     newRefundService(as),
 		Config:        as.Config,
 	}
 
+	// public, authenticated by the provider's signature header
+	app.Post("/payments/webhook", handler.PaymentWebhook)
+
 	secRoute := app.Group("/buyer", as.Auth.Authorize)
 	secRoute.Get("/payment", handler.MakePayment)
 	secRoute.Get("/verify", handler.VerifyPayment)
 
-	sellerRoute := app.Group("/seller", as.Auth.AuthorizeSeller)
-	sellerRoute.Get("/orders", handler.GetOrders)
-	sellerRoute.Get("/orders/:id", handler.GetOrderDetails)
+	// give back stock, coupon uses and payment intents held by checkouts that were never paid
+	handler.InventorySvc.StartExpiryWorker(time.Minute, handler.expireCheckout)
+
+	sellerRoute := app.Group("/seller", as.Auth.Authorize)
+	fulfil := as.Auth.Require(domain.PermOrdersFulfil)
+	sellerRoute.Get("/orders", fulfil, handler.GetOrders)
+	sellerRoute.Get("/orders/:id", fulfil, handler.GetOrderDetails)
 }
 func (h *TransactionHandler) MakePayment(ctx *fiber.Ctx) error {
 
@@ -63,16 +84,44 @@ func (h *TransactionHandler) MakePayment(ctx *fiber.Ctx) error {
 
 	// 1. Check active payment
 	activePayment, err := h.Svc.GetActivePayment(user.ID)
-	if activePayment.ID > 0 {
-		return ctx.Status(http.StatusOK).JSON(&fiber.Map{
-			"message": "create payment",
-			"pubKey":  pubKey,
-			"secret":  activePayment.ClientSecret,
-		})
+	if err == nil && activePayment.ID > 0 {
+		if h.InventorySvc.IsReserved(activePayment.OrderId) {
+			return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+				"message": "create payment",
+				"pubKey":  pubKey,
+				"secret":  activePayment.ClientSecret,
+			})
+		}
+		// the stock hold expired, start over with a fresh reservation once the old intent can
+		// no longer be paid with the client secret the buyer still holds
+		intent, err := h.PaymentClient.CancelPayment(activePayment.PaymentId)
+		if err != nil {
+			return rest.InternalError(ctx, err)
+		}
+		switch intent.Status {
+		case payment.IntentStatusCanceled, payment.IntentStatusFailed:
+		case payment.IntentStatusSucceeded, payment.IntentStatusProcessing:
+			return rest.BadRequestError(ctx, "your previous payment is being processed, verify it before paying again")
+		default:
+			return rest.InternalError(ctx, errors.New("previous payment could not be canceled, please retry"))
+		}
+		if err := h.finalizePayment(activePayment, domain.PaymentStatusCanceled, string(intent.Raw)); err != nil {
+			return rest.InternalError(ctx, err)
+		}
 	}
 
-	// 2. Get cart total
-	_, amount, err := h.UserSvc.FindCart(user.ID)
+	// 2. Get cart total, shipping, coupon discounts and tax included
+	cartItems, pricing, checkout, err := h.UserSvc.PriceCheckout(user.ID)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	if pricing.CouponError != "" {
+		return rest.BadRequestError(ctx, "coupon "+pricing.CouponCode+" cannot be used: "+pricing.CouponError+", remove it to continue")
+	}
+	if pricing.ShippingError != "" {
+		return rest.BadRequestError(ctx, pricing.ShippingError)
+	}
+	amount := pricing.Total
 
 	// 3. Generate order reference
 	orderId, err := helper.RandomHandler(8)
@@ -80,18 +129,33 @@ func (h *TransactionHandler) MakePayment(ctx *fiber.Ctx) error {
 		return rest.InternalError(ctx, errors.New("error generating order id"))
 	}
 
-	// 4. Create a new payment session on stripe
+	// 4. Hold the stock while the buyer pays
+	if err := h.InventorySvc.ReserveCart(user.ID, orderId, cartItems); err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	if err := h.CouponSvc.ReserveCoupon(user.ID, orderId, pricing); err != nil {
+		h.releaseCheckout(orderId)
+		return rest.BadRequestError(ctx, err.Error())
+	}
+
+	// 5. Create a new payment session with the payment provider
 	paymentResult, err := h.PaymentClient.CreatePayment(amount, user.ID, orderId)
+	if err != nil {
+		h.releaseCheckout(orderId)
+		return rest.InternalError(ctx, err)
+	}
 
-	//5. Store payment session in db to create to store payment info
+	//6. Store payment session in db to create to store payment info
 	err = h.Svc.StoreCreatedPayment(dto.CreatePaymentRequest{
 		UserId:       user.ID,
 		Amount:       amount,
 		ClientSecret: paymentResult.ClientSecret,
 		PaymentId:    paymentResult.ID,
 		OrderId:      orderId,
+		Checkout:     checkout,
 	})
 	if err != nil {
+		h.releaseCheckout(orderId)
 		return ctx.Status(400).JSON(err)
 	}
 
@@ -113,36 +177,273 @@ func (h *TransactionHandler) VerifyPayment(ctx *fiber.Ctx) error {
 		return ctx.Status(400).JSON(errors.New("no active payment exist"))
 	}
 
-	// fetch payment status from stripe
+	// fetch payment status from the payment provider
 	paymentRes, err := h.PaymentClient.GetPaymentStatus(activePayment.PaymentId)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
 	PaymentJson, _ := json.Marshal(paymentRes)
 	paymentLogs := string(PaymentJson)
-	paymentStatus := "failed"
 
-	// if payment then create order
-	if paymentRes.Status == "succeeded" {
-		// create Order
-		paymentStatus = "success"
-		err = h.UserSvc.CreateOrder(user.ID, activePayment.OrderId, activePayment.PaymentId, activePayment.Amount)
+	var paymentStatus domain.PaymentStatus
 
+	switch paymentRes.Status {
+	case payment.IntentStatusSucceeded:
+		paymentStatus = domain.PaymentStatusSuccess
+	case payment.IntentStatusFailed:
+		paymentStatus = domain.PaymentStatusFailed
+	case payment.IntentStatusCanceled:
+		paymentStatus = domain.PaymentStatusCanceled
 	}
 
-	if err != nil {
-		return rest.InternalError(ctx, err)
+	// an intent still waiting on the buyer stays active
+	if paymentStatus != "" {
+		if err := h.finalizePayment(activePayment, paymentStatus, paymentLogs); err != nil {
+			return rest.InternalError(ctx, err)
+		}
 	}
 
-	// update payment status
-	h.Svc.UpdatePayment(user.ID, paymentStatus, paymentLogs)
-
 	return ctx.Status(200).JSON(&fiber.Map{
 		"message":  "create payment",
 		"response": paymentRes,
 	})
 }
+
+// PaymentWebhook receives payment intent events from the payment provider so orders are created even
+// when the buyer never comes back to call /buyer/verify. Events may be delivered more
+// than once, so every branch is idempotent.
+func (h *TransactionHandler) PaymentWebhook(ctx *fiber.Ctx) error {
+
+	event, err := h.PaymentClient.ParseWebhookEvent(ctx.Body(), func(key string) string {
+		return ctx.Get(key)
+	})
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+
+	if event.RefundId != "" {
+		return h.refundWebhook(ctx, event)
+	}
+
+	if event.PaymentId == "" {
+		return rest.SuccessResponse(ctx, "event ignored", event.Type)
+	}
+
+	activePayment, err := h.Svc.GetPaymentByPaymentId(event.PaymentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("webhook %s for unknown payment %s", event.ID, event.PaymentId)
+		return rest.SuccessResponse(ctx, "event ignored", event.Type)
+	}
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+
+	// a captured payment is final, late failure events must not override it
+	if activePayment.Status.IsCaptured() {
+		return rest.SuccessResponse(ctx, "event already processed", event.Type)
+	}
+
+	// the checkout was given up but the buyer still paid, there is no order to create
+	abandoned := activePayment.Status == domain.PaymentStatusCanceled || activePayment.Status == domain.PaymentStatusFailed
+	if event.Type == payment.WebhookPaymentSucceeded && abandoned {
+		if err := h.refundAbandonedPayment(activePayment, string(event.Payload)); err != nil {
+			return rest.InternalError(ctx, err)
+		}
+		return rest.SuccessResponse(ctx, "payment refunded", event.Type)
+	}
+
+	var paymentStatus domain.PaymentStatus
+
+	switch event.Type {
+	case payment.WebhookPaymentSucceeded:
+		paymentStatus = domain.PaymentStatusSuccess
+	case payment.WebhookPaymentFailed:
+		// a declined card, the buyer may retry with another one while the stock stays held;
+		// the payment only ends once it is canceled or expires
+		log.Printf("payment %s: attempt declined", activePayment.PaymentId)
+		return rest.SuccessResponse(ctx, "payment attempt failed", event.Type)
+	case payment.WebhookPaymentCanceled:
+		paymentStatus = domain.PaymentStatusCanceled
+	}
+
+	if err := h.finalizePayment(activePayment, paymentStatus, string(event.Payload)); err != nil {
+		// non 2xx makes the provider retry the delivery
+		return rest.InternalError(ctx, err)
+	}
+
+	return rest.SuccessResponse(ctx, "event processed", event.Type)
+}
+
+// refundWebhook settles a refund the provider first reported as pending
+func (h *TransactionHandler) refundWebhook(ctx *fiber.Ctx, event *payment.WebhookEvent) error {
+
+	err := h.RefundSvc.ResolveRefund(event.RefundId, event.RefundKey, event.RefundStatus, string(event.Payload))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if event.RefundKey != "" && domain.RefundIdFromKey(event.RefundKey) == 0 {
+			// e.g. the refund of an abandoned payment, it is not tracked as a refund
+			return rest.SuccessResponse(ctx, "event ignored", event.Type)
+		}
+		// non 2xx makes the provider retry until the refund is known
+		log.Printf("webhook %s for unknown refund %s", event.ID, event.RefundId)
+		return rest.ErrorMessage(ctx, http.StatusNotFound, errors.New("unknown refund"))
+	}
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "event processed", event.Type)
+}
+
+// finalizePayment applies the outcome of a payment: a success creates the order and sells the
+// reserved stock, a failure gives the stock back. Both verification and the webhook end up here.
+func (h *TransactionHandler) finalizePayment(p *domain.Payment, status domain.PaymentStatus, paymentLogs string) error {
+
+	// The steps are separate writes, not one transaction. Each is safe to run again: the order
+	// is looked up by its reference (unique) before it is created, and commit, redeem and
+	// release only touch reservations and redemptions still in the state they move from.
+	// The payment status is written last, so when a step fails the payment stays initial and
+	// the next verify call or webhook delivery runs them all again.
+	switch status {
+	case domain.PaymentStatusSuccess:
+		if err := h.UserSvc.CreateOrder(p); err != nil {
+			return err
+		}
+		if err := h.InventorySvc.Commit(p.OrderId); err != nil {
+			return err
+		}
+		if err := h.CouponSvc.Redeem(p.OrderId); err != nil {
+			return err
+		}
+	case domain.PaymentStatusFailed, domain.PaymentStatusCanceled:
+		if err := h.InventorySvc.Release(p.OrderId); err != nil {
+			return err
+		}
+		if err := h.CouponSvc.Release(p.OrderId); err != nil {
+			return err
+		}
+	}
+
+	return h.Svc.SetPaymentStatus(p, status, paymentLogs)
+}
+
+// refundAbandonedPayment pays back in full a payment that succeeded after its checkout was
+// canceled or failed
+func (h *TransactionHandler) refundAbandonedPayment(p *domain.Payment, paymentLogs string) error {
+	result, err := h.PaymentClient.RefundPayment(p.PaymentId, p.Amount, "payment completed after its checkout was "+string(p.Status), "abandoned_"+p.PaymentId)
+	if err != nil {
+		return err
+	}
+	if result.Status == payment.RefundStatusFailed {
+		return errors.New("refund was declined by the payment provider")
+	}
+	log.Printf("payment %s completed after its checkout was %s, refunded with %s", p.PaymentId, p.Status, result.ID)
+
+	p.RefundedAmount = p.Amount
+	return h.Svc.SetPaymentStatus(p, domain.PaymentStatusRefunded, paymentLogs)
+}
+
+// releaseCheckout gives back the stock and coupon held for a payment that was not created
+func (h *TransactionHandler) releaseCheckout(orderRef string) {
+	if err := h.InventorySvc.Release(orderRef); err != nil {
+		log.Printf("unable to release stock for %s: %v", orderRef, err)
+	}
+	if err := h.CouponSvc.Release(orderRef); err != nil {
+		log.Printf("unable to release coupon for %s: %v", orderRef, err)
+	}
+}
+
+// expireCheckout settles a checkout whose stock hold expired: its payment intent is canceled so
+// the buyer can no longer pay it, then the coupon use goes back. An intent the buyer already paid
+// is left to the webhook, which sells the stock again.
+func (h *TransactionHandler) expireCheckout(orderRef string) {
+
+	p, err := h.Svc.GetPaymentByOrderId(orderRef)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// the payment was never stored, nothing can be paid for this checkout
+		h.releaseCheckout(orderRef)
+		return
+	}
+	if err != nil {
+		log.Printf("unable to expire checkout %s: %v", orderRef, err)
+		return
+	}
+	if p.Status != domain.PaymentStatusInitial {
+		return
+	}
+
+	intent, err := h.PaymentClient.CancelPayment(p.PaymentId)
+	if err != nil {
+		// settled when the buyer returns to pay or verify
+		log.Printf("unable to cancel payment %s of expired checkout %s: %v", p.PaymentId, orderRef, err)
+		return
+	}
+	switch intent.Status {
+	case payment.IntentStatusCanceled, payment.IntentStatusFailed:
+		if err := h.finalizePayment(p, domain.PaymentStatusCanceled, string(intent.Raw)); err != nil {
+			log.Printf("unable to cancel payment %s of expired checkout %s: %v", p.PaymentId, orderRef, err)
+		}
+	default:
+		log.Printf("payment %s of expired checkout %s is %s, left to the webhook", p.PaymentId, orderRef, intent.Status)
+	}
+}
+
 func (h *TransactionHandler) GetOrders(ctx *fiber.Ctx) error {
-	return ctx.Status(fiber.StatusOK).JSON("success")
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	filter := dto.SellerOrderFilter{
+		Status: ctx.Query("status"),
+	}
+	filter.Page = ctx.QueryInt("page", 1)
+	filter.Limit = ctx.QueryInt("limit", dto.DefaultPageLimit)
+
+	// from / to accept a date (2006-01-02) or a full RFC3339 timestamp
+	var err error
+	if filter.From, err = parseDateQuery(ctx.Query("from"), false); err != nil {
+		return rest.BadRequestError(ctx, "from must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+	}
+	if filter.To, err = parseDateQuery(ctx.Query("to"), true); err != nil {
+		return rest.BadRequestError(ctx, "to must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+	}
+
+	orders, err := h.Svc.GetOrders(user, filter)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "orders", orders)
 }
 
 func (h *TransactionHandler) GetOrderDetails(ctx *fiber.Ctx) error {
-	return ctx.Status(fiber.StatusOK).JSON("success")
+
+	user := h.Svc.Auth.GetCurrentUser(ctx)
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "order id is not valid")
+	}
+
+	// like the listing, one row per item of the seller
+	items, err := h.Svc.GetOrderDetails(user, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "order details", items)
+}
+
+// parseDateQuery parses an optional date filter; a plain date used as an upper bound
+// covers the whole day
+func parseDateQuery(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
 }
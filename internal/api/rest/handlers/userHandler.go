@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
 	"go-ecommerce-app/internal/api/rest"
+	"go-ecommerce-app/internal/domain"
 	"go-ecommerce-app/internal/dto"
 	"go-ecommerce-app/internal/repository"
 	"go-ecommerce-app/internal/service"
@@ -24,10 +24,16 @@ func SetupUserRoutes(rh *rest.RestHandler) {
 
 	//create an instance of user service & inject to handler
 	svc := service.UserService{
-		UserRepo:    repository.NewUserRepository(rh.DB),
-		CatalogRepo: repository.NewCatalogRepository(rh.DB),
-		Auth:        rh.Auth,
-		Config:      rh.Config,
+		UserRepo:      repository.NewUserRepository(rh.DB),
+		CatalogRepo:   repository.NewCatalogRepository(rh.DB),
+		Auth:          rh.Auth,
+		Config:        rh.Config,
+		Coupons:       newCouponService(rh),
+		Taxes:         newTaxService(rh),
+		Shipping:      newShippingService(rh),
+		Sessions:      repository.NewSessionRepository(rh.DB),
+		Resets:        repository.NewPasswordResetRepository(rh.DB),
+		Notifications: newNotificationService(rh),
 	}
 	handler := UserHandler{
 		svc: svc,
@@ -37,6 +43,10 @@ func SetupUserRoutes(rh *rest.RestHandler) {
 	//Public endpoints
 	pubRoutes.Post("/register", handler.Register)
 	pubRoutes.Post("/login", handler.Login)
+	pubRoutes.Post("/password/forgot", handler.ForgotPassword)
+	pubRoutes.Post("/password/reset", handler.ResetPassword)
+	// registered ahead of the private group, an expired access token must not block a refresh
+	pubRoutes.Post("/users/token/refresh", handler.RefreshToken)
 
 	//Private routes ko grouping kardenge and can be accessible only by authorization
 	pvtRoutes := pubRoutes.Group("/users", rh.Auth.Authorize)
@@ -51,9 +61,14 @@ func SetupUserRoutes(rh *rest.RestHandler) {
 	pvtRoutes.Get("/cart", handler.GetCart)
 
 	pvtRoutes.Get("/order", handler.GetOrders)
-	pvtRoutes.Get("/order/:id", handler.GetOrders)
+	pvtRoutes.Get("/order/:id", handler.GetOrder)
 
 	pvtRoutes.Post("/become-seller", handler.BecomeSeller)
+	pvtRoutes.Post("/logout", handler.Logout)
+	pvtRoutes.Post("/password", handler.ChangePassword)
+
+	adminRoutes := app.Group("/admin", rh.Auth.Authorize)
+	adminRoutes.Patch("/users/:id/role", rh.Auth.Require(domain.PermUsersManage), handler.AssignRole)
 }
 
 func (h *UserHandler) Register(ctx *fiber.Ctx) error {
@@ -65,7 +80,7 @@ func (h *UserHandler) Register(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, err := h.svc.Signup(user)
+	tokens, err := h.svc.Signup(user)
 	if err != nil {
 		log.Printf("Signup error: %v\n", err) // <--- THIS IS IMPORTANT
 
@@ -76,8 +91,10 @@ func (h *UserHandler) Register(ctx *fiber.Ctx) error {
 	}
 
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
-		"message": "signup success",
-		"token":   token,
+		"message":       "signup success",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 func (h *UserHandler) Login(ctx *fiber.Ctx) error {
@@ -91,7 +108,7 @@ func (h *UserHandler) Login(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, err := h.svc.Login(loginInput.Email, loginInput.Password)
+	tokens, err := h.svc.Login(loginInput.Email, loginInput.Password)
 
 	if err != nil {
 		return ctx.Status(http.StatusUnauthorized).JSON(&fiber.Map{
@@ -99,20 +116,141 @@ func (h *UserHandler) Login(ctx *fiber.Ctx) error {
 		})
 	}
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
-		"message": "login",
-		"token":   token,
+		"message":       "login",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
-func (h *UserHandler) GetverificationCode(ctx *fiber.Ctx) error {
+
+func (h *UserHandler) RefreshToken(ctx *fiber.Ctx) error {
+
+	input := dto.RefreshTokenInput{}
+	if err := ctx.BodyParser(&input); err != nil || input.RefreshToken == "" {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": "please provide a refresh token",
+		})
+	}
+
+	tokens, err := h.svc.RefreshSession(input.RefreshToken)
+	if err != nil {
+		return ctx.Status(http.StatusUnauthorized).JSON(&fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message":       "token refreshed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+func (h *UserHandler) ForgotPassword(ctx *fiber.Ctx) error {
+
+	input := dto.ForgotPasswordInput{}
+	if err := ctx.BodyParser(&input); err != nil || input.Email == "" {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": "please provide your email",
+		})
+	}
+
+	if err := h.svc.RequestPasswordReset(input.Email); err != nil {
+		log.Printf("password reset request failed: %v", err)
+		return ctx.Status(http.StatusInternalServerError).JSON(&fiber.Map{
+			"message": "unable to send a reset code, please try again later",
+		})
+	}
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message": "if the account exists, a reset code has been sent",
+	})
+}
+
+func (h *UserHandler) ResetPassword(ctx *fiber.Ctx) error {
+
+	input := dto.ResetPasswordInput{}
+	if err := ctx.BodyParser(&input); err != nil || input.Email == "" || input.Code == "" {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": "please provide your email, the reset code and a new password",
+		})
+	}
+
+	if err := h.svc.ResetPassword(input); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message": "password reset, please log in again",
+	})
+}
+
+func (h *UserHandler) ChangePassword(ctx *fiber.Ctx) error {
+
 	user := h.svc.Auth.GetCurrentUser(ctx)
 
-	err := h.svc.GetVerificationCode(user)
+	input := dto.ChangePasswordInput{}
+	if err := ctx.BodyParser(&input); err != nil || input.OldPassword == "" {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": "please provide your current and new password",
+		})
+	}
+
+	tokens, err := h.svc.ChangePassword(user.ID, input)
 	if err != nil {
-		fmt.Println("GetVerificationCode error:", err) // 🔍 DEBUG
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message":       "password changed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+func (h *UserHandler) AssignRole(ctx *fiber.Ctx) error {
+
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil || id < 1 {
+		return rest.BadRequestError(ctx, "user id is not valid")
+	}
+
+	input := dto.AssignRoleInput{}
+	if err := ctx.BodyParser(&input); err != nil || input.Role == "" {
+		return rest.BadRequestError(ctx, "please provide a role")
+	}
+
+	if err := h.svc.AssignRole(uint(id), input.Role); err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "role updated", nil)
+}
+
+func (h *UserHandler) Logout(ctx *fiber.Ctx) error {
+
+	if err := h.svc.Logout(h.svc.Auth.GetCurrentSession(ctx)); err != nil {
 		return ctx.Status(http.StatusInternalServerError).JSON(&fiber.Map{
-			"message": err.Error(), // temporarily return real reason
+			"message": err.Error(),
 		})
 	}
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message": "logged out",
+	})
+}
+func (h *UserHandler) GetverificationCode(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	// phone unless the email is asked for, as codes used to go out by sms only
+	channel := domain.VerifyChannel(ctx.Query("channel", string(domain.VerifyChannelPhone)))
+	err := h.svc.GetVerificationCode(user, channel)
+	if errors.Is(err, service.ErrCodeNotSent) {
+		return rest.InternalError(ctx, err)
+	}
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
 
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
 		"message": "GetverificationCode",
@@ -155,7 +293,7 @@ func (h *UserHandler) CreateProfile(ctx *fiber.Ctx) error {
 		})
 	}
 
-	log.Printf("User &v", user)
+	log.Printf("User %v", user)
 
 	//create profile
 
@@ -231,13 +369,14 @@ func (h *UserHandler) AddtoCart(ctx *fiber.Ctx) error {
 func (h *UserHandler) GetCart(ctx *fiber.Ctx) error {
 
 	user := h.svc.Auth.GetCurrentUser(ctx)
-	cart, _, err := h.svc.FindCart(user.ID)
+	cart, pricing, err := h.svc.PriceCart(user.ID)
 	if err != nil {
 		return rest.InternalError(ctx, errors.New("cart does not exist"))
 	}
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
 		"message": "get cart",
 		"cart":    cart,
+		"pricing": pricing,
 	})
 
 }
@@ -259,7 +398,7 @@ func (h *UserHandler) GetOrder(ctx *fiber.Ctx) error {
 
 	order, err := h.svc.GetOrderById(uint(orderId), user.ID)
 	if err != nil {
-		return rest.InternalError(ctx, err)
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
 	}
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
 		"message": "Get order by id",
@@ -282,7 +421,7 @@ func (h *UserHandler) BecomeSeller(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, err := h.svc.BecomeSeller(user.ID, req)
+	tokens, err := h.svc.BecomeSeller(user.ID, req)
 	if err != nil {
 		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
 			"message": err.Error(),
@@ -290,7 +429,9 @@ func (h *UserHandler) BecomeSeller(ctx *fiber.Ctx) error {
 	}
 
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
-		"message": "seller account created",
-		"token":   token,
+		"message":       "seller account created",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
@@ -3,16 +3,20 @@ package rest
 import (
 	"go-ecommerce-app/config"
 	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/pkg/notification"
 	"go-ecommerce-app/pkg/payment"
+	"go-ecommerce-app/pkg/storage"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 type RestHandler struct {
-	App    *fiber.App
-	DB     *gorm.DB
-	Auth   helper.Auth
-	Config config.AppConfig
-	Pc     payment.PaymentClient
+	App      *fiber.App
+	DB       *gorm.DB
+	Auth     helper.Auth
+	Config   config.AppConfig
+	Pc       payment.PaymentClient
+	Store    storage.Storage
+	Notifier notification.NotificationClient
 }
@@ -9,7 +9,11 @@ import (
 	"go-ecommerce-app/internal/api/rest/handlers"
 	"go-ecommerce-app/internal/domain"
 	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/internal/service"
+	"go-ecommerce-app/pkg/notification"
 	"go-ecommerce-app/pkg/payment"
+	"go-ecommerce-app/pkg/storage"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -18,7 +22,9 @@ import (
 )
 
 func StartServer(cfg config.AppConfig) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		BodyLimit: 4 * service.MaxImageSize, // room for a few product images per upload
+	})
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return rest.SuccessResponse(c, "I am Healty", &fiber.Map{
@@ -37,31 +43,107 @@ func StartServer(cfg config.AppConfig) {
 		&domain.User{},
 		&domain.Address{},
 		&domain.BankAccount{},
+		&domain.Session{},
+		&domain.RefreshToken{},
+		&domain.PasswordReset{},
+		&domain.NotificationTemplate{},
+		&domain.NotificationPreference{},
+		&domain.Notification{},
+		&domain.NotificationDelivery{},
 		&domain.Category{},
 		&domain.Product{},
+		&domain.ProductOption{},
+		&domain.ProductVariant{},
+		&domain.ProductImage{},
 		&domain.Cart{},
 		&domain.Order{},
 		&domain.OrderItem{},
+		&domain.SubOrder{},
+		&domain.OrderStatusHistory{},
 		&domain.Payment{},
+		&domain.Refund{},
+		&domain.OrderCancellation{},
+		&domain.ReturnRequest{},
+		&domain.ReturnPhoto{},
+		&domain.ReturnEvent{},
+		&domain.StockReservation{},
+		&domain.StockMovement{},
+		&domain.Review{},
+		&domain.ReviewFlag{},
+		&domain.Coupon{},
+		&domain.CartCoupon{},
+		&domain.CouponRedemption{},
+		&domain.TaxRate{},
+		&domain.ShippingMethod{},
+		&domain.CartShipping{},
+		&domain.OrderShipping{},
+		&domain.Shipment{},
+		&domain.ShipmentItem{},
+		&domain.ShipmentEvent{},
 	); err != nil {
 		log.Printf("migration failed: %v", err)
 	}
 
+	if n, err := repository.NewCatalogRepository(db).CreateOpeningBalances(); err != nil {
+		log.Printf("stock ledger backfill failed: %v", err)
+	} else if n > 0 {
+		log.Printf("opened stock ledger for %d products", n)
+	}
+
+	if n, err := repository.NewUserRepository(db).BackfillPhoneVerified(); err != nil {
+		log.Printf("phone verification backfill failed: %v", err)
+	} else if n > 0 {
+		log.Printf("marked %d verified users as phone verified", n)
+	}
+
+	if n, err := repository.NewUserRepository(db).PromoteAdmins(cfg.AdminEmails); err != nil {
+		log.Printf("admin setup failed: %v", err)
+	} else if n > 0 {
+		log.Printf("promoted %d users of ADMIN_EMAILS to admin", n)
+	}
+
+	if n, err := repository.NewNotificationRepository(db).CreateDefaultTemplates(service.DefaultNotificationTemplates); err != nil {
+		log.Printf("notification templates setup failed: %v", err)
+	} else if n > 0 {
+		log.Printf("stored %d default notification templates", n)
+	}
+
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowHeaders: "Content-Type, Accept, Authorization",
 		AllowMethods: "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 	}))
 
-	auth := helper.SetupAuth(cfg.AppSecret)
-	paymentClient := payment.NewPaymentClient(cfg.StripeSecret)
+	auth := helper.SetupAuth(cfg.AppSecret, cfg.AccessTokenTTL, repository.NewSessionRepository(db))
+	paymentClient, err := payment.NewPaymentClient(cfg)
+	if err != nil {
+		log.Printf("payment provider setup failed: %v", err)
+		return
+	}
+
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		log.Printf("storage setup failed: %v", err)
+		return
+	}
+	if _, ok := store.(*storage.LocalStorage); ok {
+		app.Static("/uploads", cfg.StorageLocalDir)
+	}
+
+	notifier, err := notification.NewNotificationClient(cfg)
+	if err != nil {
+		log.Printf("notification setup failed: %v", err)
+		return
+	}
 
 	rh := &rest.RestHandler{
-		App:    app,
-		DB:     db,
-		Auth:   auth,
-		Config: cfg,
-		Pc:     paymentClient,
+		App:      app,
+		DB:       db,
+		Auth:     auth,
+		Config:   cfg,
+		Pc:       paymentClient,
+		Store:    store,
+		Notifier: notifier,
 	}
 
 	setupRoutes(rh)
@@ -84,5 +166,15 @@ func StartServer(cfg config.AppConfig) {
 func setupRoutes(rh *rest.RestHandler) {
 	handlers.SetupCatalogRoutes(rh)
 	handlers.SetupUserRoutes(rh)
+	handlers.SetupNotificationRoutes(rh)
 	handlers.SetupTransactionRoutes(rh)
+	handlers.SetupOrderRoutes(rh)
+	handlers.SetupRefundRoutes(rh)
+	handlers.SetupReviewRoutes(rh)
+	handlers.SetupCouponRoutes(rh)
+	handlers.SetupTaxRoutes(rh)
+	handlers.SetupShippingRoutes(rh)
+	handlers.SetupShipmentRoutes(rh)
+	handlers.SetupCancellationRoutes(rh)
+	handlers.SetupReturnRoutes(rh)
 }
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+type CancelReason string
+
+const (
+	CancelReasonChangedMind      CancelReason = "changed_mind"
+	CancelReasonOrderedByMistake CancelReason = "ordered_by_mistake"
+	CancelReasonFoundCheaper     CancelReason = "found_cheaper"
+	CancelReasonDeliveryTooSlow  CancelReason = "delivery_too_slow"
+	CancelReasonOutOfStock       CancelReason = "out_of_stock"
+	CancelReasonCannotShip       CancelReason = "cannot_ship"
+	CancelReasonPricingError     CancelReason = "pricing_error"
+	CancelReasonSuspectedFraud   CancelReason = "suspected_fraud"
+	CancelReasonOther            CancelReason = "other"
+)
+
+// CancelReasons are the reason codes each party may give when cancelling
+var CancelReasons = map[OrderActor][]CancelReason{
+	OrderActorBuyer: {
+		CancelReasonChangedMind, CancelReasonOrderedByMistake, CancelReasonFoundCheaper,
+		CancelReasonDeliveryTooSlow, CancelReasonOther,
+	},
+	OrderActorSeller: {
+		CancelReasonOutOfStock, CancelReasonCannotShip, CancelReasonPricingError,
+		CancelReasonSuspectedFraud, CancelReasonOther,
+	},
+}
+
+func (r CancelReason) AllowedFor(actor OrderActor) bool {
+	return slices.Contains(CancelReasons[actor], r)
+}
+
+// OrderCancellation records who cancelled a seller's sub-order, why, and what was refunded
+type OrderCancellation struct {
+	ID           uint         `json:"id" gorm:"PrimaryKey"`
+	OrderId      uint         `json:"order_id" gorm:"index;not null"`
+	SubOrderId   uint         `json:"sub_order_id" gorm:"index;not null"`
+	ActorId      uint         `json:"actor_id"`
+	ActorRole    OrderActor   `json:"actor_role"`
+	Reason       CancelReason `json:"reason" gorm:"not null"`
+	Note         string       `json:"note"`
+	RefundId     uint         `json:"refund_id"` // 0 when nothing was refunded
+	RefundAmount float64      `json:"refund_amount" gorm:"default:0"`
+	CreatedAt    time.Time    `json:"created_at" gorm:"default:current_timestamp"`
+}
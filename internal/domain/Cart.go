@@ -3,14 +3,19 @@ package domain
 import "time"
 
 type Cart struct {
-	ID        uint      `gorm:"PrimaryKey" json:"id"`
-	UserId    uint      `json:"user_id"`
-	ProductId uint      `json:"product_id"`
-	Name      string    `json:"name"`
-	ImageUrl  string    `json:"image_url"`
-	SellerId  uint      `json:"seller_id"`
-	Price     float64   `json:"price"`
-	Qty       uint      `json:"qty"`
-	CreatedAt time.Time `gorm:"default:current_timestamp"`
-	UpdatedAt time.Time `gorm:"default:current_timestamp"`
+	ID        uint              `gorm:"PrimaryKey" json:"id"`
+	UserId    uint              `json:"user_id"`
+	ProductId uint              `json:"product_id"`
+	VariantId uint              `json:"variant_id" gorm:"default:0"` // 0 for products without variants
+	Sku       string            `json:"sku"`
+	Options   map[string]string `json:"options,omitempty" gorm:"serializer:json"`
+	Name      string            `json:"name"`
+	ImageUrl  string            `json:"image_url"`
+	SellerId  uint              `json:"seller_id"`
+	Price     float64           `json:"price"`
+	TaxClass  string            `json:"tax_class" gorm:"default:standard"`
+	Weight    float64           `json:"weight" gorm:"default:0"` // kg per unit
+	Qty       uint              `json:"qty"`
+	CreatedAt time.Time         `gorm:"default:current_timestamp"`
+	UpdatedAt time.Time         `gorm:"default:current_timestamp"`
 }
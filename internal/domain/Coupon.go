@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+type CouponType string
+
+const (
+	CouponTypePercentage   CouponType = "percentage"    // Value percent off the eligible items
+	CouponTypeFixedAmount  CouponType = "fixed_amount"  // Value off the eligible items
+	CouponTypeFreeShipping CouponType = "free_shipping" // shipping is not charged
+	CouponTypeBuyXGetY     CouponType = "buy_x_get_y"   // for every BuyQty items the GetQty cheapest are free
+)
+
+func (t CouponType) IsValid() bool {
+	switch t {
+	case CouponTypePercentage, CouponTypeFixedAmount, CouponTypeFreeShipping, CouponTypeBuyXGetY:
+		return true
+	}
+	return false
+}
+
+type Coupon struct {
+	ID           uint       `json:"id" gorm:"PrimaryKey"`
+	Code         string     `json:"code" gorm:"uniqueIndex;size:64;not null"` // stored upper case
+	Description  string     `json:"description"`
+	Type         CouponType `json:"type" gorm:"not null"`
+	Value        float64    `json:"value"`        // percent or amount, depending on Type
+	MaxDiscount  float64    `json:"max_discount"` // caps percentage discounts, 0 means no cap
+	BuyQty       int        `json:"buy_qty"`
+	GetQty       int        `json:"get_qty"`
+	MinSpend     float64    `json:"min_spend"` // counted over the items the coupon applies to
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	UsageLimit   int        `json:"usage_limit"`    // total uses, 0 means unlimited
+	PerUserLimit int        `json:"per_user_limit"` // uses per buyer, 0 means unlimited
+	UsedCount    int        `json:"used_count" gorm:"default:0"`
+	CategoryIds  []uint     `json:"category_ids" gorm:"serializer:json"` // empty applies to every category
+	SellerIds    []uint     `json:"seller_ids" gorm:"serializer:json"`   // empty applies to every seller
+	Active       bool       `json:"active" gorm:"default:true"`
+	CreatedBy    uint       `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// IsRunning reports whether the coupon can be used at t, usage limits aside
+func (c Coupon) IsRunning(t time.Time) bool {
+	if !c.Active {
+		return false
+	}
+	if c.StartsAt != nil && t.Before(*c.StartsAt) {
+		return false
+	}
+	if c.EndsAt != nil && !t.Before(*c.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// AppliesToSeller reports whether items sold by sellerId are in the coupon scope
+func (c Coupon) AppliesToSeller(sellerId uint) bool {
+	return len(c.SellerIds) == 0 || slices.Contains(c.SellerIds, sellerId)
+}
+
+// CartCoupon is the coupon a buyer applied to their cart
+type CartCoupon struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	UserId    uint      `json:"user_id" gorm:"uniqueIndex;not null"`
+	CouponId  uint      `json:"coupon_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+type RedemptionStatus string
+
+const (
+	RedemptionStatusReserved RedemptionStatus = "reserved" // held while the buyer pays
+	RedemptionStatusRedeemed RedemptionStatus = "redeemed"
+	RedemptionStatusReleased RedemptionStatus = "released"
+)
+
+// CouponRedemption is one use of a coupon, it counts against the usage limits unless released
+type CouponRedemption struct {
+	ID        uint             `json:"id" gorm:"PrimaryKey"`
+	CouponId  uint             `json:"coupon_id" gorm:"index;not null"`
+	UserId    uint             `json:"user_id" gorm:"index;not null"`
+	OrderRef  string           `json:"order_ref" gorm:"uniqueIndex;size:32;not null"`
+	Amount    float64          `json:"amount"`
+	Status    RedemptionStatus `json:"status" gorm:"default:reserved;index"`
+	CreatedAt time.Time        `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time        `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// NotificationEvent names something users are told about, each has its own templates
+type NotificationEvent string
+
+const (
+	EventVerificationCode       NotificationEvent = "verification_code"
+	EventPasswordReset          NotificationEvent = "password_reset"
+	EventOrderCancelledByBuyer  NotificationEvent = "order_cancelled_by_buyer"  // sent to the seller
+	EventOrderCancelledBySeller NotificationEvent = "order_cancelled_by_seller" // sent to the buyer
+)
+
+var NotificationEvents = []NotificationEvent{
+	EventVerificationCode, EventPasswordReset, EventOrderCancelledByBuyer, EventOrderCancelledBySeller,
+}
+
+func (e NotificationEvent) IsValid() bool {
+	return slices.Contains(NotificationEvents, e)
+}
+
+type NotificationChannel string
+
+const (
+	ChannelSMS     NotificationChannel = "sms"
+	ChannelEmail   NotificationChannel = "email"
+	ChannelInApp   NotificationChannel = "in_app"
+	ChannelWebhook NotificationChannel = "webhook"
+)
+
+var NotificationChannels = []NotificationChannel{ChannelSMS, ChannelEmail, ChannelInApp, ChannelWebhook}
+
+func (c NotificationChannel) IsValid() bool {
+	return slices.Contains(NotificationChannels, c)
+}
+
+// DefaultLocale is used when a user has none or no template exists in theirs
+const DefaultLocale = "en"
+
+// NotificationTemplate is the text of an event in one locale. Subject, Text and HTML are Go
+// templates rendered with the event data. An empty Channel applies to every channel that
+// has no template of its own; SMS and in-app use Text, email uses all three.
+type NotificationTemplate struct {
+	ID        uint                `json:"id" gorm:"PrimaryKey"`
+	Event     NotificationEvent   `json:"event" gorm:"uniqueIndex:idx_notification_template;not null"`
+	Channel   NotificationChannel `json:"channel" gorm:"uniqueIndex:idx_notification_template;not null;default:''"`
+	Locale    string              `json:"locale" gorm:"uniqueIndex:idx_notification_template;not null"`
+	Subject   string              `json:"subject"`
+	Text      string              `json:"text" gorm:"not null"`
+	HTML      string              `json:"html"`
+	CreatedAt time.Time           `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time           `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// NotificationPreference is where a user wants to be told about events. Users without
+// one get DefaultNotificationPreference.
+type NotificationPreference struct {
+	UserId        uint      `json:"user_id" gorm:"PrimaryKey;autoIncrement:false"`
+	SMS           bool      `json:"sms"`
+	Email         bool      `json:"email"`
+	InApp         bool      `json:"in_app"`
+	Webhook       bool      `json:"webhook"`
+	WebhookUrl    string    `json:"webhook_url"`
+	WebhookSecret string    `json:"webhook_secret"` // signs the webhook bodies, see notification.WebhookSignatureHeader
+	Locale        string    `json:"locale" gorm:"not null;default:'en'"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+func DefaultNotificationPreference(userId uint) NotificationPreference {
+	return NotificationPreference{UserId: userId, SMS: true, Email: true, InApp: true, Locale: DefaultLocale}
+}
+
+// Channels lists the channels the user opted into
+func (p NotificationPreference) Channels() []NotificationChannel {
+	var channels []NotificationChannel
+	if p.SMS {
+		channels = append(channels, ChannelSMS)
+	}
+	if p.Email {
+		channels = append(channels, ChannelEmail)
+	}
+	if p.InApp {
+		channels = append(channels, ChannelInApp)
+	}
+	if p.Webhook && p.WebhookUrl != "" {
+		channels = append(channels, ChannelWebhook)
+	}
+	return channels
+}
+
+// Notification is a message in a user's in-app inbox
+type Notification struct {
+	ID        uint              `json:"id" gorm:"PrimaryKey"`
+	UserId    uint              `json:"user_id" gorm:"index;not null"`
+	Event     NotificationEvent `json:"event" gorm:"not null"`
+	Subject   string            `json:"subject"`
+	Body      string            `json:"body"`
+	ReadAt    *time.Time        `json:"read_at"`
+	CreatedAt time.Time         `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusSent    DeliveryStatus = "sent"
+	DeliveryStatusFailed  DeliveryStatus = "failed"
+	DeliveryStatusSkipped DeliveryStatus = "skipped" // e.g. no phone number on file
+)
+
+// NotificationDelivery records one attempt to send an event over a channel. The rendered
+// message is not kept, it may hold one-time codes.
+type NotificationDelivery struct {
+	ID        uint                `json:"id" gorm:"PrimaryKey"`
+	UserId    uint                `json:"user_id" gorm:"index;not null"`
+	Event     NotificationEvent   `json:"event" gorm:"index;not null"`
+	Channel   NotificationChannel `json:"channel" gorm:"not null"`
+	Recipient string              `json:"recipient"`
+	Status    DeliveryStatus      `json:"status" gorm:"index;not null"`
+	Error     string              `json:"error"`
+	CreatedAt time.Time           `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -3,14 +3,24 @@ package domain
 import "time"
 
 type Order struct {
-	ID             uint        `json:"id" gorm:"primaryKey"`
-	UserId         uint        `json:"user_id"`
-	Status         string      `json:"status"`
-	Amount         float64     `json:"amount"`
-	TransactionId  string      `json:"transaction_id"`
-	OrderRefNumber string      `json:"order_ref_number" gorm:"uniqueIndex;size:32"`
-	PaymentId      string      `json:"payment_id"`
-	Items          []OrderItem `json:"items"`
-	CreatedAt      time.Time   `json:"created_at"`
-	UpdatedAt      time.Time   `json:"updated_at"`
+	ID              uint                 `json:"id" gorm:"primaryKey"`
+	UserId          uint                 `json:"user_id"`
+	Status          OrderStatus          `json:"status" gorm:"default:pending;index"`
+	Amount          float64              `json:"amount"`
+	DiscountAmount  float64              `json:"discount_amount" gorm:"default:0"`
+	CouponCode      string               `json:"coupon_code"`
+	TaxAmount       float64              `json:"tax_amount" gorm:"default:0"` // inclusive and exclusive tax
+	ShippingAmount  float64              `json:"shipping_amount" gorm:"default:0"`
+	ShippingAddress ShippingAddress      `json:"shipping_address" gorm:"embedded;embeddedPrefix:ship_"`
+	Shipping        []OrderShipping      `json:"shipping,omitempty"`
+	Shipments       []Shipment           `json:"shipments,omitempty"`
+	RefundedAmount  float64              `json:"refunded_amount" gorm:"default:0"`
+	TransactionId   string               `json:"transaction_id"`
+	OrderRefNumber  string               `json:"order_ref_number" gorm:"uniqueIndex;size:32"`
+	PaymentId       string               `json:"payment_id"`
+	Items           []OrderItem          `json:"items"`
+	SubOrders       []SubOrder           `json:"sub_orders,omitempty"` // one per seller
+	History         []OrderStatusHistory `json:"history,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
 }
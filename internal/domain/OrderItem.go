@@ -3,14 +3,33 @@ package domain
 import "time"
 
 type OrderItem struct {
-	ID        uint      `json:"id" gorm:"PrimaryKey"`
-	OrderId   uint      `json:"user_id"`
-	ProductId uint      `json:""`
-	Name      string    `json:"amount" `
-	ImageUrl  string    `json:"image_url"`
-	SellerId  uint      `json:"seller_id"`
-	Price     float64   `json:"price"`
-	Qty       uint      `json:"qty"`
-	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	ID             uint              `json:"id" gorm:"PrimaryKey"`
+	OrderId        uint              `json:"order_id"`
+	SubOrderId     uint              `json:"sub_order_id" gorm:"index;default:0"`
+	ProductId      uint              `json:"product_id"`
+	VariantId      uint              `json:"variant_id" gorm:"default:0"`
+	Sku            string            `json:"sku"`
+	Options        map[string]string `json:"options,omitempty" gorm:"serializer:json"`
+	Name           string            `json:"name" `
+	ImageUrl       string            `json:"image_url"`
+	SellerId       uint              `json:"seller_id"`
+	Price          float64           `json:"price"`
+	Qty            uint              `json:"qty"`
+	Discount       float64           `json:"discount" gorm:"default:0"` // coupon discount taken off the line
+	TaxAmount      float64           `json:"tax_amount" gorm:"default:0"`
+	TaxRate        float64           `json:"tax_rate" gorm:"default:0"` // percent
+	TaxInclusive   bool              `json:"tax_inclusive" gorm:"default:false"`
+	Status         OrderStatus       `json:"status" gorm:"default:paid"`
+	RefundedAmount float64           `json:"refunded_amount" gorm:"default:0"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time         `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// Total is the amount charged for the line, exclusive tax is charged on top of the price
+func (i OrderItem) Total() float64 {
+	total := i.Price*float64(i.Qty) - i.Discount
+	if !i.TaxInclusive {
+		total += i.TaxAmount
+	}
+	return total
 }
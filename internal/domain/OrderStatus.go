@@ -0,0 +1,112 @@
+package domain
+
+type OrderStatus string
+
+const (
+	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusPaid       OrderStatus = "paid"
+	OrderStatusProcessing OrderStatus = "processing"
+	OrderStatusShipped    OrderStatus = "shipped"
+	OrderStatusDelivered  OrderStatus = "delivered"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusRefunded   OrderStatus = "refunded"
+)
+
+// OrderActor is the party requesting an order status change
+type OrderActor string
+
+const (
+	OrderActorBuyer  OrderActor = "buyer"
+	OrderActorSeller OrderActor = "seller"
+	OrderActorSystem OrderActor = "system"
+	OrderActorAdmin  OrderActor = "admin"
+)
+
+// orderTransitions is the single source of truth for the order lifecycle:
+// current status -> next status -> actors allowed to make that move
+var orderTransitions = map[OrderStatus]map[OrderStatus][]OrderActor{
+	OrderStatusPending: {
+		OrderStatusPaid:      {OrderActorSystem},
+		OrderStatusCancelled: {OrderActorBuyer, OrderActorSeller, OrderActorSystem},
+	},
+	OrderStatusPaid: {
+		OrderStatusProcessing: {OrderActorSeller},
+		OrderStatusCancelled:  {OrderActorBuyer, OrderActorSeller, OrderActorSystem},
+		OrderStatusRefunded:   {OrderActorSeller, OrderActorSystem},
+	},
+	OrderStatusProcessing: {
+		OrderStatusShipped:   {OrderActorSeller},
+		OrderStatusCancelled: {OrderActorSeller, OrderActorSystem},
+		OrderStatusRefunded:  {OrderActorSeller, OrderActorSystem},
+	},
+	OrderStatusShipped: {
+		OrderStatusDelivered: {OrderActorBuyer, OrderActorSeller, OrderActorSystem},
+		OrderStatusRefunded:  {OrderActorSystem},
+	},
+	OrderStatusDelivered: {
+		OrderStatusRefunded: {OrderActorSeller, OrderActorSystem},
+	},
+	OrderStatusCancelled: {
+		OrderStatusRefunded: {OrderActorSystem},
+	},
+}
+
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusProcessing, OrderStatusShipped,
+		OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// orderProgress ranks the statuses an order goes through when all goes well
+var orderProgress = map[OrderStatus]int{
+	OrderStatusPending:    0,
+	OrderStatusPaid:       1,
+	OrderStatusProcessing: 2,
+	OrderStatusShipped:    3,
+	OrderStatusDelivered:  4,
+}
+
+// AggregateStatus derives the status of a parent order from its sub-orders. Cancelled and
+// refunded groups are left out, the order is as far as its least advanced group and it is
+// processing as soon as one group moved on.
+func AggregateStatus(statuses []OrderStatus) OrderStatus {
+	lowest, advanced, refunded := OrderStatus(""), false, false
+	for _, s := range statuses {
+		switch s {
+		case OrderStatusCancelled:
+			continue
+		case OrderStatusRefunded:
+			refunded = true
+			continue
+		}
+		if lowest == "" || orderProgress[s] < orderProgress[lowest] {
+			lowest = s
+		}
+		if orderProgress[s] > orderProgress[OrderStatusPaid] {
+			advanced = true
+		}
+	}
+
+	switch {
+	case lowest == "" && refunded:
+		return OrderStatusRefunded
+	case lowest == "":
+		return OrderStatusCancelled
+	case lowest == OrderStatusPaid && advanced:
+		return OrderStatusProcessing
+	}
+	return lowest
+}
+
+// CanTransitionTo reports whether the actor may move an order from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus, actor OrderActor) bool {
+	for _, allowed := range orderTransitions[s][next] {
+		if allowed == actor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+type OrderStatusHistory struct {
+	ID         uint        `json:"id" gorm:"PrimaryKey"`
+	OrderId    uint        `json:"order_id" gorm:"index;not null"`
+	SubOrderId uint        `json:"sub_order_id" gorm:"index;default:0"` // 0 for the parent order
+	FromStatus OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus `json:"to_status" gorm:"not null"`
+	ActorId    uint        `json:"actor_id"` // 0 when changed by the system
+	ActorRole  OrderActor  `json:"actor_role"`
+	Note       string      `json:"note"`
+	CreatedAt  time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// PasswordReset is a one-time code sent to a user who forgot their password. Only a hash of
+// the code is kept, and it stops working once used, expired or guessed wrong too often.
+type PasswordReset struct {
+	ID        uint       `json:"id" gorm:"PrimaryKey"`
+	UserId    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	Attempts  int        `json:"attempts" gorm:"default:0"` // wrong codes tried
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"default:current_timestamp;index"`
+}
@@ -3,25 +3,49 @@ package domain
 import "time"
 
 type Payment struct {
-	ID            uint          `gorm:"PrimaryKey" json:"id"`
-	UserId        uint          `json:"user_id"`
-	CaptureMethod string        `json:"capture_method"`
-	Amount        float64       `json:"amount"`
-	OrderId       string        `json:"order_id"`
-	CustomerId    string        `json:"customer_id"` // stripe customer id
-	PaymentId     string        `json:"payment_id"`  // payment id
-	ClientSecret  string        `json:"client_secret"`
-	Status        PaymentStatus `json:"status" gorm:"default:initial"` // initial, success, failed
-	Response      string        `json:"response"`
-	CreatedAt     time.Time     `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt     time.Time     `json:"updated_at" gorm:"default:current_timestamp"`
+	ID             uint          `gorm:"PrimaryKey" json:"id"`
+	UserId         uint          `json:"user_id"`
+	CaptureMethod  string        `json:"capture_method"`
+	Amount         float64       `json:"amount"`
+	RefundedAmount float64       `json:"refunded_amount" gorm:"default:0"`
+	OrderId        string        `json:"order_id"`
+	CustomerId     string        `json:"customer_id"`             // stripe customer id
+	PaymentId      string        `json:"payment_id" gorm:"index"` // payment id
+	ClientSecret   string        `json:"client_secret"`
+	Status         PaymentStatus `json:"status" gorm:"default:initial"` // initial, success, failed, canceled, partially_refunded, refunded
+	Response       string        `json:"response"`
+	Checkout       *Checkout     `json:"-" gorm:"serializer:json"` // what the amount pays for, the order is built from it
+	CreatedAt      time.Time     `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time     `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// Checkout is the cart as it was priced when the buyer started paying. Cart, catalog, address
+// or tax edits made while the payment is in flight do not change the order it pays for.
+type Checkout struct {
+	Items            []OrderItem     `json:"items"`
+	Shipping         []OrderShipping `json:"shipping"`
+	ShippingAddress  ShippingAddress `json:"shipping_address"`
+	CouponCode       string          `json:"coupon_code"`
+	DiscountAmount   float64         `json:"discount_amount"`   // line and shipping discounts
+	ShippingDiscount float64         `json:"shipping_discount"` // the shipping part of DiscountAmount
+	TaxAmount        float64         `json:"tax_amount"`
+	ShippingAmount   float64         `json:"shipping_amount"`
 }
 
 type PaymentStatus string
 
 const (
-	PaymentStatusInitial PaymentStatus = "initial"
-	PaymentStatusSuccess PaymentStatus = "success"
-	PaymentStatusFailed  PaymentStatus = "failed"
-	PaymentStatusPending PaymentStatus = "pending"
+	PaymentStatusInitial  PaymentStatus = "initial"
+	PaymentStatusSuccess  PaymentStatus = "success"
+	PaymentStatusFailed   PaymentStatus = "failed"
+	PaymentStatusPending  PaymentStatus = "pending"
+	PaymentStatusCanceled PaymentStatus = "canceled"
+
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
 )
+
+// IsCaptured reports whether money was taken for the payment, refunded or not
+func (s PaymentStatus) IsCaptured() bool {
+	return s == PaymentStatusSuccess || s == PaymentStatusPartiallyRefunded || s == PaymentStatusRefunded
+}
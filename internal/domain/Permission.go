@@ -0,0 +1,45 @@
+package domain
+
+import "slices"
+
+// Permission is a single capability a route can require
+type Permission string
+
+const (
+	PermProductsManage      Permission = "products:manage"   // own products, variants, images and stock
+	PermOrdersFulfil        Permission = "orders:fulfil"     // move, ship, cancel and refund own sub-orders, handle returns
+	PermShippingManage      Permission = "shipping:manage"   // own shipping methods
+	PermReviewsReply        Permission = "reviews:reply"     // answer reviews of own products
+	PermCategoriesManage    Permission = "categories:manage" // the global category tree
+	PermCouponsManage       Permission = "coupons:manage"
+	PermTaxesManage         Permission = "taxes:manage"
+	PermRefundsManage       Permission = "refunds:manage"       // refund any order
+	PermUsersManage         Permission = "users:manage"         // assign roles
+	PermNotificationsManage Permission = "notifications:manage" // templates and the delivery log
+)
+
+// AllPermissions is what admins are granted
+var AllPermissions = []Permission{
+	PermProductsManage, PermOrdersFulfil, PermShippingManage, PermReviewsReply,
+	PermCategoriesManage, PermCouponsManage, PermTaxesManage, PermRefundsManage, PermUsersManage,
+	PermNotificationsManage,
+}
+
+// RolePermissions maps every role to its permissions. Any signed in user can shop, so
+// buyers need no permission of their own.
+var RolePermissions = map[string][]Permission{
+	BUYER:   {},
+	SELLER:  {PermProductsManage, PermOrdersFulfil, PermShippingManage, PermReviewsReply},
+	SUPPORT: {PermRefundsManage},
+	ADMIN:   AllPermissions,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether the role grants p
+func HasPermission(role string, p Permission) bool {
+	return slices.Contains(RolePermissions[role], p)
+}
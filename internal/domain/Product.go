@@ -3,14 +3,44 @@ package domain
 import "time"
 
 type Product struct {
-	ID          uint      `json:"id" gorm:"PrimaryKey"`
-	Name        string    `json:"name" gorm:"index"`
-	Description string    `json:"description"`
-	CategoryId  uint      `json:"category_id"`
-	ImageUrl    string    `json:"image_url"`
-	Price       float64   `json:"price"`
-	UserId      int       `json:"user_id"`
-	Stock       int       `json:"stock"`
-	CreatedAt   time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	ID          uint             `json:"id" gorm:"PrimaryKey"`
+	Name        string           `json:"name" gorm:"index"`
+	Description string           `json:"description"`
+	CategoryId  uint             `json:"category_id"`
+	ImageUrl    string           `json:"image_url"` // primary image, snapshotted into carts and orders
+	ImageSizes  ImageSizes       `json:"image_sizes,omitempty" gorm:"serializer:json"`
+	Price       float64          `json:"price"`
+	UserId      int              `json:"user_id"`
+	TaxClass    string           `json:"tax_class" gorm:"default:standard"`
+	Weight      float64          `json:"weight" gorm:"default:0"` // kg, used by weight based shipping
+	Stock       int              `json:"stock"`                   // unused once the product has variants, each variant has its own
+	RatingAvg   float64          `json:"rating_average" gorm:"default:0"`
+	RatingCount int              `json:"rating_count" gorm:"default:0"`
+	Options     []ProductOption  `json:"options,omitempty"`
+	Variants    []ProductVariant `json:"variants,omitempty"`
+	Images      []ProductImage   `json:"images,omitempty"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time        `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+func (p Product) HasVariants() bool {
+	return len(p.Variants) > 0
+}
+
+func (p Product) FindVariant(id uint) (*ProductVariant, bool) {
+	for i := range p.Variants {
+		if p.Variants[i].ID == id {
+			return &p.Variants[i], true
+		}
+	}
+	return nil, false
+}
+
+func (p Product) FindImage(id uint) (*ProductImage, bool) {
+	for i := range p.Images {
+		if p.Images[i].ID == id {
+			return &p.Images[i], true
+		}
+	}
+	return nil, false
 }
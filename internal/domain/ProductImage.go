@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// ImageSizes holds the generated derivatives of an image, size -> format -> url,
+// e.g. sizes["card"]["webp"]
+type ImageSizes map[string]map[string]string
+
+// ProductImage is an uploaded product picture, Product.ImageUrl mirrors the primary one
+type ProductImage struct {
+	ID          uint       `json:"id" gorm:"PrimaryKey"`
+	ProductId   uint       `json:"product_id" gorm:"index;not null"`
+	Url         string     `json:"url" gorm:"not null"`
+	StorageKey  string     `json:"-" gorm:"not null"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	Position    int        `json:"position"`
+	IsPrimary   bool       `json:"is_primary" gorm:"default:false"`
+	Sizes       ImageSizes `json:"sizes" gorm:"serializer:json"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProductOption is one axis a product varies on, e.g. size with values S, M, L
+type ProductOption struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	ProductId uint      `json:"product_id" gorm:"index;not null"`
+	Name      string    `json:"name" gorm:"not null"`
+	Values    []string  `json:"values" gorm:"serializer:json"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ProductVariant is a sellable combination of option values with its own SKU and stock
+type ProductVariant struct {
+	ID        uint              `json:"id" gorm:"PrimaryKey"`
+	ProductId uint              `json:"product_id" gorm:"index;not null"`
+	Sku       string            `json:"sku" gorm:"uniqueIndex;size:64;not null"`
+	Options   map[string]string `json:"options" gorm:"serializer:json"` // option name -> value
+	Price     *float64          `json:"price"`                          // nil uses the product price
+	Stock     int               `json:"stock"`
+	ImageUrl  string            `json:"image_url"`
+	CreatedAt time.Time         `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time         `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// EffectivePrice is the variant price override, or the product price
+func (v ProductVariant) EffectivePrice(p Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return p.Price
+}
+
+// Label renders the option values in option order, e.g. "M / Red"
+func (v ProductVariant) Label(options []ProductOption) string {
+	sorted := append([]ProductOption(nil), options...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Position < sorted[j].Position })
+
+	values := make([]string, 0, len(v.Options))
+	for _, opt := range sorted {
+		if value, ok := v.Options[opt.Name]; ok {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, " / ")
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Refund struct {
+	ID               uint         `json:"id" gorm:"PrimaryKey"`
+	PaymentId        uint         `json:"payment_id" gorm:"index;not null"` // domain.Payment id
+	OrderId          uint         `json:"order_id" gorm:"index;not null"`
+	OrderItemId      uint         `json:"order_item_id"` // 0 for an order level refund
+	SubOrderId       uint         `json:"sub_order_id"`  // set when a whole sub-order is refunded
+	Amount           float64      `json:"amount"`
+	Reason           string       `json:"reason"`
+	Status           RefundStatus `json:"status" gorm:"default:pending"`
+	ProviderRefundId string       `json:"provider_refund_id" gorm:"index"`
+	RequestedBy      uint         `json:"requested_by"`
+	Response         string       `json:"-"`
+	CreatedAt        time.Time    `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt        time.Time    `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+const refundKeyPrefix = "refund_"
+
+// IdempotencyKey identifies the refund to the payment provider, a retried request with the
+// same key never pays out twice and refund webhooks carry it back
+func (r Refund) IdempotencyKey() string {
+	return fmt.Sprintf("%s%d", refundKeyPrefix, r.ID)
+}
+
+// RefundIdFromKey returns the refund id an idempotency key was made from, 0 when the key
+// does not belong to a refund
+func RefundIdFromKey(key string) uint {
+	id, err := strconv.ParseUint(strings.TrimPrefix(key, refundKeyPrefix), 10, 64)
+	if err != nil || !strings.HasPrefix(key, refundKeyPrefix) {
+		return 0
+	}
+	return uint(id)
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnStatusRequested ReturnStatus = "requested"
+	ReturnStatusApproved  ReturnStatus = "approved"
+	ReturnStatusRejected  ReturnStatus = "rejected"
+	ReturnStatusReceived  ReturnStatus = "received"
+	ReturnStatusRefunded  ReturnStatus = "refunded"
+	ReturnStatusCancelled ReturnStatus = "cancelled"
+)
+
+// returnTransitions is the lifecycle of a return:
+// current status -> next status -> actors allowed to make that move
+var returnTransitions = map[ReturnStatus]map[ReturnStatus][]OrderActor{
+	ReturnStatusRequested: {
+		ReturnStatusApproved:  {OrderActorSeller},
+		ReturnStatusRejected:  {OrderActorSeller},
+		ReturnStatusCancelled: {OrderActorBuyer},
+	},
+	ReturnStatusApproved: {
+		ReturnStatusReceived:  {OrderActorSeller},
+		ReturnStatusCancelled: {OrderActorBuyer},
+	},
+	ReturnStatusReceived: {
+		// the goods did not come back as described
+		ReturnStatusRejected: {OrderActorSeller},
+		ReturnStatusRefunded: {OrderActorSeller, OrderActorSystem},
+	},
+}
+
+func (s ReturnStatus) IsValid() bool {
+	switch s {
+	case ReturnStatusRequested, ReturnStatusApproved, ReturnStatusRejected,
+		ReturnStatusReceived, ReturnStatusRefunded, ReturnStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether the return still holds its quantity of the item
+func (s ReturnStatus) IsOpen() bool {
+	return s != ReturnStatusRejected && s != ReturnStatusCancelled
+}
+
+// CanTransitionTo reports whether the actor may move a return from s to next
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus, actor OrderActor) bool {
+	return slices.Contains(returnTransitions[s][next], actor)
+}
+
+type ReturnReason string
+
+const (
+	ReturnReasonDamaged        ReturnReason = "damaged"
+	ReturnReasonDefective      ReturnReason = "defective"
+	ReturnReasonWrongItem      ReturnReason = "wrong_item"
+	ReturnReasonNotAsDescribed ReturnReason = "not_as_described"
+	ReturnReasonNoLongerNeeded ReturnReason = "no_longer_needed"
+	ReturnReasonOther          ReturnReason = "other"
+)
+
+var ReturnReasons = []ReturnReason{
+	ReturnReasonDamaged, ReturnReasonDefective, ReturnReasonWrongItem,
+	ReturnReasonNotAsDescribed, ReturnReasonNoLongerNeeded, ReturnReasonOther,
+}
+
+func (r ReturnReason) IsValid() bool {
+	return slices.Contains(ReturnReasons, r)
+}
+
+// ReturnRequest is a buyer asking to send back some units of a delivered order item (RMA)
+type ReturnRequest struct {
+	ID           uint          `json:"id" gorm:"PrimaryKey"`
+	OrderId      uint          `json:"order_id" gorm:"index;not null"`
+	SubOrderId   uint          `json:"sub_order_id" gorm:"index"`
+	OrderItemId  uint          `json:"order_item_id" gorm:"index;not null"`
+	BuyerId      uint          `json:"buyer_id" gorm:"index;not null"`
+	SellerId     uint          `json:"seller_id" gorm:"index;not null"`
+	Qty          uint          `json:"qty"`
+	Reason       ReturnReason  `json:"reason" gorm:"not null"`
+	Note         string        `json:"note"`
+	Status       ReturnStatus  `json:"status" gorm:"default:requested;index"`
+	Restocked    bool          `json:"restocked" gorm:"default:false"`
+	RefundId     uint          `json:"refund_id"` // 0 until refunded
+	RefundAmount float64       `json:"refund_amount" gorm:"default:0"`
+	Photos       []ReturnPhoto `json:"photos,omitempty" gorm:"foreignKey:ReturnId"`
+	Events       []ReturnEvent `json:"events,omitempty" gorm:"foreignKey:ReturnId"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time     `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ReturnPhoto is a picture of the goods uploaded with the request
+type ReturnPhoto struct {
+	ID          uint      `json:"id" gorm:"PrimaryKey"`
+	ReturnId    uint      `json:"return_id" gorm:"index;not null"`
+	Url         string    `json:"url"`
+	StorageKey  string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+// ReturnEvent is one step of a return, FromStatus is empty for the request itself
+type ReturnEvent struct {
+	ID         uint         `json:"id" gorm:"PrimaryKey"`
+	ReturnId   uint         `json:"return_id" gorm:"index;not null"`
+	FromStatus ReturnStatus `json:"from_status"`
+	ToStatus   ReturnStatus `json:"to_status"`
+	ActorId    uint         `json:"actor_id"` // 0 when made by the system
+	ActorRole  OrderActor   `json:"actor_role"`
+	Note       string       `json:"note"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// ReviewFlagThreshold is how many abuse flags hide a review until it is looked at
+const ReviewFlagThreshold = 3
+
+// Review is a buyer's rating of a product they received, one per buyer and product
+type Review struct {
+	ID           uint       `json:"id" gorm:"PrimaryKey"`
+	ProductId    uint       `json:"product_id" gorm:"uniqueIndex:idx_review_user_product;index;not null"`
+	UserId       uint       `json:"user_id" gorm:"uniqueIndex:idx_review_user_product;not null"`
+	OrderItemId  uint       `json:"order_item_id"` // the delivered purchase that allowed the review
+	ReviewerName string     `json:"reviewer_name"`
+	Rating       int        `json:"rating" gorm:"not null"` // 1 to 5
+	Title        string     `json:"title"`
+	Body         string     `json:"body"`
+	SellerReply  string     `json:"seller_reply"`
+	RepliedAt    *time.Time `json:"replied_at"`
+	FlagCount    int        `json:"-" gorm:"default:0"`
+	Hidden       bool       `json:"-" gorm:"default:false"` // hidden reviews are left out of listings and ratings
+	CreatedAt    time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ReviewFlag records that a user reported a review as abusive, once per user
+type ReviewFlag struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	ReviewId  uint      `json:"review_id" gorm:"uniqueIndex:idx_review_flag_user;not null"`
+	UserId    uint      `json:"user_id" gorm:"uniqueIndex:idx_review_flag_user;not null"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
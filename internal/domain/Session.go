@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// Session is one login of a user. Every refresh token rotated from the login belongs to it,
+// so revoking the session logs out the whole token family at once.
+type Session struct {
+	ID        string     `json:"id" gorm:"primaryKey;size:32"`
+	UserId    uint       `json:"user_id" gorm:"index;not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+func (s Session) IsActive() bool {
+	return s.RevokedAt == nil
+}
+
+// RefreshToken is one link of a session's rotation chain. Only the SHA-256 of the token is
+// kept, and a token can be exchanged once; presenting it again means it leaked.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"PrimaryKey"`
+	SessionId string     `json:"session_id" gorm:"index;size:32;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	UsedAt    *time.Time `json:"used_at"` // set once exchanged for a new token
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+}
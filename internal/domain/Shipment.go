@@ -0,0 +1,75 @@
+package domain
+
+import "time"
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusShipped        ShipmentStatus = "shipped"
+	ShipmentStatusInTransit      ShipmentStatus = "in_transit"
+	ShipmentStatusOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentStatusFailed         ShipmentStatus = "failed" // delivery attempt failed, the carrier tries again
+	ShipmentStatusDelivered      ShipmentStatus = "delivered"
+)
+
+func (s ShipmentStatus) IsValid() bool {
+	switch s {
+	case ShipmentStatusShipped, ShipmentStatusInTransit, ShipmentStatusOutForDelivery,
+		ShipmentStatusFailed, ShipmentStatusDelivered:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether a shipment in s can report next. Delivered is final and a
+// shipment never goes back to shipped, any other move follows what the carrier reports.
+func (s ShipmentStatus) CanTransitionTo(next ShipmentStatus) bool {
+	if s == ShipmentStatusDelivered || next == ShipmentStatusShipped {
+		return false
+	}
+	return next.IsValid()
+}
+
+// Shipment is a parcel a seller sent for some or all of their items in an order
+type Shipment struct {
+	ID             uint            `json:"id" gorm:"PrimaryKey"`
+	OrderId        uint            `json:"order_id" gorm:"index;not null"`
+	SubOrderId     uint            `json:"sub_order_id" gorm:"index"`
+	SellerId       uint            `json:"seller_id" gorm:"index;not null"`
+	Carrier        string          `json:"carrier" gorm:"not null"`
+	TrackingNumber string          `json:"tracking_number" gorm:"not null"`
+	TrackingUrl    string          `json:"tracking_url"`
+	Status         ShipmentStatus  `json:"status" gorm:"default:shipped"`
+	ShippedAt      time.Time       `json:"shipped_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	Items          []ShipmentItem  `json:"items"`
+	Events         []ShipmentEvent `json:"events"` // the tracking timeline, oldest first
+	CreatedAt      time.Time       `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ShipmentItem puts an order item in a shipment, an item ships in a single shipment
+type ShipmentItem struct {
+	ID          uint `json:"id" gorm:"PrimaryKey"`
+	ShipmentId  uint `json:"shipment_id" gorm:"index;not null"`
+	OrderItemId uint `json:"order_item_id" gorm:"uniqueIndex;not null"`
+}
+
+// ShipmentEvent is one delivery status update of a shipment
+type ShipmentEvent struct {
+	ID         uint           `json:"id" gorm:"PrimaryKey"`
+	ShipmentId uint           `json:"shipment_id" gorm:"index;not null"`
+	Status     ShipmentStatus `json:"status" gorm:"not null"`
+	Location   string         `json:"location"`
+	Note       string         `json:"note"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+func (s Shipment) OrderItemIds() []uint {
+	ids := make([]uint, 0, len(s.Items))
+	for _, item := range s.Items {
+		ids = append(ids, item.OrderItemId)
+	}
+	return ids
+}
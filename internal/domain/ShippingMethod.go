@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+type ShippingRateType string
+
+const (
+	ShippingRateFlat        ShippingRateType = "flat"         // Rate per shipment
+	ShippingRateWeightBased ShippingRateType = "weight_based" // Rate plus PerKg for every kg
+	ShippingRateFreeOver    ShippingRateType = "free_over"    // Rate, free once the seller's items reach FreeAbove
+)
+
+func (t ShippingRateType) IsValid() bool {
+	switch t {
+	case ShippingRateFlat, ShippingRateWeightBased, ShippingRateFreeOver:
+		return true
+	}
+	return false
+}
+
+// ShippingMethod is a way a seller ships their items, e.g. standard or express
+type ShippingMethod struct {
+	ID            uint             `json:"id" gorm:"PrimaryKey"`
+	SellerId      uint             `json:"seller_id" gorm:"index;not null"`
+	Name          string           `json:"name" gorm:"not null"`
+	Description   string           `json:"description"`
+	Type          ShippingRateType `json:"type" gorm:"not null"`
+	Rate          float64          `json:"rate"`
+	PerKg         float64          `json:"per_kg"`
+	FreeAbove     float64          `json:"free_above"`
+	Countries     []string         `json:"countries" gorm:"serializer:json"` // upper case, empty ships everywhere
+	EstimatedDays int              `json:"estimated_days"`
+	Active        bool             `json:"active" gorm:"default:true"`
+	CreatedAt     time.Time        `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt     time.Time        `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+func (m ShippingMethod) ShipsTo(country string) bool {
+	if len(m.Countries) == 0 {
+		return true
+	}
+	for _, c := range m.Countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cost prices a shipment of items worth subtotal weighing weight kg
+func (m ShippingMethod) Cost(subtotal float64, weight float64) float64 {
+	cost := m.Rate
+	switch m.Type {
+	case ShippingRateWeightBased:
+		cost += m.PerKg * weight
+	case ShippingRateFreeOver:
+		if subtotal >= m.FreeAbove {
+			cost = 0
+		}
+	}
+	return math.Round(cost*100) / 100
+}
+
+// CartShipping is the method a buyer picked for the items of one seller
+type CartShipping struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	UserId    uint      `json:"user_id" gorm:"uniqueIndex:idx_cart_shipping_seller;not null"`
+	SellerId  uint      `json:"seller_id" gorm:"uniqueIndex:idx_cart_shipping_seller;not null"`
+	MethodId  uint      `json:"method_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// OrderShipping is the method and charge an order was shipped with for one seller
+type OrderShipping struct {
+	ID         uint             `json:"id" gorm:"PrimaryKey"`
+	OrderId    uint             `json:"order_id" gorm:"index;not null"`
+	SubOrderId uint             `json:"sub_order_id" gorm:"index;default:0"`
+	SellerId   uint             `json:"seller_id"`
+	MethodId   uint             `json:"method_id"`
+	Name       string           `json:"name"`
+	Type       ShippingRateType `json:"type"`
+	Cost       float64          `json:"cost"`
+	CreatedAt  time.Time        `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+// ShippingAddress is the delivery address copied onto an order, later profile edits do not change it
+type ShippingAddress struct {
+	AddressLine1 string `json:"address_line1"`
+	AddressLine2 string `json:"address_line2"`
+	City         string `json:"city"`
+	Region       string `json:"region"`
+	PostCode     uint   `json:"postCode"`
+	Country      string `json:"country"`
+}
+
+func NewShippingAddress(a Address) ShippingAddress {
+	return ShippingAddress{
+		AddressLine1: a.AddressLine1,
+		AddressLine2: a.AddressLine2,
+		City:         a.City,
+		Region:       a.Region,
+		PostCode:     a.PostCode,
+		Country:      a.Country,
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// StockMovement is one entry of a product's stock ledger. Summing Delta over all entries
+// of a product gives its current Product.Stock.
+type StockMovement struct {
+	ID        uint              `json:"id" gorm:"PrimaryKey"`
+	ProductId uint              `json:"product_id" gorm:"index;not null"`
+	VariantId uint              `json:"variant_id" gorm:"index;default:0"` // 0 when the product has no variants
+	Type      StockMovementType `json:"type" gorm:"not null"`
+	Qty       uint              `json:"qty"`     // units concerned by the movement
+	Delta     int               `json:"delta"`   // change applied to Product.Stock
+	Balance   int               `json:"balance"` // Product.Stock after the movement
+	Reason    string            `json:"reason"`
+	Reference string            `json:"reference" gorm:"index"` // order reference when the movement comes from checkout
+	ActorId   uint              `json:"actor_id"`               // 0 when made by the system
+	CreatedAt time.Time         `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+type StockMovementType string
+
+const (
+	StockMovementAdjustment  StockMovementType = "adjustment"
+	StockMovementSale        StockMovementType = "sale"
+	StockMovementReservation StockMovementType = "reservation"
+	StockMovementRelease     StockMovementType = "release"
+	StockMovementReturn      StockMovementType = "return"
+	StockMovementCancel      StockMovementType = "cancellation"
+	StockMovementImport      StockMovementType = "import"
+)
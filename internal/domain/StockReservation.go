@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// StockReservation holds stock for a pending payment. Product.Stock is decremented when
+// the reservation is made, so it always shows what is still available to sell.
+type StockReservation struct {
+	ID        uint              `json:"id" gorm:"PrimaryKey"`
+	OrderRef  string            `json:"order_ref" gorm:"index;size:32;not null"` // Payment.OrderId / Order.OrderRefNumber
+	UserId    uint              `json:"user_id" gorm:"index"`
+	ProductId uint              `json:"product_id" gorm:"index;not null"`
+	VariantId uint              `json:"variant_id" gorm:"index;default:0"` // 0 when the product has no variants
+	Qty       uint              `json:"qty"`
+	Status    ReservationStatus `json:"status" gorm:"default:active;index"`
+	ExpiresAt time.Time         `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time         `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time         `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "active"
+	ReservationStatusCommitted ReservationStatus = "committed" // sold, the order was created
+	ReservationStatusReleased  ReservationStatus = "released"  // stock given back
+)
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// SubOrder is the fulfilment group of one seller in a buyer's order. Sellers only work on
+// their own sub-order and its status moves independently of the other sellers' groups.
+type SubOrder struct {
+	ID             uint           `json:"id" gorm:"PrimaryKey"`
+	OrderId        uint           `json:"order_id" gorm:"uniqueIndex:idx_sub_order_seller;not null"`
+	SellerId       uint           `json:"seller_id" gorm:"uniqueIndex:idx_sub_order_seller;not null"`
+	Status         OrderStatus    `json:"status" gorm:"default:paid;index"`
+	Subtotal       float64        `json:"subtotal"`
+	DiscountAmount float64        `json:"discount_amount" gorm:"default:0"` // item and shipping discounts
+	TaxAmount      float64        `json:"tax_amount" gorm:"default:0"`
+	ShippingAmount float64        `json:"shipping_amount" gorm:"default:0"`
+	Total          float64        `json:"total"`
+	RefundedAmount float64        `json:"refunded_amount" gorm:"default:0"` // sub-order and item refunds
+	Items          []OrderItem    `json:"items,omitempty"`
+	Shipping       *OrderShipping `json:"shipping,omitempty"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// FindSubOrder returns the fulfilment group of a seller
+func (o Order) FindSubOrder(sellerId uint) (*SubOrder, bool) {
+	for i := range o.SubOrders {
+		if o.SubOrders[i].SellerId == sellerId {
+			return &o.SubOrders[i], true
+		}
+	}
+	return nil, false
+}
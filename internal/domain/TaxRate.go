@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultTaxClass is used for products that do not name one
+const DefaultTaxClass = "standard"
+
+// TaxRate is a configurable rate for a tax class in a country, optionally narrowed down to
+// a region and a postcode prefix. The most specific matching rate applies.
+type TaxRate struct {
+	ID             uint      `json:"id" gorm:"PrimaryKey"`
+	Name           string    `json:"name"`                                  // e.g. VAT, GST, Sales tax
+	Country        string    `json:"country" gorm:"index;size:64;not null"` // stored upper case
+	Region         string    `json:"region"`                                // empty matches the whole country
+	PostcodePrefix string    `json:"postcode_prefix"`                       // digits without leading zeros, empty matches every postcode
+	TaxClass       string    `json:"tax_class" gorm:"default:standard;not null"`
+	Rate           float64   `json:"rate"`      // percent
+	Inclusive      bool      `json:"inclusive"` // prices already contain the tax
+	CreatedAt      time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// Matches reports whether the rate covers an address and tax class
+func (r TaxRate) Matches(country, region, postcode, taxClass string) bool {
+	if !strings.EqualFold(r.Country, country) || r.TaxClass != taxClass {
+		return false
+	}
+	if r.Region != "" && !strings.EqualFold(r.Region, region) {
+		return false
+	}
+	return strings.HasPrefix(postcode, r.PostcodePrefix)
+}
+
+// Specificity ranks matching rates, a postcode beats a region which beats the country
+func (r TaxRate) Specificity() int {
+	score := 0
+	if r.Region != "" {
+		score++
+	}
+	if r.PostcodePrefix != "" {
+		score += 100 + len(r.PostcodePrefix)
+	}
+	return score
+}
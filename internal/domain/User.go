@@ -2,26 +2,52 @@ package domain
 
 import "time"
 
+// user roles, see RolePermissions for what each may do
 const (
-	SELLER = "seller"
-	BUYER  = "buyer"
+	SELLER  = "seller"
+	BUYER   = "buyer"
+	ADMIN   = "admin"
+	SUPPORT = "support"
 )
 
+// VerifyChannel is where a verification code is sent to
+type VerifyChannel string
+
+const (
+	VerifyChannelEmail VerifyChannel = "email"
+	VerifyChannelPhone VerifyChannel = "phone"
+)
+
+func (c VerifyChannel) IsValid() bool {
+	return c == VerifyChannelEmail || c == VerifyChannelPhone
+}
+
 type User struct {
-	ID        uint      `json:"id" gorm:"PrimaryKey"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email" gorm:"index;unique;not null"`
-	Phone     string    `json:"phone"`
-	Password  string    `json:"password"`
-	Code      string    `json:"code"`
-	Expiry    time.Time `json:"expiry"`
-	Address   Address   `json:"address"` // relation
-	Cart      Cart      `json:"cart"`    // realtion
-	Orders    []Order   `json:"order"`   //relation
-	Payment   []Payment `json:"payment"` // relation
-	Verified  bool      `json:"verified" gorm:"default:false"`
-	UserType  string    `json:"user_type" gorm:"default:buyer"`
-	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	ID            uint          `json:"id" gorm:"PrimaryKey"`
+	FirstName     string        `json:"first_name"`
+	LastName      string        `json:"last_name"`
+	Email         string        `json:"email" gorm:"index;unique;not null"`
+	Phone         string        `json:"phone"`
+	Password      string        `json:"password"`
+	Code          string        `json:"code"`
+	CodeChannel   VerifyChannel `json:"code_channel"` // where the pending code was sent
+	Expiry        time.Time     `json:"expiry"`
+	Address       Address       `json:"address"`                       // relation
+	Cart          Cart          `json:"cart"`                          // realtion
+	Orders        []Order       `json:"order"`                         //relation
+	Payment       []Payment     `json:"payment"`                       // relation
+	Verified      bool          `json:"verified" gorm:"default:false"` // at least one channel is verified
+	EmailVerified bool          `json:"email_verified" gorm:"default:false"`
+	PhoneVerified bool          `json:"phone_verified" gorm:"default:false"`
+	UserType      string        `json:"user_type" gorm:"default:buyer"`
+	CreatedAt     time.Time     `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt     time.Time     `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// IsVerified tells whether the user has confirmed the given channel
+func (u User) IsVerified(c VerifyChannel) bool {
+	if c == VerifyChannelEmail {
+		return u.EmailVerified
+	}
+	return u.PhoneVerified
 }
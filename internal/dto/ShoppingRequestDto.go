@@ -1,14 +1,18 @@
 package dto
 
+import "go-ecommerce-app/internal/domain"
+
 type CreateCartRequest struct {
 	ProductId uint `json:"product_id"`
+	VariantId uint `json:"variant_id"` // required when the product has variants
 	Qty       uint `json:"qty"`
 }
 
 type CreatePaymentRequest struct {
-	OrderId      string  `json:"order_id"`
-	PaymentId    string  `json:"payment_id"`
-	ClientSecret string  `json:"client"`
-	Amount       float64 `json:"amount"`
-	UserId       uint    `json:"user_id"`
+	OrderId      string           `json:"order_id"`
+	PaymentId    string           `json:"payment_id"`
+	ClientSecret string           `json:"client"`
+	Amount       float64          `json:"amount"`
+	UserId       uint             `json:"user_id"`
+	Checkout     *domain.Checkout `json:"-"`
 }
@@ -2,7 +2,18 @@ package dto
 
 type CreateCategoryRequest struct {
 	Name         string `json:"name"`
-	ParentId     uint   `json:"parent_id"`
+	ParentId     *uint  `json:"parent_id"` // omit to keep the current parent, 0 for a top level category
 	ImageUrl     string `json:"image_url"`
 	DisplayOrder int    `json:"display_order"`
 }
+
+type CategoryDeletePolicy string
+
+const (
+	// CategoryDeleteRefuse fails when the category still has children or products
+	CategoryDeleteRefuse CategoryDeletePolicy = "refuse"
+	// CategoryDeleteReparent moves children and products up to the deleted category's parent
+	CategoryDeleteReparent CategoryDeletePolicy = "reparent"
+	// CategoryDeleteCascade deletes the whole subtree; its products are left uncategorised
+	CategoryDeleteCascade CategoryDeletePolicy = "cascade"
+)
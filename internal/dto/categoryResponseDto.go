@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"go-ecommerce-app/internal/domain"
+	"time"
+)
+
+type CategoryNode struct {
+	ID           uint              `json:"id"`
+	Name         string            `json:"name"`
+	ParentId     uint              `json:"parent_id"`
+	ImageUrl     string            `json:"image_url"`
+	ImageSizes   domain.ImageSizes `json:"image_sizes,omitempty"`
+	DisplayOrder int               `json:"display_order"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Children     []*CategoryNode   `json:"children"`
+}
@@ -0,0 +1,110 @@
+package dto
+
+import (
+	"go-ecommerce-app/internal/domain"
+	"math"
+	"time"
+)
+
+type CouponRequest struct {
+	Code         string            `json:"code"`
+	Description  string            `json:"description"`
+	Type         domain.CouponType `json:"type"`
+	Value        float64           `json:"value"`
+	MaxDiscount  float64           `json:"max_discount"`
+	BuyQty       int               `json:"buy_qty"`
+	GetQty       int               `json:"get_qty"`
+	MinSpend     float64           `json:"min_spend"`
+	StartsAt     *time.Time        `json:"starts_at"`
+	EndsAt       *time.Time        `json:"ends_at"`
+	UsageLimit   int               `json:"usage_limit"`
+	PerUserLimit int               `json:"per_user_limit"`
+	CategoryIds  []uint            `json:"category_ids"`
+	SellerIds    []uint            `json:"seller_ids"`
+	Active       *bool             `json:"active"` // omit to keep the current value, new coupons are active
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+}
+
+type CouponList struct {
+	Coupons    []domain.Coupon    `json:"coupons"`
+	Pagination PaginationResponse `json:"pagination"`
+}
+
+// LineDiscount is the part of a discount taken off one cart item
+type LineDiscount struct {
+	CartItemId uint    `json:"cart_item_id"`
+	ProductId  uint    `json:"product_id"`
+	Name       string  `json:"name"`
+	Amount     float64 `json:"amount"`
+}
+
+type DiscountBreakdown struct {
+	Code        string            `json:"code"`
+	Type        domain.CouponType `json:"type"`
+	Description string            `json:"description"`
+	Amount      float64           `json:"amount"`
+	Lines       []LineDiscount    `json:"lines,omitempty"` // empty for shipping discounts
+}
+
+// TaxLine is the tax computed for one cart item
+type TaxLine struct {
+	CartItemId uint    `json:"cart_item_id"`
+	Name       string  `json:"name"`
+	Rate       float64 `json:"rate"` // percent
+	Inclusive  bool    `json:"inclusive"`
+	Taxable    float64 `json:"taxable"` // line amount after discounts
+	Amount     float64 `json:"amount"`
+}
+
+// CartPricing is the itemised price of a cart, Total is what the buyer is charged
+type CartPricing struct {
+	Subtotal         float64             `json:"subtotal"`
+	Discounts        []DiscountBreakdown `json:"discounts"`
+	DiscountTotal    float64             `json:"discount_total"`
+	Shipping         float64             `json:"shipping"`
+	ShippingLines    []ShippingLine      `json:"shipping_lines"`
+	ShippingError    string              `json:"shipping_error,omitempty"` // checkout is blocked until it is resolved
+	ShippingDiscount float64             `json:"shipping_discount"`
+	Tax              float64             `json:"tax"` // inclusive and exclusive tax
+	TaxLines         []TaxLine           `json:"tax_lines"`
+	Total            float64             `json:"total"`
+	CouponCode       string              `json:"coupon_code,omitempty"`
+	CouponError      string              `json:"coupon_error,omitempty"` // why the applied coupon does not apply
+}
+
+// UpdateTotal recomputes Total, exclusive tax is added while inclusive tax is already in the prices
+func (p *CartPricing) UpdateTotal() {
+	total := p.Subtotal - p.DiscountTotal + p.Shipping - p.ShippingDiscount
+	for _, t := range p.TaxLines {
+		if !t.Inclusive {
+			total += t.Amount
+		}
+	}
+	p.Total = math.Round(max(total, 0)*100) / 100
+}
+
+// ItemTax returns the tax line of a cart item, the zero line when it is not taxed
+func (p CartPricing) ItemTax(cartItemId uint) TaxLine {
+	for _, t := range p.TaxLines {
+		if t.CartItemId == cartItemId {
+			return t
+		}
+	}
+	return TaxLine{CartItemId: cartItemId}
+}
+
+// ItemDiscount sums the discounts taken off a cart item
+func (p CartPricing) ItemDiscount(cartItemId uint) float64 {
+	var total float64
+	for _, d := range p.Discounts {
+		for _, l := range d.Lines {
+			if l.CartItemId == cartItemId {
+				total += l.Amount
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,40 @@
+package dto
+
+import "go-ecommerce-app/internal/domain"
+
+// NotificationTemplateRequest creates or replaces the template of an event, channel and locale
+type NotificationTemplateRequest struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"` // omit for a template shared by every channel
+	Locale  string `json:"locale"`  // defaults to en
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+type NotificationPreferenceRequest struct {
+	SMS        bool   `json:"sms"`
+	Email      bool   `json:"email"`
+	InApp      bool   `json:"in_app"`
+	Webhook    bool   `json:"webhook"`
+	WebhookUrl string `json:"webhook_url"`
+	Locale     string `json:"locale"`
+}
+
+type NotificationList struct {
+	Notifications []domain.Notification `json:"notifications"`
+	Pagination    PaginationResponse    `json:"pagination"`
+}
+
+// DeliveryFilter narrows the delivery log, zero values match everything
+type DeliveryFilter struct {
+	UserId  uint
+	Event   domain.NotificationEvent
+	Channel domain.NotificationChannel
+	Status  domain.DeliveryStatus
+}
+
+type DeliveryList struct {
+	Deliveries []domain.NotificationDelivery `json:"deliveries"`
+	Pagination PaginationResponse            `json:"pagination"`
+}
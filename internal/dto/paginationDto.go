@@ -0,0 +1,34 @@
+package dto
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+type PaginationRequest struct {
+	Page  int `query:"page" json:"page"`
+	Limit int `query:"limit" json:"limit"`
+}
+
+// Normalize clamps page and limit to sane bounds
+func (p *PaginationRequest) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		p.Limit = MaxPageLimit
+	}
+}
+
+func (p PaginationRequest) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+type PaginationResponse struct {
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Total int64 `json:"total"`
+}
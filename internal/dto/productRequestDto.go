@@ -7,8 +7,77 @@ type CreateProductRequest struct {
 	ImageUrl    string  `json:"image_url"`
 	Price       float64 `json:"price"`
 	Stock       int     `json:"stock"`
+	TaxClass    string  `json:"tax_class"` // defaults to standard
+	Weight      float64 `json:"weight"`    // kg
 }
 
 type UpdateStockRequest struct {
-	Stock int `json:"stock"`
+	Stock  int    `json:"stock"`
+	Reason string `json:"reason"`
+}
+
+const (
+	ProductSortNewest    = "newest"
+	ProductSortPriceAsc  = "price_asc"
+	ProductSortPriceDesc = "price_desc"
+	ProductSortName      = "name"
+)
+
+type ProductFilter struct {
+	PaginationRequest
+	Query      string  `json:"q"` // matched against name and description
+	CategoryId uint    `json:"category_id"`
+	MinPrice   float64 `json:"min_price"`
+	MaxPrice   float64 `json:"max_price"`
+	SellerId   uint    `json:"seller_id"`
+	InStock    bool    `json:"in_stock"`
+	Sort       string  `json:"sort"`
+}
+
+type ProductOptionInput struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type ProductOptionsRequest struct {
+	Options []ProductOptionInput `json:"options"` // in display order
+}
+
+type ProductVariantRequest struct {
+	Sku      string            `json:"sku"`
+	Options  map[string]string `json:"options"`
+	Price    *float64          `json:"price"` // omit to use the product price
+	Stock    int               `json:"stock"` // initial stock, only used on create
+	ImageUrl string            `json:"image_url"`
+}
+
+type ReorderImagesRequest struct {
+	ImageIds []uint `json:"image_ids"` // new display order
+}
+
+type ReviewRequest struct {
+	Rating int    `json:"rating"` // 1 to 5
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type ReviewReplyRequest struct {
+	Reply string `json:"reply"`
+}
+
+type FlagReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Review sort options
+const (
+	ReviewSortNewest     = "newest"
+	ReviewSortOldest     = "oldest"
+	ReviewSortRatingDesc = "rating_desc"
+	ReviewSortRatingAsc  = "rating_asc"
+)
+
+type ReviewFilter struct {
+	PaginationRequest
+	Sort string `json:"sort"`
 }
@@ -0,0 +1,18 @@
+package dto
+
+import "go-ecommerce-app/internal/domain"
+
+type StockMovementList struct {
+	Movements  []domain.StockMovement `json:"movements"`
+	Pagination PaginationResponse     `json:"pagination"`
+}
+
+type ProductList struct {
+	Products   []*domain.Product  `json:"products"`
+	Pagination PaginationResponse `json:"pagination"`
+}
+
+type ReviewList struct {
+	Reviews    []domain.Review    `json:"reviews"`
+	Pagination PaginationResponse `json:"pagination"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "go-ecommerce-app/internal/domain"
+
+// CreateReturnRequest is sent as JSON, or as multipart form data with the photos in "photos"
+type CreateReturnRequest struct {
+	OrderItemId uint   `json:"order_item_id" form:"order_item_id"`
+	Qty         uint   `json:"qty" form:"qty"` // omit to return the whole line
+	Reason      string `json:"reason" form:"reason"`
+	Note        string `json:"note" form:"note"`
+}
+
+type UpdateReturnStatusRequest struct {
+	Status  string  `json:"status"`
+	Note    string  `json:"note"`
+	Restock bool    `json:"restock"` // when received, put the units back on sale
+	Amount  float64 `json:"amount"`  // when refunded, omit to refund the returned share of the item
+}
+
+// ReturnFilter narrows a return listing, zero values match everything
+type ReturnFilter struct {
+	BuyerId  uint
+	SellerId uint
+	Status   domain.ReturnStatus
+}
+
+type ReturnList struct {
+	Returns    []domain.ReturnRequest `json:"returns"`
+	Pagination PaginationResponse     `json:"pagination"`
+}
@@ -0,0 +1,52 @@
+package dto
+
+import "go-ecommerce-app/internal/domain"
+
+type ShippingMethodRequest struct {
+	Name          string                  `json:"name"`
+	Description   string                  `json:"description"`
+	Type          domain.ShippingRateType `json:"type"`
+	Rate          float64                 `json:"rate"`
+	PerKg         float64                 `json:"per_kg"`
+	FreeAbove     float64                 `json:"free_above"`
+	Countries     []string                `json:"countries"` // empty ships everywhere
+	EstimatedDays int                     `json:"estimated_days"`
+	Active        *bool                   `json:"active"` // omit to keep the current value, new methods are active
+}
+
+// SelectShippingRequest picks one method for each seller in the cart
+type SelectShippingRequest struct {
+	MethodIds []uint `json:"method_ids"`
+}
+
+// ShippingLine is the shipping charged for the items of one seller
+type ShippingLine struct {
+	SellerId uint                    `json:"seller_id"`
+	MethodId uint                    `json:"method_id"`
+	Name     string                  `json:"name"`
+	Type     domain.ShippingRateType `json:"type"`
+	Cost     float64                 `json:"cost"`
+}
+
+// ShippingQuote prices shipping for a cart, Error tells why the cart cannot be shipped yet
+type ShippingQuote struct {
+	Lines []ShippingLine
+	Total float64
+	Error string
+}
+
+type ShippingOption struct {
+	MethodId      uint                    `json:"method_id"`
+	Name          string                  `json:"name"`
+	Description   string                  `json:"description"`
+	Type          domain.ShippingRateType `json:"type"`
+	EstimatedDays int                     `json:"estimated_days"`
+	Cost          float64                 `json:"cost"`
+}
+
+// SellerShippingOptions lists the methods that can ship a seller's cart items to the buyer
+type SellerShippingOptions struct {
+	SellerId         uint             `json:"seller_id"`
+	SelectedMethodId uint             `json:"selected_method_id"`
+	Options          []ShippingOption `json:"options"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+import "go-ecommerce-app/internal/domain"
+
+type TaxRateRequest struct {
+	Name           string  `json:"name"`
+	Country        string  `json:"country"`
+	Region         string  `json:"region"`
+	PostcodePrefix string  `json:"postcode_prefix"`
+	TaxClass       string  `json:"tax_class"` // defaults to standard
+	Rate           float64 `json:"rate"`      // percent
+	Inclusive      bool    `json:"inclusive"`
+}
+
+type TaxRateList struct {
+	TaxRates   []domain.TaxRate   `json:"tax_rates"`
+	Pagination PaginationResponse `json:"pagination"`
+}
@@ -0,0 +1,42 @@
+package dto
+
+import "time"
+
+type SellerOrderFilter struct {
+	PaginationRequest
+	Status string    `json:"status"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason"` // one of domain.CancelReasons for the caller
+	Note   string `json:"note"`
+}
+
+type CreateRefundRequest struct {
+	OrderItemId uint    `json:"order_item_id"` // omit to refund at order level
+	Amount      float64 `json:"amount"`        // omit to refund everything still refundable
+	Reason      string  `json:"reason"`
+}
+
+type CreateShipmentRequest struct {
+	OrderItemIds   []uint     `json:"order_item_ids"` // omit to ship every item of the seller not shipped yet
+	Carrier        string     `json:"carrier"`
+	TrackingNumber string     `json:"tracking_number"`
+	TrackingUrl    string     `json:"tracking_url"`
+	ShippedAt      *time.Time `json:"shipped_at"` // defaults to now
+	Note           string     `json:"note"`
+}
+
+type UpdateShipmentStatusRequest struct {
+	Status     string     `json:"status"`
+	Location   string     `json:"location"`
+	Note       string     `json:"note"`
+	OccurredAt *time.Time `json:"occurred_at"` // defaults to now
+}
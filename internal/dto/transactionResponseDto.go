@@ -1,16 +1,27 @@
 package dto
 
+import "time"
+
 type SellerOrderDetails struct {
-	OrderRefNumber  int    `gorm:"PrimaryKey" json:"id"`
-	OrderStatus     int    `json:"order_status"`
-	CreatedAt       string `json:"created_at"`
-	OrderItemId     uint   `json:"order_item_id"`
-	ProductId       uint   `json:"product_id"`
-	Name            string `json:"name"`
-	ImageUrl        string `json:"image_url"`
-	Qty             uint   `json:"qty"`
-	CustomerName    string `json:"customer_name"`
-	CustomerEmail   string `json:"customer_email"`
-	CustomerPhone   string `json:"customer_phone"`
-	CustomerAddress string `json:"customer_address"`
+	OrderId         uint      `json:"order_id"`
+	OrderRefNumber  string    `json:"order_ref_number"`
+	SubOrderId      uint      `json:"sub_order_id"`
+	OrderStatus     string    `json:"order_status"`
+	CreatedAt       time.Time `json:"created_at"`
+	OrderItemId     uint      `json:"order_item_id"`
+	ProductId       uint      `json:"product_id"`
+	Name            string    `json:"name"`
+	ImageUrl        string    `json:"image_url"`
+	Price           float64   `json:"price"`
+	Qty             uint      `json:"qty"`
+	Discount        float64   `json:"discount"`
+	CustomerName    string    `json:"customer_name"`
+	CustomerEmail   string    `json:"customer_email"`
+	CustomerPhone   string    `json:"customer_phone"`
+	CustomerAddress string    `json:"customer_address"`
+}
+
+type SellerOrderList struct {
+	Orders     []SellerOrderDetails `json:"orders"`
+	Pagination PaginationResponse   `json:"pagination"`
 }
@@ -10,6 +10,36 @@ type UserSignup struct {
 	Phone string `json:"phone"`
 }
 
+type ForgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordInput struct {
+	Email    string `json:"email"`
+	Code     string `json:"code"`
+	Password string `json:"password"` // the new password
+}
+
+type ChangePasswordInput struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type AssignRoleInput struct {
+	Role string `json:"role"` // buyer, seller, support or admin
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenPair is returned on login: a short lived access token and the refresh token to renew it
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds the access token is valid for
+}
+
 type VerificationCodeInput struct {
 	Code string `json:"code"`
 }
@@ -27,6 +57,7 @@ type AddressInput struct {
 	AddressLine1 string `json:"address_line1"`
 	AddressLine2 string `json:"address_line2"`
 	City         string `json:"city"`
+	Region       string `json:"region"`
 	PostCode     uint   `json:"post_code"`
 	Country      string `json:"country"`
 }
@@ -1,6 +1,9 @@
 package helper
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"go-ecommerce-app/internal/domain"
@@ -13,19 +16,35 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// DefaultAccessTTL is how long access tokens live when no lifetime is configured
+const DefaultAccessTTL = 15 * time.Minute
+
 type Auth struct {
-	Secret string
+	Secret    string
+	AccessTTL time.Duration
+	Sessions  SessionStore // nil skips the revocation check
+}
+
+// SessionStore tells the middleware whether the session an access token belongs to is still open
+type SessionStore interface {
+	IsSessionActive(sessionId string) bool
 }
 
 func NewAuth() Auth {
 	return Auth{
-		Secret: os.Getenv("APP_SECRET"),
+		Secret:    os.Getenv("APP_SECRET"),
+		AccessTTL: DefaultAccessTTL,
 	}
 }
 
-func SetupAuth(secret string) Auth {
+func SetupAuth(secret string, accessTTL time.Duration, sessions SessionStore) Auth {
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTTL
+	}
 	return Auth{
-		Secret: secret,
+		Secret:    secret,
+		AccessTTL: accessTTL,
+		Sessions:  sessions,
 	}
 }
 
@@ -53,11 +72,17 @@ func (a Auth) VerifyPassword(plain string, hashed string) error {
 
 /* ================= JWT ================= */
 
-func (a Auth) GenerateToken(id uint, email string, role string) (string, error) {
-	if id == 0 || email == "" || role == "" {
+// GenerateToken issues a short lived access token for a session of the user
+func (a Auth) GenerateToken(id uint, email string, role string, sessionId string) (string, error) {
+	if id == 0 || email == "" || role == "" || sessionId == "" {
 		return "", errors.New("invalid inputs for token generation")
 	}
 
+	ttl := a.AccessTTL
+	if ttl <= 0 {
+		ttl = DefaultAccessTTL
+	}
+
 	if a.Secret == "" {
 		return "", errors.New("jwt secret is missing")
 	}
@@ -66,7 +91,8 @@ func (a Auth) GenerateToken(id uint, email string, role string) (string, error)
 		"user_id": id,
 		"email":   email,
 		"role":    role,
-		"exp":     time.Now().Add(30 * 24 * time.Hour).Unix(),
+		"sid":     sessionId,
+		"exp":     time.Now().Add(ttl).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
@@ -80,14 +106,45 @@ func (a Auth) GenerateToken(id uint, email string, role string) (string, error)
 	return tokenStr, nil
 }
 
+// GenerateRefreshToken returns a random opaque token and the hash it is stored under
+func (a Auth) GenerateRefreshToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", errors.New("failed to generate refresh token")
+	}
+	token = hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// GenerateSessionId returns a random id for a new session
+func (a Auth) GenerateSessionId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("failed to generate session id")
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken is how refresh tokens are looked up, the tokens themselves are never stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func (a Auth) VerifyToken(authHeader string) (domain.User, error) {
+	user, _, err := a.parseToken(authHeader)
+	return user, err
+}
+
+// parseToken validates an access token and returns its user and session id
+func (a Auth) parseToken(authHeader string) (domain.User, string, error) {
 	if authHeader == "" {
-		return domain.User{}, errors.New("authorization header missing")
+		return domain.User{}, "", errors.New("authorization header missing")
 	}
 
 	parts := strings.SplitN(authHeader, " ", 2)
 	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return domain.User{}, errors.New("invalid authorization header format")
+		return domain.User{}, "", errors.New("invalid authorization header format")
 	}
 
 	tokenStr := parts[1]
@@ -99,17 +156,17 @@ func (a Auth) VerifyToken(authHeader string) (domain.User, error) {
 		return []byte(a.Secret), nil
 	})
 	if err != nil || !parsedToken.Valid {
-		return domain.User{}, errors.New("invalid token")
+		return domain.User{}, "", errors.New("invalid token")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return domain.User{}, errors.New("invalid token claims")
+		return domain.User{}, "", errors.New("invalid token claims")
 	}
 
 	exp, ok := claims["exp"].(float64)
 	if !ok || time.Now().Unix() > int64(exp) {
-		return domain.User{}, errors.New("token expired")
+		return domain.User{}, "", errors.New("token expired")
 	}
 
 	user := domain.User{
@@ -117,47 +174,66 @@ func (a Auth) VerifyToken(authHeader string) (domain.User, error) {
 		Email:    claims["email"].(string),
 		UserType: claims["role"].(string),
 	}
+	sessionId, _ := claims["sid"].(string)
 
-	return user, nil
+	return user, sessionId, nil
 }
 
 /* ================= MIDDLEWARE ================= */
 
-func (a Auth) Authorize(ctx *fiber.Ctx) error {
-	authHeader := ctx.Get("Authorization")
+// authenticate verifies the bearer token of the request and that its session was not revoked.
+// A request going through several auth middlewares is only checked once.
+func (a Auth) authenticate(ctx *fiber.Ctx) (domain.User, error) {
+	if user, ok := ctx.Locals("user").(domain.User); ok && user.ID > 0 {
+		return user, nil
+	}
 
-	user, err := a.VerifyToken(authHeader)
+	user, sessionId, err := a.parseToken(ctx.Get("Authorization"))
 	if err != nil {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"message": "authorization failed",
-			"reason":  err.Error(),
-		})
+		return domain.User{}, err
+	}
+	// tokens issued before sessions existed cannot be revoked and are no longer accepted
+	if a.Sessions != nil && (sessionId == "" || !a.Sessions.IsSessionActive(sessionId)) {
+		return domain.User{}, errors.New("token has been revoked")
 	}
-
 	ctx.Locals("user", user)
-	return ctx.Next()
+	ctx.Locals("session", sessionId)
+	return user, nil
 }
 
-func (a Auth) AuthorizeSeller(ctx *fiber.Ctx) error {
-	authHeader := ctx.Get("Authorization")
-
-	user, err := a.VerifyToken(authHeader)
-	if err != nil {
+// Authorize lets any signed in user through
+func (a Auth) Authorize(ctx *fiber.Ctx) error {
+	if _, err := a.authenticate(ctx); err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
 			"message": "authorization failed",
 			"reason":  err.Error(),
 		})
 	}
+	return ctx.Next()
+}
 
-	if user.UserType != domain.SELLER {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"message": "authorization failed",
-			"reason":  "please join seller program to manage products",
-		})
-	}
+// Require is declared on a route with the permissions it needs, the role of the signed in
+// user must grant all of them, e.g. group.Post("/categories", auth.Require(domain.PermCategoriesManage), h)
+func (a Auth) Require(perms ...domain.Permission) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		user, err := a.authenticate(ctx)
+		if err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"message": "authorization failed",
+				"reason":  err.Error(),
+			})
+		}
 
-	ctx.Locals("user", user)
-	return ctx.Next()
+		for _, p := range perms {
+			if !domain.HasPermission(user.UserType, p) {
+				return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+					"message": "authorization failed",
+					"reason":  fmt.Sprintf("%s permission required", p),
+				})
+			}
+		}
+		return ctx.Next()
+	}
 }
 
 /* ================= CONTEXT ================= */
@@ -170,6 +246,12 @@ func (a Auth) GetCurrentUser(ctx *fiber.Ctx) domain.User {
 	return user
 }
 
+// GetCurrentSession returns the session id of the access token of the request
+func (a Auth) GetCurrentSession(ctx *fiber.Ctx) string {
+	sessionId, _ := ctx.Locals("session").(string)
+	return sessionId
+}
+
 /* ================= OTP ================= */
 
 func (a Auth) GenerateCode() (string, error) {
@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"errors"
 	"go-ecommerce-app/internal/domain"
 	"go-ecommerce-app/internal/dto"
+	"log"
 
 	"gorm.io/gorm"
 )
@@ -10,9 +12,11 @@ import (
 type TransactionRepository interface {
 	CreatePayment(payment *domain.Payment) error
 	FindInitialPayment(uId uint) (*domain.Payment, error)
+	FindPaymentByPaymentId(pId string) (*domain.Payment, error)
+	FindPaymentByOrderId(orderId string) (*domain.Payment, error)
 	UpdatePayment(payment *domain.Payment) error
-	FindOrders(uId uint) ([]domain.OrderItem, error)
-	FindOrderById(uId uint, id uint) (dto.SellerOrderDetails, error)
+	FindOrders(uId uint, filter dto.SellerOrderFilter) ([]dto.SellerOrderDetails, int64, error)
+	FindOrderById(uId uint, id uint) ([]dto.SellerOrderDetails, error)
 }
 
 type transactionStorage struct {
@@ -42,22 +46,132 @@ func (t *transactionStorage) FindInitialPayment(uId uint) (*domain.Payment, erro
 	return &payment, nil
 }
 
+// FindPaymentByPaymentId implements [TransactionRepository].
+func (t *transactionStorage) FindPaymentByPaymentId(pId string) (*domain.Payment, error) {
+
+	var payment domain.Payment
+
+	err := t.db.Where("payment_id = ?", pId).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// FindPaymentByOrderId implements [TransactionRepository].
+func (t *transactionStorage) FindPaymentByOrderId(orderId string) (*domain.Payment, error) {
+
+	var payment domain.Payment
+
+	err := t.db.Where("order_id = ?", orderId).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
 // CreatePayment implements [TransactionRepository].
 func (t *transactionStorage) CreatePayment(payment *domain.Payment) error {
 	return t.db.Create(payment).Error
 }
 
+// sellerOrderQuery joins the seller's order items with their sub-order, the parent order and
+// the buyer. The address is the one copied onto the order at checkout, not the buyer's profile.
+func (t *transactionStorage) sellerOrderQuery(uId uint) *gorm.DB {
+	return t.db.Table("order_items AS oi").
+		Joins("JOIN orders AS o ON o.id = oi.order_id").
+		Joins("LEFT JOIN sub_orders AS so ON so.id = oi.sub_order_id").
+		Joins("JOIN users AS u ON u.id = o.user_id").
+		Where("oi.seller_id = ?", uId)
+}
+
+// sellerOrderStatus is the status of the seller's sub-order, orders placed before sub-orders
+// existed fall back to the order status
+const sellerOrderStatus = "COALESCE(so.status, o.status)"
+
+const sellerOrderColumns = `o.id AS order_id,
+	o.order_ref_number,
+	oi.sub_order_id,
+	` + sellerOrderStatus + ` AS order_status,
+	o.created_at,
+	oi.id AS order_item_id,
+	oi.product_id,
+	oi.name,
+	oi.image_url,
+	oi.price,
+	oi.qty,
+	oi.discount,
+	TRIM(CONCAT_WS(' ', u.first_name, u.last_name)) AS customer_name,
+	u.email AS customer_email,
+	u.phone AS customer_phone,
+	CONCAT_WS(', ', NULLIF(o.ship_address_line1, ''), NULLIF(o.ship_address_line2, ''), NULLIF(o.ship_city, ''),
+		NULLIF(o.ship_region, ''), NULLIF(o.ship_post_code, 0), NULLIF(o.ship_country, '')) AS customer_address`
+
 // FindOrderById implements [TransactionRepository].
-func (t transactionStorage) FindOrderById(uId uint, id uint) (dto.SellerOrderDetails, error) {
-	//TODO implement me
-	panic("implement me")
+// It returns one row per item the seller has in the order.
+func (t *transactionStorage) FindOrderById(uId uint, id uint) ([]dto.SellerOrderDetails, error) {
+
+	var items []dto.SellerOrderDetails
+
+	err := t.sellerOrderQuery(uId).
+		Where("o.id = ?", id).
+		Select(sellerOrderColumns).
+		Order("oi.id").
+		Scan(&items).
+		Error
+
+	if err != nil {
+		log.Printf("error on fetching seller order %v", err)
+		return nil, errors.New("failed to fetch order")
+	}
+	if len(items) == 0 {
+		return nil, errors.New("order does not exist")
+	}
+
+	return items, nil
 }
 
 // FindOrders implements [TransactionRepository].
+func (t *transactionStorage) FindOrders(uId uint, filter dto.SellerOrderFilter) ([]dto.SellerOrderDetails, int64, error) {
+
+	query := t.sellerOrderQuery(uId)
+
+	if filter.Status != "" {
+		query = query.Where(sellerOrderStatus+" = ?", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("o.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("o.created_at < ?", filter.To)
+	}
+
+	// share the filters between the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("error on counting seller orders %v", err)
+		return nil, 0, errors.New("failed to fetch orders")
+	}
+
+	orders := make([]dto.SellerOrderDetails, 0)
+	err := query.
+		Select(sellerOrderColumns).
+		Order("o.created_at DESC, oi.id DESC").
+		Offset(filter.Offset()).
+		Limit(filter.Limit).
+		Scan(&orders).
+		Error
+
+	if err != nil {
+		log.Printf("error on fetching seller orders %v", err)
+		return nil, 0, errors.New("failed to fetch orders")
+	}
 
-func (t transactionStorage) FindOrders(uId uint) ([]domain.OrderItem, error) {
-	//TODO implement me
-	panic("implement me")
+	return orders, total, nil
 }
 
 func NewTransactionRepository(db *gorm.DB) TransactionRepository {
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+type CancellationRepository interface {
+	CreateCancellation(c *domain.OrderCancellation) error
+	FindCancellations(orderId uint) ([]domain.OrderCancellation, error)
+}
+
+type cancellationRepository struct {
+	db *gorm.DB
+}
+
+// CreateCancellation implements [CancellationRepository].
+func (r *cancellationRepository) CreateCancellation(c *domain.OrderCancellation) error {
+	if err := r.db.Create(c).Error; err != nil {
+		log.Printf("error on creating order cancellation %v", err)
+		return errors.New("failed to record cancellation")
+	}
+	return nil
+}
+
+// FindCancellations implements [CancellationRepository].
+func (r *cancellationRepository) FindCancellations(orderId uint) ([]domain.OrderCancellation, error) {
+	var cancellations []domain.OrderCancellation
+	err := r.db.Where("order_id = ?", orderId).Order("created_at, id").Find(&cancellations).Error
+	if err != nil {
+		log.Printf("error on fetching order cancellations %v", err)
+		return nil, errors.New("failed to fetch cancellations")
+	}
+	return cancellations, nil
+}
+
+func NewCancellationRepository(db *gorm.DB) CancellationRepository {
+	return &cancellationRepository{
+		db: db,
+	}
+}
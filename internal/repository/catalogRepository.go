@@ -2,10 +2,14 @@ package repository
 
 import (
 	"errors"
+	"fmt"
 	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
 	"log"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CatalogRepository interface {
@@ -14,13 +18,33 @@ type CatalogRepository interface {
 	FindCategoryById(id int) (*domain.Category, error)
 	EditCategory(e *domain.Category) (*domain.Category, error)
 	DeleteCategory(id int) error
+	DeleteCategoryWithPolicy(e *domain.Category, policy dto.CategoryDeletePolicy) error
 
 	CreateProduct(e *domain.Product) error
-	FindProducts() ([]*domain.Product, error)
-	FindProductById(id int) (*domain.Product, error) // fixed
-	FindSellerProducts(id int) ([]*domain.Product, error)
+	FindProducts(filter dto.ProductFilter) ([]*domain.Product, int64, error)
+	FindProductById(id int) (*domain.Product, error)        // fixed
 	EditProduct(e *domain.Product) (*domain.Product, error) // fixed
 	DeleteProduct(e *domain.Product) error
+
+	// variants
+	SetProductOptions(productId uint, options []domain.ProductOption) error
+	CreateVariant(v *domain.ProductVariant, actorId uint) error
+	EditVariant(v *domain.ProductVariant) error
+	DeleteVariant(v *domain.ProductVariant) error
+
+	// images
+	AddProductImages(productId uint, images []domain.ProductImage) ([]domain.ProductImage, error)
+	SetPrimaryImage(productId uint, imageId uint) error
+	ReorderProductImages(productId uint, imageIds []uint) error
+	DeleteProductImage(img *domain.ProductImage) error
+	FindProductImagesAfter(afterId uint, limit int) ([]domain.ProductImage, error)
+	UpdateProductImageSizes(img *domain.ProductImage) error
+
+	// stock ledger, m.VariantId selects a variant's stock
+	AdjustStock(productId uint, stock int, m domain.StockMovement) (*domain.Product, error)
+	FindStockMovements(productId uint, p dto.PaginationRequest) ([]domain.StockMovement, int64, error)
+	RebuildStock(productId uint) (*domain.Product, error)
+	CreateOpeningBalances() (int64, error)
 }
 
 type catalogRepository struct {
@@ -28,7 +52,20 @@ type catalogRepository struct {
 }
 
 func (c catalogRepository) CreateProduct(e *domain.Product) error {
-	err := c.db.Model(&domain.Product{}).Create(e).Error
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Product{}).Create(e).Error; err != nil {
+			return err
+		}
+		// the initial stock opens the product's ledger
+		return recordMovement(tx, domain.StockMovement{
+			ProductId: e.ID,
+			Type:      domain.StockMovementImport,
+			Qty:       uint(max(e.Stock, 0)),
+			Delta:     e.Stock,
+			Reason:    "initial stock",
+			ActorId:   uint(e.UserId),
+		}, e.Stock)
+	})
 	if err != nil {
 		log.Printf("err: %v", err)
 		return errors.New("cannot create product")
@@ -36,18 +73,85 @@ func (c catalogRepository) CreateProduct(e *domain.Product) error {
 	return nil
 }
 
-func (c catalogRepository) FindProducts() ([]*domain.Product, error) {
-	var products []*domain.Product
-	err := c.db.Find(&products).Error
+func (c catalogRepository) FindProducts(filter dto.ProductFilter) ([]*domain.Product, int64, error) {
+	query := c.db.Model(&domain.Product{})
+
+	if filter.Query != "" {
+		like := "%" + escapeLike(filter.Query) + "%"
+		query = query.Where("(name ILIKE ? OR description ILIKE ?)", like, like)
+	}
+	if filter.CategoryId > 0 {
+		// the category and all of its descendants
+		query = query.Where(`category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = ?
+				UNION
+				SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+			) SELECT id FROM tree)`, filter.CategoryId)
+	}
+	if filter.MinPrice > 0 {
+		query = query.Where("price >= ?", filter.MinPrice)
+	}
+	if filter.MaxPrice > 0 {
+		query = query.Where("price <= ?", filter.MaxPrice)
+	}
+	if filter.SellerId > 0 {
+		query = query.Where("user_id = ?", filter.SellerId)
+	}
+	if filter.InStock {
+		query = query.Where("(stock > 0 OR EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND v.stock > 0))")
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch products")
+	}
+
+	products := make([]*domain.Product, 0)
+	err := query.
+		Preload("Options", productOptionOrder).
+		Preload("Variants", productVariantOrder).
+		Preload("Images", productImageOrder).
+		Order(productOrder(filter.Sort)).
+		Offset(filter.Offset()).
+		Limit(filter.Limit).
+		Find(&products).Error
 	if err != nil {
-		return nil, err
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch products")
 	}
-	return products, nil
+	return products, total, nil
+}
+
+// productOrder maps a sort option to an ORDER BY clause, id keeps pages stable
+func productOrder(sort string) string {
+	switch sort {
+	case dto.ProductSortPriceAsc:
+		return "price ASC, id ASC"
+	case dto.ProductSortPriceDesc:
+		return "price DESC, id DESC"
+	case dto.ProductSortName:
+		return "name ASC, id ASC"
+	default:
+		return "created_at DESC, id DESC"
+	}
+}
+
+// escapeLike makes user input match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
 func (c catalogRepository) FindProductById(id int) (*domain.Product, error) {
 	var product *domain.Product
-	err := c.db.First(&product, id).Error
+	err := c.db.
+		Preload("Options", productOptionOrder).
+		Preload("Variants", productVariantOrder).
+		Preload("Images", productImageOrder).
+		First(&product, id).Error
 	if err != nil {
 		log.Printf("err: %v", err)
 		return nil, errors.New("  product does not exist")
@@ -55,16 +159,10 @@ func (c catalogRepository) FindProductById(id int) (*domain.Product, error) {
 	return product, nil
 
 }
-func (c catalogRepository) FindSellerProducts(id int) ([]*domain.Product, error) {
-	var products []*domain.Product
-	err := c.db.First("user_id=?", id).Find(&products).Error
-	if err != nil {
-		return nil, err
-	}
-	return products, nil
-}
 func (c catalogRepository) EditProduct(e *domain.Product) (*domain.Product, error) {
-	err := c.db.Save(&e).Error
+	// stock only changes through the stock ledger, ratings through reviews, variants through
+	// their own methods
+	err := c.db.Omit("stock", "rating_avg", "rating_count", clause.Associations).Save(&e).Error
 	if err != nil {
 		log.Printf("db_err: %v", err)
 		return nil, errors.New("Failed to update produc")
@@ -72,13 +170,381 @@ func (c catalogRepository) EditProduct(e *domain.Product) (*domain.Product, erro
 	return e, nil
 }
 func (c catalogRepository) DeleteProduct(e *domain.Product) error {
-	err := c.db.Delete(&domain.Product{}, e.ID).Error
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", e.ID).Delete(&domain.ProductImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Product{}, e.ID).Error
+	})
 	if err != nil {
+		log.Printf("db_err: %v", err)
 		return errors.New("product cannot delete")
 	}
 	return nil
 }
 
+// AdjustStock sets the stock of a product, or of the variant m.VariantId, to an absolute
+// value and records the difference
+func (c catalogRepository) AdjustStock(productId uint, stock int, m domain.StockMovement) (*domain.Product, error) {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&domain.Product{}).Where("id = ?", productId)
+		if m.VariantId > 0 {
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&domain.ProductVariant{}).Where("id = ? AND product_id = ?", m.VariantId, productId)
+		}
+
+		var current []int
+		if err := query.Pluck("stock", &current).Error; err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		m.ProductId = productId
+		m.Delta = stock - current[0]
+		if m.Delta < 0 {
+			m.Qty = uint(-m.Delta)
+		} else {
+			m.Qty = uint(m.Delta)
+		}
+
+		update := tx.Model(&domain.Product{}).Where("id = ?", productId)
+		if m.VariantId > 0 {
+			update = tx.Model(&domain.ProductVariant{}).Where("id = ?", m.VariantId)
+		}
+		if err := update.Update("stock", stock).Error; err != nil {
+			return err
+		}
+		return recordMovement(tx, m, stock)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to update stock")
+	}
+	return c.FindProductById(int(productId))
+}
+
+func (c catalogRepository) FindStockMovements(productId uint, p dto.PaginationRequest) ([]domain.StockMovement, int64, error) {
+	query := c.db.Model(&domain.StockMovement{}).Where("product_id = ?", productId).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch stock movements")
+	}
+
+	movements := make([]domain.StockMovement, 0)
+	err := query.Order("created_at DESC, id DESC").Offset(p.Offset()).Limit(p.Limit).Find(&movements).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch stock movements")
+	}
+	return movements, total, nil
+}
+
+// RebuildStock recomputes a product's stock, and the stock of each of its variants, from the ledger
+func (c catalogRepository) RebuildStock(productId uint) (*domain.Product, error) {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var product domain.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productId).Error
+		if err != nil {
+			return err
+		}
+
+		balance := func(variantId uint) (int, error) {
+			var stock int
+			err := tx.Model(&domain.StockMovement{}).
+				Where("product_id = ? AND variant_id = ?", productId, variantId).
+				Select("COALESCE(SUM(delta), 0)").
+				Scan(&stock).Error
+			return stock, err
+		}
+
+		stock, err := balance(0)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&product).Update("stock", stock).Error; err != nil {
+			return err
+		}
+
+		var variantIds []uint
+		if err := tx.Model(&domain.ProductVariant{}).Where("product_id = ?", productId).Pluck("id", &variantIds).Error; err != nil {
+			return err
+		}
+		for _, variantId := range variantIds {
+			stock, err := balance(variantId)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&domain.ProductVariant{}).Where("id = ?", variantId).Update("stock", stock).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to rebuild stock")
+	}
+	return c.FindProductById(int(productId))
+}
+
+// CreateOpeningBalances starts a ledger for products created before stock was tracked,
+// so rebuilding never loses their current stock
+func (c catalogRepository) CreateOpeningBalances() (int64, error) {
+	res := c.db.Exec(`INSERT INTO stock_movements (product_id, variant_id, type, qty, delta, balance, reason, actor_id, created_at)
+		SELECT p.id, 0, ?, GREATEST(p.stock, 0), p.stock, p.stock, 'opening balance', p.user_id, NOW()
+		FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id AND m.variant_id = 0)`,
+		domain.StockMovementImport)
+	if res.Error != nil {
+		log.Printf("db_err: %v", res.Error)
+		return 0, errors.New("failed to create opening stock balances")
+	}
+	created := res.RowsAffected
+
+	res = c.db.Exec(`INSERT INTO stock_movements (product_id, variant_id, type, qty, delta, balance, reason, actor_id, created_at)
+		SELECT v.product_id, v.id, ?, GREATEST(v.stock, 0), v.stock, v.stock, 'opening balance', 0, NOW()
+		FROM product_variants v
+		WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.variant_id = v.id)`,
+		domain.StockMovementImport)
+	if res.Error != nil {
+		log.Printf("db_err: %v", res.Error)
+		return 0, errors.New("failed to create opening stock balances")
+	}
+	return created + res.RowsAffected, nil
+}
+
+// SetProductOptions replaces the option axes of a product
+func (c catalogRepository) SetProductOptions(productId uint, options []domain.ProductOption) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productId).Delete(&domain.ProductOption{}).Error; err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			return nil
+		}
+		for i := range options {
+			options[i].ID = 0
+			options[i].ProductId = productId
+		}
+		return tx.Create(&options).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to save product options")
+	}
+	return nil
+}
+
+// CreateVariant stores a variant and opens its stock ledger
+func (c catalogRepository) CreateVariant(v *domain.ProductVariant, actorId uint) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(v).Error; err != nil {
+			return err
+		}
+		return recordMovement(tx, domain.StockMovement{
+			ProductId: v.ProductId,
+			VariantId: v.ID,
+			Type:      domain.StockMovementImport,
+			Qty:       uint(max(v.Stock, 0)),
+			Delta:     v.Stock,
+			Reason:    "initial stock",
+			ActorId:   actorId,
+		}, v.Stock)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to create variant, the sku may already be in use")
+	}
+	return nil
+}
+
+func (c catalogRepository) EditVariant(v *domain.ProductVariant) error {
+	// stock only changes through the stock ledger
+	err := c.db.Omit("stock").Save(v).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to update variant, the sku may already be in use")
+	}
+	return nil
+}
+
+func (c catalogRepository) DeleteVariant(v *domain.ProductVariant) error {
+	err := c.db.Delete(&domain.ProductVariant{}, v.ID).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to delete variant")
+	}
+	return nil
+}
+
+// AddProductImages appends images after the existing ones, the first image of a product
+// becomes its primary image
+func (c catalogRepository) AddProductImages(productId uint, images []domain.ProductImage) ([]domain.ProductImage, error) {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		// lock the product so concurrent uploads do not get the same positions
+		var product domain.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productId).Error; err != nil {
+			return err
+		}
+
+		var existing []domain.ProductImage
+		if err := tx.Where("product_id = ?", productId).Find(&existing).Error; err != nil {
+			return err
+		}
+		next, hasPrimary := 0, false
+		for _, img := range existing {
+			next = max(next, img.Position+1)
+			hasPrimary = hasPrimary || img.IsPrimary
+		}
+
+		for i := range images {
+			images[i].ProductId = productId
+			images[i].Position = next + i
+			images[i].IsPrimary = !hasPrimary && i == 0
+		}
+		if err := tx.Create(&images).Error; err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, productId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to save product images")
+	}
+	return images, nil
+}
+
+func (c catalogRepository) SetPrimaryImage(productId uint, imageId uint) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.ProductImage{}).
+			Where("product_id = ?", productId).
+			Update("is_primary", gorm.Expr("id = ?", imageId)).Error
+		if err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, productId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to set primary image")
+	}
+	return nil
+}
+
+// ReorderProductImages positions the images in the given order, images left out keep
+// their place after the listed ones
+func (c catalogRepository) ReorderProductImages(productId uint, imageIds []uint) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var images []domain.ProductImage
+		if err := tx.Where("product_id = ?", productId).Order("position, id").Find(&images).Error; err != nil {
+			return err
+		}
+
+		position := make(map[uint]int, len(images))
+		for i, id := range imageIds {
+			position[id] = i
+		}
+		next := len(imageIds)
+		for _, img := range images {
+			pos, ok := position[img.ID]
+			if !ok {
+				pos = next
+				next++
+			}
+			err := tx.Model(&domain.ProductImage{}).Where("id = ?", img.ID).Update("position", pos).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to reorder product images")
+	}
+	return nil
+}
+
+// DeleteProductImage removes an image, the next image in order takes over as primary
+func (c catalogRepository) DeleteProductImage(img *domain.ProductImage) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.ProductImage{}, img.ID).Error; err != nil {
+			return err
+		}
+		if img.IsPrimary {
+			var next domain.ProductImage
+			err := tx.Where("product_id = ?", img.ProductId).Order("position, id").Limit(1).Find(&next).Error
+			if err != nil {
+				return err
+			}
+			if next.ID > 0 {
+				if err := tx.Model(&next).Update("is_primary", true).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return syncPrimaryImage(tx, img.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to delete product image")
+	}
+	return nil
+}
+
+// FindProductImagesAfter pages through all product images by id
+func (c catalogRepository) FindProductImagesAfter(afterId uint, limit int) ([]domain.ProductImage, error) {
+	var images []domain.ProductImage
+	err := c.db.Where("id > ?", afterId).Order("id").Limit(limit).Find(&images).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to fetch product images")
+	}
+	return images, nil
+}
+
+func (c catalogRepository) UpdateProductImageSizes(img *domain.ProductImage) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.ProductImage{ID: img.ID}).Select("sizes").
+			Updates(domain.ProductImage{Sizes: img.Sizes}).Error
+		if err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, img.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to update image sizes")
+	}
+	return nil
+}
+
+// syncPrimaryImage copies the primary image url and sizes onto the product
+func syncPrimaryImage(tx *gorm.DB, productId uint) error {
+	var primary domain.ProductImage
+	err := tx.Where("product_id = ? AND is_primary", productId).Limit(1).Find(&primary).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&domain.Product{ID: productId}).Select("image_url", "image_sizes").
+		Updates(domain.Product{ImageUrl: primary.Url, ImageSizes: primary.Sizes}).Error
+}
+
+func productImageOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func productOptionOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func productVariantOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
 func (c catalogRepository) CreateCategory(e *domain.Category) error {
 	err := c.db.Create(&e).Error
 	if err != nil {
@@ -91,7 +557,7 @@ func (c catalogRepository) CreateCategory(e *domain.Category) error {
 func (c catalogRepository) FindCategories() ([]*domain.Category, error) {
 	var categories []*domain.Category
 
-	err := c.db.Find(&categories).Error
+	err := c.db.Order("display_order, id").Find(&categories).Error
 
 	if err != nil {
 		return nil, err
@@ -129,6 +595,63 @@ func (c catalogRepository) DeleteCategory(id int) error {
 	return nil
 }
 
+// DeleteCategoryWithPolicy deletes a category, handling its children and products as the policy says
+func (c catalogRepository) DeleteCategoryWithPolicy(e *domain.Category, policy dto.CategoryDeletePolicy) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+
+		switch policy {
+		case dto.CategoryDeleteReparent:
+			if err := tx.Model(&domain.Category{}).Where("parent_id = ?", e.ID).Update("parent_id", e.ParentId).Error; err != nil {
+				log.Printf("db_err: %v", err)
+				return errors.New("failed to move child categories")
+			}
+			if err := tx.Model(&domain.Product{}).Where("category_id = ?", e.ID).Update("category_id", e.ParentId).Error; err != nil {
+				log.Printf("db_err: %v", err)
+				return errors.New("failed to move category products")
+			}
+			return deleteCategories(tx, []uint{e.ID})
+
+		case dto.CategoryDeleteCascade:
+			var ids []uint
+			err := tx.Raw(`WITH RECURSIVE tree AS (
+					SELECT id FROM categories WHERE id = ?
+					UNION
+					SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+				) SELECT id FROM tree`, e.ID).Scan(&ids).Error
+			if err != nil {
+				log.Printf("db_err: %v", err)
+				return errors.New("failed to delete category")
+			}
+			if err := tx.Model(&domain.Product{}).Where("category_id IN ?", ids).Update("category_id", 0).Error; err != nil {
+				log.Printf("db_err: %v", err)
+				return errors.New("failed to detach category products")
+			}
+			return deleteCategories(tx, ids)
+
+		default:
+			var children, products int64
+			if err := tx.Model(&domain.Category{}).Where("parent_id = ?", e.ID).Count(&children).Error; err != nil {
+				return errors.New("failed to delete category")
+			}
+			if err := tx.Model(&domain.Product{}).Where("category_id = ?", e.ID).Count(&products).Error; err != nil {
+				return errors.New("failed to delete category")
+			}
+			if children > 0 || products > 0 {
+				return fmt.Errorf("category has %d child categories and %d products, use policy reparent or cascade", children, products)
+			}
+			return deleteCategories(tx, []uint{e.ID})
+		}
+	})
+}
+
+func deleteCategories(tx *gorm.DB, ids []uint) error {
+	if err := tx.Delete(&domain.Category{}, ids).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to delete category")
+	}
+	return nil
+}
+
 func NewCatalogRepository(db *gorm.DB) CatalogRepository {
 	return &catalogRepository{
 		db: db,
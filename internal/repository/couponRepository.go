@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CouponRepository interface {
+	CreateCoupon(c *domain.Coupon) error
+	UpdateCoupon(c *domain.Coupon) error
+	FindCoupons(p dto.PaginationRequest) ([]domain.Coupon, int64, error)
+	FindCouponById(id uint) (*domain.Coupon, error)
+	FindCouponByCode(code string) (*domain.Coupon, error)
+
+	// the coupon applied to a cart
+	SetCartCoupon(userId uint, couponId uint) error
+	FindCartCoupon(userId uint) (*domain.Coupon, error)
+	DeleteCartCoupon(userId uint) error
+
+	// usage
+	CountUserRedemptions(couponId uint, userId uint) (int64, error)
+	ReserveRedemption(r *domain.CouponRedemption) error
+	FindRedemption(orderRef string) (*domain.CouponRedemption, error)
+	RedeemRedemption(orderRef string) error
+	ReleaseRedemption(orderRef string) error
+}
+
+type couponRepository struct {
+	db *gorm.DB
+}
+
+func (r *couponRepository) CreateCoupon(c *domain.Coupon) error {
+	if err := r.db.Create(c).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to create coupon, the code may already be in use")
+	}
+	return nil
+}
+
+func (r *couponRepository) UpdateCoupon(c *domain.Coupon) error {
+	// used_count only changes through redemptions
+	if err := r.db.Omit("used_count").Save(c).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to update coupon, the code may already be in use")
+	}
+	return nil
+}
+
+func (r *couponRepository) FindCoupons(p dto.PaginationRequest) ([]domain.Coupon, int64, error) {
+	var total int64
+	if err := r.db.Model(&domain.Coupon{}).Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch coupons")
+	}
+
+	coupons := make([]domain.Coupon, 0)
+	err := r.db.Order("created_at DESC, id DESC").Offset(p.Offset()).Limit(p.Limit).Find(&coupons).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch coupons")
+	}
+	return coupons, total, nil
+}
+
+func (r *couponRepository) FindCouponById(id uint) (*domain.Coupon, error) {
+	var coupon domain.Coupon
+	if err := r.db.First(&coupon, id).Error; err != nil {
+		return nil, errors.New("coupon does not exist")
+	}
+	return &coupon, nil
+}
+
+func (r *couponRepository) FindCouponByCode(code string) (*domain.Coupon, error) {
+	var coupon domain.Coupon
+	if err := r.db.Where("code = ?", code).First(&coupon).Error; err != nil {
+		return nil, errors.New("coupon code is not valid")
+	}
+	return &coupon, nil
+}
+
+func (r *couponRepository) SetCartCoupon(userId uint, couponId uint) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"coupon_id", "created_at"}),
+	}).Create(&domain.CartCoupon{UserId: userId, CouponId: couponId}).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to apply coupon")
+	}
+	return nil
+}
+
+func (r *couponRepository) FindCartCoupon(userId uint) (*domain.Coupon, error) {
+	var coupon domain.Coupon
+	err := r.db.Joins("JOIN cart_coupons ON cart_coupons.coupon_id = coupons.id").
+		Where("cart_coupons.user_id = ?", userId).
+		First(&coupon).Error
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *couponRepository) DeleteCartCoupon(userId uint) error {
+	if err := r.db.Where("user_id = ?", userId).Delete(&domain.CartCoupon{}).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to remove coupon")
+	}
+	return nil
+}
+
+// CountUserRedemptions counts the uses of a coupon by a buyer, payments in progress included
+func (r *couponRepository) CountUserRedemptions(couponId uint, userId uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&domain.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ? AND status <> ?", couponId, userId, domain.RedemptionStatusReleased).
+		Count(&n).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return 0, errors.New("failed to check coupon usage")
+	}
+	return n, nil
+}
+
+// ReserveRedemption takes one use of the coupon for a payment. The coupon row is locked so
+// concurrent checkouts cannot go over the total or per buyer limits.
+func (r *couponRepository) ReserveRedemption(e *domain.CouponRedemption) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var coupon domain.Coupon
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, e.CouponId).Error; err != nil {
+			log.Printf("db_err: %v", err)
+			return errors.New("coupon does not exist")
+		}
+
+		if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+			return errors.New("coupon has reached its usage limit")
+		}
+		if coupon.PerUserLimit > 0 {
+			var used int64
+			err := tx.Model(&domain.CouponRedemption{}).
+				Where("coupon_id = ? AND user_id = ? AND status <> ?", e.CouponId, e.UserId, domain.RedemptionStatusReleased).
+				Count(&used).Error
+			if err != nil {
+				log.Printf("db_err: %v", err)
+				return errors.New("failed to reserve coupon")
+			}
+			if used >= int64(coupon.PerUserLimit) {
+				return errors.New("you have already used this coupon the maximum number of times")
+			}
+		}
+
+		err := tx.Model(&domain.Coupon{}).Where("id = ?", e.CouponId).
+			Update("used_count", gorm.Expr("used_count + 1")).Error
+		if err != nil {
+			log.Printf("db_err: %v", err)
+			return errors.New("failed to reserve coupon")
+		}
+
+		e.Status = domain.RedemptionStatusReserved
+		if err := tx.Create(e).Error; err != nil {
+			log.Printf("db_err: %v", err)
+			return errors.New("failed to reserve coupon")
+		}
+		return nil
+	})
+}
+
+func (r *couponRepository) FindRedemption(orderRef string) (*domain.CouponRedemption, error) {
+	var redemption domain.CouponRedemption
+	if err := r.db.Where("order_ref = ?", orderRef).First(&redemption).Error; err != nil {
+		return nil, err
+	}
+	return &redemption, nil
+}
+
+// RedeemRedemption makes the reserved use final and clears the coupon from the cart
+func (r *couponRepository) RedeemRedemption(orderRef string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var redemption domain.CouponRedemption
+		res := tx.Model(&redemption).
+			Clauses(clause.Returning{}).
+			Where("order_ref = ? AND status = ?", orderRef, domain.RedemptionStatusReserved).
+			Update("status", domain.RedemptionStatusRedeemed)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Where("user_id = ? AND coupon_id = ?", redemption.UserId, redemption.CouponId).
+			Delete(&domain.CartCoupon{}).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to redeem coupon")
+	}
+	return nil
+}
+
+// ReleaseRedemption gives a reserved use back, e.g. when the payment failed
+func (r *couponRepository) ReleaseRedemption(orderRef string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var redemption domain.CouponRedemption
+		res := tx.Model(&redemption).
+			Clauses(clause.Returning{}).
+			Where("order_ref = ? AND status = ?", orderRef, domain.RedemptionStatusReserved).
+			Update("status", domain.RedemptionStatusReleased)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Model(&domain.Coupon{}).Where("id = ? AND used_count > 0", redemption.CouponId).
+			Update("used_count", gorm.Expr("used_count - 1")).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to release coupon")
+	}
+	return nil
+}
+
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InventoryRepository interface {
+	ReserveStock(items []domain.StockReservation) error
+	CommitReservations(orderRef string) error
+	ReleaseReservations(orderRef string) error
+	ReleaseExpired(now time.Time) ([]string, error)
+	FindReservations(orderRef string) ([]domain.StockReservation, error)
+	RestoreStock(movements []domain.StockMovement) error
+}
+
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+// ReserveStock implements [InventoryRepository].
+// Either every item is reserved or none is.
+func (r *inventoryRepository) ReserveStock(items []domain.StockReservation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range items {
+			_, err := changeStock(tx, domain.StockMovement{
+				ProductId: items[i].ProductId,
+				VariantId: items[i].VariantId,
+				Type:      domain.StockMovementReservation,
+				Qty:       items[i].Qty,
+				Delta:     -int(items[i].Qty),
+				Reason:    "held for checkout",
+				Reference: items[i].OrderRef,
+				ActorId:   items[i].UserId,
+			})
+			if err != nil {
+				return err
+			}
+			items[i].Status = domain.ReservationStatusActive
+			if err := tx.Create(&items[i]).Error; err != nil {
+				log.Printf("error on creating stock reservation %v", err)
+				return errors.New("failed to reserve stock")
+			}
+		}
+		return nil
+	})
+}
+
+// CommitReservations implements [InventoryRepository].
+// Reservations released by the expiry worker before the payment landed are taken again
+// when the stock is still there.
+func (r *inventoryRepository) CommitReservations(orderRef string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		var reservations []domain.StockReservation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_ref = ? AND status <> ?", orderRef, domain.ReservationStatusCommitted).
+			Find(&reservations).Error
+		if err != nil {
+			log.Printf("error on fetching stock reservations %v", err)
+			return errors.New("failed to commit stock reservation")
+		}
+
+		for _, res := range reservations {
+			sale := domain.StockMovement{
+				ProductId: res.ProductId,
+				VariantId: res.VariantId,
+				Type:      domain.StockMovementSale,
+				Qty:       res.Qty,
+				Reason:    "reserved stock sold",
+				Reference: orderRef,
+				ActorId:   res.UserId,
+			}
+			if res.Status == domain.ReservationStatusReleased {
+				sale.Delta = -int(res.Qty)
+				sale.Reason = "sold after the reservation expired"
+				if _, err := changeStock(tx, sale); err != nil {
+					// the buyer has already paid, keep the order and flag it for the seller
+					log.Printf("order %s oversold product %d: %v", orderRef, res.ProductId, err)
+				}
+			} else {
+				// the stock already left with the reservation, only the ledger changes
+				stock, err := currentStock(tx, res.ProductId, res.VariantId)
+				if err != nil {
+					return errors.New("failed to commit stock reservation")
+				}
+				if err := recordMovement(tx, sale, stock); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&res).Update("status", domain.ReservationStatusCommitted).Error; err != nil {
+				log.Printf("error on committing stock reservation %v", err)
+				return errors.New("failed to commit stock reservation")
+			}
+		}
+		return nil
+	})
+}
+
+// ReleaseReservations implements [InventoryRepository].
+func (r *inventoryRepository) ReleaseReservations(orderRef string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		_, err := releaseWhere(tx, "order_ref = ?", orderRef)
+		return err
+	})
+}
+
+// ReleaseExpired implements [InventoryRepository].
+// It returns the order references that lost their stock hold.
+func (r *inventoryRepository) ReleaseExpired(now time.Time) ([]string, error) {
+	var released []domain.StockReservation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		released, err = releaseWhere(tx, "expires_at < ?", now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var orderRefs []string
+	for _, res := range released {
+		if !slices.Contains(orderRefs, res.OrderRef) {
+			orderRefs = append(orderRefs, res.OrderRef)
+		}
+	}
+	return orderRefs, nil
+}
+
+// FindReservations implements [InventoryRepository].
+func (r *inventoryRepository) FindReservations(orderRef string) ([]domain.StockReservation, error) {
+	var reservations []domain.StockReservation
+	err := r.db.Where("order_ref = ?", orderRef).Find(&reservations).Error
+	if err != nil {
+		log.Printf("error on fetching stock reservations %v", err)
+		return nil, errors.New("failed to fetch stock reservations")
+	}
+	return reservations, nil
+}
+
+// RestoreStock implements [InventoryRepository].
+// Sold stock comes back, e.g. when an order is cancelled before it ships.
+func (r *inventoryRepository) RestoreStock(movements []domain.StockMovement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range movements {
+			if _, err := changeStock(tx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// releaseWhere gives back the stock of the active reservations matching the condition.
+// Rows are locked first so a concurrent commit cannot also see them as active.
+func releaseWhere(tx *gorm.DB, query string, args ...interface{}) ([]domain.StockReservation, error) {
+
+	var reservations []domain.StockReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where(query, args...).
+		Where("status = ?", domain.ReservationStatusActive).
+		Find(&reservations).Error
+	if err != nil {
+		log.Printf("error on fetching stock reservations %v", err)
+		return nil, errors.New("failed to release stock")
+	}
+
+	for _, res := range reservations {
+		_, err := changeStock(tx, domain.StockMovement{
+			ProductId: res.ProductId,
+			VariantId: res.VariantId,
+			Type:      domain.StockMovementRelease,
+			Qty:       res.Qty,
+			Delta:     int(res.Qty),
+			Reason:    "checkout reservation released",
+			Reference: res.OrderRef,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&res).Update("status", domain.ReservationStatusReleased).Error; err != nil {
+			log.Printf("error on releasing stock reservation %v", err)
+			return nil, errors.New("failed to release stock")
+		}
+	}
+	return reservations, nil
+}
+
+func NewInventoryRepository(db *gorm.DB) InventoryRepository {
+	return &inventoryRepository{
+		db: db,
+	}
+}
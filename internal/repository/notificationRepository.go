@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	// templates
+	FindTemplates(event domain.NotificationEvent, locales ...string) ([]domain.NotificationTemplate, error)
+	ListTemplates() ([]domain.NotificationTemplate, error)
+	SaveTemplate(t *domain.NotificationTemplate) error
+	DeleteTemplate(id uint) error
+	CreateDefaultTemplates(templates []domain.NotificationTemplate) (int64, error)
+
+	// preferences
+	FindPreference(userId uint) (*domain.NotificationPreference, error)
+	SavePreference(p *domain.NotificationPreference) error
+
+	// in-app inbox
+	CreateNotification(n *domain.Notification) error
+	FindNotifications(userId uint, unreadOnly bool, p dto.PaginationRequest) ([]domain.Notification, int64, error)
+	MarkNotificationRead(id uint, userId uint) error
+
+	// delivery log
+	CreateDelivery(d *domain.NotificationDelivery) error
+	FindDeliveries(f dto.DeliveryFilter, p dto.PaginationRequest) ([]domain.NotificationDelivery, int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// FindTemplates implements [NotificationRepository].
+func (r *notificationRepository) FindTemplates(event domain.NotificationEvent, locales ...string) ([]domain.NotificationTemplate, error) {
+	var templates []domain.NotificationTemplate
+	err := r.db.Where("event = ? AND locale IN ?", event, locales).Find(&templates).Error
+	if err != nil {
+		log.Printf("error on fetching notification templates %v", err)
+		return nil, errors.New("failed to fetch notification templates")
+	}
+	return templates, nil
+}
+
+// ListTemplates implements [NotificationRepository].
+func (r *notificationRepository) ListTemplates() ([]domain.NotificationTemplate, error) {
+	templates := make([]domain.NotificationTemplate, 0)
+	err := r.db.Order("event, locale, channel").Find(&templates).Error
+	if err != nil {
+		log.Printf("error on fetching notification templates %v", err)
+		return nil, errors.New("failed to fetch notification templates")
+	}
+	return templates, nil
+}
+
+// SaveTemplate implements [NotificationRepository].
+// A template for the same event, channel and locale is replaced.
+func (r *notificationRepository) SaveTemplate(t *domain.NotificationTemplate) error {
+	t.UpdatedAt = time.Now()
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event"}, {Name: "channel"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"subject", "text", "html", "updated_at"}),
+	}).Create(t).Error
+	if err != nil {
+		log.Printf("error on saving notification template %v", err)
+		return errors.New("failed to save notification template")
+	}
+	return nil
+}
+
+// DeleteTemplate implements [NotificationRepository].
+func (r *notificationRepository) DeleteTemplate(id uint) error {
+	res := r.db.Delete(&domain.NotificationTemplate{}, id)
+	if res.Error != nil {
+		log.Printf("error on deleting notification template %v", res.Error)
+		return errors.New("failed to delete notification template")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("notification template does not exist")
+	}
+	return nil
+}
+
+// CreateDefaultTemplates stores the templates no one has written yet, edited ones are kept
+func (r *notificationRepository) CreateDefaultTemplates(templates []domain.NotificationTemplate) (int64, error) {
+	if len(templates) == 0 {
+		return 0, nil
+	}
+	// a copy, gorm writes the ids back
+	rows := append([]domain.NotificationTemplate(nil), templates...)
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+	return res.RowsAffected, res.Error
+}
+
+// FindPreference implements [NotificationRepository].
+// Users that never saved theirs get the defaults.
+func (r *notificationRepository) FindPreference(userId uint) (*domain.NotificationPreference, error) {
+	var pref domain.NotificationPreference
+	err := r.db.Where("user_id = ?", userId).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pref = domain.DefaultNotificationPreference(userId)
+		return &pref, nil
+	}
+	if err != nil {
+		log.Printf("error on fetching notification preference %v", err)
+		return nil, errors.New("failed to fetch notification preferences")
+	}
+	return &pref, nil
+}
+
+// SavePreference implements [NotificationRepository].
+func (r *notificationRepository) SavePreference(p *domain.NotificationPreference) error {
+	p.UpdatedAt = time.Now()
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		UpdateAll: true,
+	}).Create(p).Error
+	if err != nil {
+		log.Printf("error on saving notification preference %v", err)
+		return errors.New("failed to save notification preferences")
+	}
+	return nil
+}
+
+// CreateNotification implements [NotificationRepository].
+func (r *notificationRepository) CreateNotification(n *domain.Notification) error {
+	if err := r.db.Create(n).Error; err != nil {
+		log.Printf("error on creating notification %v", err)
+		return errors.New("failed to create notification")
+	}
+	return nil
+}
+
+// FindNotifications implements [NotificationRepository].
+func (r *notificationRepository) FindNotifications(userId uint, unreadOnly bool, p dto.PaginationRequest) ([]domain.Notification, int64, error) {
+	query := r.db.Model(&domain.Notification{}).Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("error on counting notifications %v", err)
+		return nil, 0, errors.New("failed to fetch notifications")
+	}
+
+	notifications := make([]domain.Notification, 0)
+	err := query.Order("created_at DESC, id DESC").
+		Offset(p.Offset()).
+		Limit(p.Limit).
+		Find(&notifications).Error
+	if err != nil {
+		log.Printf("error on fetching notifications %v", err)
+		return nil, 0, errors.New("failed to fetch notifications")
+	}
+	return notifications, total, nil
+}
+
+// MarkNotificationRead implements [NotificationRepository].
+func (r *notificationRepository) MarkNotificationRead(id uint, userId uint) error {
+	res := r.db.Model(&domain.Notification{}).
+		Where("id = ? AND user_id = ?", id, userId).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if res.Error != nil {
+		log.Printf("error on marking notification read %v", res.Error)
+		return errors.New("failed to update notification")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("notification does not exist")
+	}
+	return nil
+}
+
+// CreateDelivery implements [NotificationRepository].
+func (r *notificationRepository) CreateDelivery(d *domain.NotificationDelivery) error {
+	if err := r.db.Create(d).Error; err != nil {
+		log.Printf("error on logging notification delivery %v", err)
+		return errors.New("failed to log notification delivery")
+	}
+	return nil
+}
+
+// FindDeliveries implements [NotificationRepository].
+func (r *notificationRepository) FindDeliveries(f dto.DeliveryFilter, p dto.PaginationRequest) ([]domain.NotificationDelivery, int64, error) {
+	query := r.db.Model(&domain.NotificationDelivery{})
+	if f.UserId > 0 {
+		query = query.Where("user_id = ?", f.UserId)
+	}
+	if f.Event != "" {
+		query = query.Where("event = ?", f.Event)
+	}
+	if f.Channel != "" {
+		query = query.Where("channel = ?", f.Channel)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("error on counting notification deliveries %v", err)
+		return nil, 0, errors.New("failed to fetch notification deliveries")
+	}
+
+	deliveries := make([]domain.NotificationDelivery, 0)
+	err := query.Order("created_at DESC, id DESC").
+		Offset(p.Offset()).
+		Limit(p.Limit).
+		Find(&deliveries).Error
+	if err != nil {
+		log.Printf("error on fetching notification deliveries %v", err)
+		return nil, 0, errors.New("failed to fetch notification deliveries")
+	}
+	return deliveries, total, nil
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrderRepository interface {
+	FindOrderById(id uint) (*domain.Order, error)
+	UpdateOrderStatus(o *domain.Order, h domain.OrderStatusHistory) error
+	FindOrderHistory(orderId uint) ([]domain.OrderStatusHistory, error)
+
+	// sub-orders, one per seller
+	CreateSubOrders(orderId uint, subOrders []domain.SubOrder) error
+	UpdateSubOrderStatus(so *domain.SubOrder, h domain.OrderStatusHistory) (cancelledItems []uint, err error)
+	FindSubOrderHistory(subOrderId uint) ([]domain.OrderStatusHistory, error)
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+// FindOrderById implements [OrderRepository].
+func (r *orderRepository) FindOrderById(id uint) (*domain.Order, error) {
+	var order domain.Order
+	err := r.db.Preload("Items").
+		Preload("Shipping").
+		Preload("SubOrders", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&order, id).Error
+	if err != nil {
+		log.Printf("error on fetching order %v", err)
+		return nil, errors.New("order does not exist")
+	}
+	return &order, nil
+}
+
+// UpdateOrderStatus implements [OrderRepository].
+// The status is only changed if it still matches h.FromStatus, so two concurrent
+// transitions from the same state cannot both succeed.
+func (r *orderRepository) UpdateOrderStatus(o *domain.Order, h domain.OrderStatusHistory) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.Order{}).
+			Where("id = ? AND status = ?", o.ID, h.FromStatus).
+			Update("status", h.ToStatus)
+		if res.Error != nil {
+			log.Printf("error on updating order status %v", res.Error)
+			return errors.New("failed to update order status")
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("order status was changed by another request, please retry")
+		}
+
+		h.OrderId = o.ID
+		if err := tx.Create(&h).Error; err != nil {
+			log.Printf("error on creating order history %v", err)
+			return errors.New("failed to update order status")
+		}
+
+		o.Status = h.ToStatus
+		return nil
+	})
+}
+
+// FindOrderHistory implements [OrderRepository].
+func (r *orderRepository) FindOrderHistory(orderId uint) ([]domain.OrderStatusHistory, error) {
+	var history []domain.OrderStatusHistory
+	err := r.db.Where("order_id=?", orderId).Order("created_at, id").Find(&history).Error
+	if err != nil {
+		log.Printf("error on fetching order history %v", err)
+		return nil, errors.New("failed to fetch order history")
+	}
+	return history, nil
+}
+
+// CreateSubOrders implements [OrderRepository].
+func (r *orderRepository) CreateSubOrders(orderId uint, subOrders []domain.SubOrder) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return createSubOrders(tx, orderId, subOrders)
+	})
+	if err != nil {
+		log.Printf("error on creating sub-orders %v", err)
+		return errors.New("failed to create sub-orders")
+	}
+	return nil
+}
+
+// createSubOrders stores the sub-orders of an order and links the order items and shipping
+// of each seller to their sub-order
+func createSubOrders(tx *gorm.DB, orderId uint, subOrders []domain.SubOrder) error {
+	for i := range subOrders {
+		so := &subOrders[i]
+		so.OrderId = orderId
+		if err := tx.Omit("Items", "Shipping").Create(so).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&domain.OrderItem{}).
+			Where("order_id = ? AND seller_id = ?", orderId, so.SellerId).
+			Update("sub_order_id", so.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&domain.OrderShipping{}).
+			Where("order_id = ? AND seller_id = ?", orderId, so.SellerId).
+			Update("sub_order_id", so.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateSubOrderStatus implements [OrderRepository].
+// Like UpdateOrderStatus the change only applies if the sub-order is still in h.FromStatus.
+// Cancelling a sub-order cancels its items that have not shipped, their ids are returned.
+func (r *orderRepository) UpdateSubOrderStatus(so *domain.SubOrder, h domain.OrderStatusHistory) ([]uint, error) {
+	var cancelled []domain.OrderItem
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.SubOrder{}).
+			Where("id = ? AND status = ?", so.ID, h.FromStatus).
+			Update("status", h.ToStatus)
+		if res.Error != nil {
+			log.Printf("error on updating sub-order status %v", res.Error)
+			return errors.New("failed to update order status")
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("order status was changed by another request, please retry")
+		}
+
+		if h.ToStatus == domain.OrderStatusCancelled {
+			err := tx.Model(&cancelled).
+				Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+				Where("sub_order_id = ? AND status IN ?", so.ID, unshippedItemStatuses).
+				Update("status", domain.OrderStatusCancelled).Error
+			if err != nil {
+				log.Printf("error on cancelling sub-order items %v", err)
+				return errors.New("failed to update order status")
+			}
+		}
+
+		h.OrderId = so.OrderId
+		h.SubOrderId = so.ID
+		if err := tx.Create(&h).Error; err != nil {
+			log.Printf("error on creating order history %v", err)
+			return errors.New("failed to update order status")
+		}
+
+		so.Status = h.ToStatus
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(cancelled))
+	for _, item := range cancelled {
+		ids = append(ids, item.ID)
+	}
+	return ids, nil
+}
+
+// FindSubOrderHistory implements [OrderRepository].
+func (r *orderRepository) FindSubOrderHistory(subOrderId uint) ([]domain.OrderStatusHistory, error) {
+	var history []domain.OrderStatusHistory
+	err := r.db.Where("sub_order_id=?", subOrderId).Order("created_at, id").Find(&history).Error
+	if err != nil {
+		log.Printf("error on fetching order history %v", err)
+		return nil, errors.New("failed to fetch order history")
+	}
+	return history, nil
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	CreateReset(r *domain.PasswordReset) error
+	CountResetsSince(userId uint, since time.Time) (int64, error)
+	FindActiveReset(userId uint) (*domain.PasswordReset, error)
+	ReserveResetAttempt(id uint, maxAttempts int) error
+	CompleteReset(r *domain.PasswordReset, hashedPassword string) error
+}
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// CreateReset implements [PasswordResetRepository].
+func (r *passwordResetRepository) CreateReset(e *domain.PasswordReset) error {
+	if err := r.db.Create(e).Error; err != nil {
+		log.Printf("error on creating password reset %v", err)
+		return errors.New("failed to create password reset")
+	}
+	return nil
+}
+
+// CountResetsSince implements [PasswordResetRepository].
+func (r *passwordResetRepository) CountResetsSince(userId uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.PasswordReset{}).
+		Where("user_id = ? AND created_at >= ?", userId, since).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("error on counting password resets %v", err)
+		return 0, errors.New("failed to fetch password resets")
+	}
+	return count, nil
+}
+
+// FindActiveReset implements [PasswordResetRepository].
+// Only the latest code can be used, requesting a new one replaces it.
+func (r *passwordResetRepository) FindActiveReset(userId uint) (*domain.PasswordReset, error) {
+	var reset domain.PasswordReset
+	err := r.db.Where("user_id = ?", userId).
+		Order("created_at DESC, id DESC").
+		First(&reset).Error
+	if err != nil || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return nil, errors.New("reset code is not valid or has expired")
+	}
+	return &reset, nil
+}
+
+// ReserveResetAttempt implements [PasswordResetRepository].
+// The attempt is counted before the code is checked, so parallel guesses cannot get past
+// maxAttempts.
+func (r *passwordResetRepository) ReserveResetAttempt(id uint, maxAttempts int) error {
+	res := r.db.Model(&domain.PasswordReset{}).
+		Where("id = ? AND attempts < ? AND used_at IS NULL", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		log.Printf("error on counting password reset attempt %v", res.Error)
+		return errors.New("failed to update password reset")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("too many wrong codes, please request a new one")
+	}
+	return nil
+}
+
+// CompleteReset implements [PasswordResetRepository].
+// The code is used up and the password changed together, a code can never reset twice.
+func (r *passwordResetRepository) CompleteReset(e *domain.PasswordReset, hashedPassword string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&domain.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", e.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			log.Printf("error on using password reset %v", res.Error)
+			return errors.New("failed to reset password")
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("reset code is not valid or has expired")
+		}
+
+		err := tx.Model(&domain.User{}).
+			Where("id = ?", e.UserId).
+			Update("password", hashedPassword).Error
+		if err != nil {
+			log.Printf("error on updating password %v", err)
+			return errors.New("failed to reset password")
+		}
+
+		e.UsedAt = &now
+		return nil
+	})
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{
+		db: db,
+	}
+}
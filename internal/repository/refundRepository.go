@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRefundSettled is returned when a refund is no longer pending, e.g. a webhook delivered twice
+var ErrRefundSettled = errors.New("refund was already settled")
+
+type RefundRepository interface {
+	ReserveRefund(r *domain.Refund) error
+	UpdateRefund(r *domain.Refund) error
+	CompleteRefund(r *domain.Refund) (fullyRefunded bool, err error)
+	FailRefund(r *domain.Refund) error
+	FindRefunds(orderId uint) ([]domain.Refund, error)
+	FindRefundByProviderId(providerRefundId string) (*domain.Refund, error)
+	FindRefundById(id uint) (*domain.Refund, error)
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+// ReserveRefund implements [RefundRepository].
+// The refunded totals of the payment, order and item are raised with conditional updates,
+// so concurrent refunds can never add up to more than was captured.
+func (r *refundRepository) ReserveRefund(e *domain.Refund) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&domain.Payment{}).
+			Where("id = ? AND ROUND(CAST(refunded_amount + ? AS numeric), 2) <= ROUND(CAST(amount AS numeric), 2)", e.PaymentId, e.Amount).
+			Update("refunded_amount", gorm.Expr("refunded_amount + ?", e.Amount))
+		if err := refundGuard(res, "refund exceeds the captured amount"); err != nil {
+			return err
+		}
+
+		if e.OrderItemId > 0 {
+			res = tx.Model(&domain.OrderItem{}).
+				Where("id = ? AND order_id = ? AND ROUND(CAST(refunded_amount + ? AS numeric), 2) <= ROUND(CAST("+orderItemTotal+" AS numeric), 2)", e.OrderItemId, e.OrderId, e.Amount).
+				Update("refunded_amount", gorm.Expr("refunded_amount + ?", e.Amount))
+			if err := refundGuard(res, "refund exceeds the order item amount"); err != nil {
+				return err
+			}
+
+			// item refunds count towards their sub-order so it is never refunded twice
+			err := tx.Model(&domain.SubOrder{}).
+				Where("id = (SELECT sub_order_id FROM order_items WHERE id = ?)", e.OrderItemId).
+				Update("refunded_amount", gorm.Expr("refunded_amount + ?", e.Amount)).Error
+			if err != nil {
+				log.Printf("error on reserving refund %v", err)
+				return errors.New("failed to create refund")
+			}
+		}
+
+		if e.SubOrderId > 0 {
+			res = tx.Model(&domain.SubOrder{}).
+				Where("id = ? AND order_id = ? AND ROUND(CAST(refunded_amount + ? AS numeric), 2) <= ROUND(CAST(total AS numeric), 2)", e.SubOrderId, e.OrderId, e.Amount).
+				Update("refunded_amount", gorm.Expr("refunded_amount + ?", e.Amount))
+			if err := refundGuard(res, "refund exceeds the sub-order amount"); err != nil {
+				return err
+			}
+		}
+
+		res = tx.Model(&domain.Order{}).
+			Where("id = ?", e.OrderId).
+			Update("refunded_amount", gorm.Expr("refunded_amount + ?", e.Amount))
+		if err := refundGuard(res, "order does not exist"); err != nil {
+			return err
+		}
+
+		e.Status = domain.RefundStatusPending
+		if err := tx.Create(e).Error; err != nil {
+			log.Printf("error on creating refund %v", err)
+			return errors.New("failed to create refund")
+		}
+		return nil
+	})
+}
+
+// UpdateRefund implements [RefundRepository].
+// It records what the provider answered for a refund that is still pending.
+func (r *refundRepository) UpdateRefund(e *domain.Refund) error {
+	err := r.db.Model(&domain.Refund{}).
+		Where("id = ?", e.ID).
+		Updates(map[string]interface{}{
+			"provider_refund_id": e.ProviderRefundId,
+			"response":           e.Response,
+			"updated_at":         time.Now(),
+		}).Error
+	if err != nil {
+		log.Printf("error on saving refund %v", err)
+		return errors.New("failed to update refund")
+	}
+	return nil
+}
+
+// CompleteRefund implements [RefundRepository].
+// Only a pending refund can complete. It reports whether the payment is now refunded in full.
+func (r *refundRepository) CompleteRefund(e *domain.Refund) (bool, error) {
+	fullyRefunded := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := settleRefund(tx, e, domain.RefundStatusSucceeded); err != nil {
+			return err
+		}
+
+		err := tx.Model(&domain.Payment{}).
+			Where("id = ?", e.PaymentId).
+			Update("status", gorm.Expr("CASE WHEN ROUND(CAST(refunded_amount AS numeric), 2) >= ROUND(CAST(amount AS numeric), 2) THEN ? ELSE ? END",
+				domain.PaymentStatusRefunded, domain.PaymentStatusPartiallyRefunded)).
+			Error
+		if err != nil {
+			log.Printf("error on updating payment refund status %v", err)
+			return errors.New("failed to update refund")
+		}
+
+		var p domain.Payment
+		if err := tx.Select("status").First(&p, e.PaymentId).Error; err != nil {
+			log.Printf("error on fetching payment %v", err)
+			return errors.New("failed to update refund")
+		}
+		fullyRefunded = p.Status == domain.PaymentStatusRefunded
+
+		// items that have been paid back in full are marked refunded
+		items := tx.Model(&domain.OrderItem{}).Where("order_id = ?", e.OrderId)
+		if e.OrderItemId > 0 {
+			items = items.Where("id = ? AND ROUND(CAST(refunded_amount AS numeric), 2) >= ROUND(CAST("+orderItemTotal+" AS numeric), 2)", e.OrderItemId)
+		} else if e.SubOrderId > 0 {
+			// cancelled items keep their status
+			items = items.Where("sub_order_id = ? AND status <> ?", e.SubOrderId, domain.OrderStatusCancelled).
+				Where("EXISTS (SELECT 1 FROM sub_orders so WHERE so.id = order_items.sub_order_id AND ROUND(CAST(so.refunded_amount AS numeric), 2) >= ROUND(CAST(so.total AS numeric), 2))")
+		} else {
+			items = items.Where("EXISTS (SELECT 1 FROM orders o WHERE o.id = order_items.order_id AND ROUND(CAST(o.refunded_amount AS numeric), 2) >= ROUND(CAST(o.amount AS numeric), 2))")
+		}
+		if err := items.Update("status", domain.OrderStatusRefunded).Error; err != nil {
+			log.Printf("error on updating order item refund status %v", err)
+			return errors.New("failed to update refund")
+		}
+		return nil
+	})
+	return fullyRefunded, err
+}
+
+// FailRefund implements [RefundRepository].
+// It gives back the amount held by ReserveRefund. A return the refund was paying for goes
+// back to received so it can be refunded again.
+func (r *refundRepository) FailRefund(e *domain.Refund) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := settleRefund(tx, e, domain.RefundStatusFailed); err != nil {
+			return err
+		}
+
+		release := gorm.Expr("GREATEST(refunded_amount - ?, 0)", e.Amount)
+		if err := tx.Model(&domain.Payment{}).Where("id = ?", e.PaymentId).Update("refunded_amount", release).Error; err != nil {
+			return errors.New("failed to release refund")
+		}
+		if e.OrderItemId > 0 {
+			if err := tx.Model(&domain.OrderItem{}).Where("id = ?", e.OrderItemId).Update("refunded_amount", release).Error; err != nil {
+				return errors.New("failed to release refund")
+			}
+		}
+		subOrders := tx.Model(&domain.SubOrder{}).Where("id = ?", e.SubOrderId)
+		if e.OrderItemId > 0 {
+			subOrders = tx.Model(&domain.SubOrder{}).Where("id = (SELECT sub_order_id FROM order_items WHERE id = ?)", e.OrderItemId)
+		}
+		if e.OrderItemId > 0 || e.SubOrderId > 0 {
+			if err := subOrders.Update("refunded_amount", release).Error; err != nil {
+				return errors.New("failed to release refund")
+			}
+		}
+		if err := tx.Model(&domain.Order{}).Where("id = ?", e.OrderId).Update("refunded_amount", release).Error; err != nil {
+			return errors.New("failed to release refund")
+		}
+
+		var returns []domain.ReturnRequest
+		if err := tx.Where("refund_id = ? AND status = ?", e.ID, domain.ReturnStatusRefunded).Find(&returns).Error; err != nil {
+			return errors.New("failed to release refund")
+		}
+		for _, ret := range returns {
+			err := tx.Model(&domain.ReturnRequest{}).Where("id = ?", ret.ID).Updates(map[string]interface{}{
+				"status":        domain.ReturnStatusReceived,
+				"refund_id":     0,
+				"refund_amount": 0,
+				"updated_at":    time.Now(),
+			}).Error
+			if err == nil {
+				err = tx.Create(&domain.ReturnEvent{
+					ReturnId:   ret.ID,
+					FromStatus: domain.ReturnStatusRefunded,
+					ToStatus:   domain.ReturnStatusReceived,
+					ActorRole:  domain.OrderActorSystem,
+					Note:       "refund failed",
+				}).Error
+			}
+			if err != nil {
+				log.Printf("error on reopening return %d %v", ret.ID, err)
+				return errors.New("failed to release refund")
+			}
+		}
+		return nil
+	})
+}
+
+// settleRefund moves a pending refund to its final status, a refund settled already is left alone
+func settleRefund(tx *gorm.DB, e *domain.Refund, status domain.RefundStatus) error {
+	res := tx.Model(&domain.Refund{}).
+		Where("id = ? AND status = ?", e.ID, domain.RefundStatusPending).
+		Updates(map[string]interface{}{
+			"status":             status,
+			"provider_refund_id": e.ProviderRefundId,
+			"response":           e.Response,
+			"updated_at":         time.Now(),
+		})
+	if res.Error != nil {
+		log.Printf("error on saving refund %v", res.Error)
+		return errors.New("failed to update refund")
+	}
+	if res.RowsAffected == 0 {
+		return ErrRefundSettled
+	}
+	e.Status = status
+	return nil
+}
+
+// FindRefunds implements [RefundRepository].
+func (r *refundRepository) FindRefunds(orderId uint) ([]domain.Refund, error) {
+	var refunds []domain.Refund
+	err := r.db.Where("order_id=?", orderId).Order("created_at, id").Find(&refunds).Error
+	if err != nil {
+		log.Printf("error on fetching refunds %v", err)
+		return nil, errors.New("failed to fetch refunds")
+	}
+	return refunds, nil
+}
+
+// FindRefundByProviderId implements [RefundRepository].
+func (r *refundRepository) FindRefundByProviderId(providerRefundId string) (*domain.Refund, error) {
+	var refund domain.Refund
+	err := r.db.Where("provider_refund_id = ?", providerRefundId).First(&refund).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// FindRefundById implements [RefundRepository].
+func (r *refundRepository) FindRefundById(id uint) (*domain.Refund, error) {
+	var refund domain.Refund
+	err := r.db.First(&refund, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// orderItemTotal is the SQL twin of domain.OrderItem.Total
+const orderItemTotal = "price * qty - discount + CASE WHEN tax_inclusive THEN 0 ELSE tax_amount END"
+
+func refundGuard(res *gorm.DB, msg string) error {
+	if res.Error != nil {
+		log.Printf("error on reserving refund %v", res.Error)
+		return errors.New("failed to create refund")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{
+		db: db,
+	}
+}
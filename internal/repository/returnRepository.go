@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReturnRepository interface {
+	CreateReturn(r *domain.ReturnRequest) error
+	FindReturnById(id uint) (*domain.ReturnRequest, error)
+	FindReturns(f dto.ReturnFilter, p dto.PaginationRequest) ([]domain.ReturnRequest, int64, error)
+	UpdateReturnStatus(r *domain.ReturnRequest, e domain.ReturnEvent, restock []domain.StockMovement) error
+	UpdateReturnRefund(r *domain.ReturnRequest) error
+	FindDeliveredAt(orderItemId uint) (*time.Time, error)
+}
+
+type returnRepository struct {
+	db *gorm.DB
+}
+
+// CreateReturn implements [ReturnRepository].
+// The order item is locked while the open returns are counted, so concurrent requests
+// cannot return more units than were bought.
+func (r *returnRepository) CreateReturn(e *domain.ReturnRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		var item domain.OrderItem
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, e.OrderItemId).Error; err != nil {
+			log.Printf("error on fetching order item %v", err)
+			return errors.New("order item does not exist")
+		}
+
+		var held int64
+		err := tx.Model(&domain.ReturnRequest{}).
+			Where("order_item_id = ? AND status NOT IN ?", e.OrderItemId, []domain.ReturnStatus{domain.ReturnStatusRejected, domain.ReturnStatusCancelled}).
+			Select("COALESCE(SUM(qty), 0)").
+			Scan(&held).Error
+		if err != nil {
+			log.Printf("error on counting returns %v", err)
+			return errors.New("failed to create return")
+		}
+		if uint(held)+e.Qty > item.Qty {
+			return errors.New("quantity exceeds what is left to return of the item")
+		}
+
+		e.Status = domain.ReturnStatusRequested
+		if err := tx.Create(e).Error; err != nil {
+			log.Printf("error on creating return %v", err)
+			return errors.New("failed to create return")
+		}
+
+		event := domain.ReturnEvent{
+			ReturnId:  e.ID,
+			ToStatus:  domain.ReturnStatusRequested,
+			ActorId:   e.BuyerId,
+			ActorRole: domain.OrderActorBuyer,
+			Note:      e.Note,
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			log.Printf("error on creating return event %v", err)
+			return errors.New("failed to create return")
+		}
+		return nil
+	})
+}
+
+// FindReturnById implements [ReturnRepository].
+func (r *returnRepository) FindReturnById(id uint) (*domain.ReturnRequest, error) {
+	var ret domain.ReturnRequest
+	err := r.db.Preload("Photos", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		First(&ret, id).Error
+	if err != nil {
+		log.Printf("error on fetching return %v", err)
+		return nil, errors.New("return does not exist")
+	}
+	return &ret, nil
+}
+
+// FindReturns implements [ReturnRepository].
+func (r *returnRepository) FindReturns(f dto.ReturnFilter, p dto.PaginationRequest) ([]domain.ReturnRequest, int64, error) {
+	query := r.db.Model(&domain.ReturnRequest{})
+	if f.BuyerId > 0 {
+		query = query.Where("buyer_id = ?", f.BuyerId)
+	}
+	if f.SellerId > 0 {
+		query = query.Where("seller_id = ?", f.SellerId)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("error on counting returns %v", err)
+		return nil, 0, errors.New("failed to fetch returns")
+	}
+
+	returns := make([]domain.ReturnRequest, 0)
+	err := query.Preload("Photos", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("created_at DESC, id DESC").
+		Offset(p.Offset()).
+		Limit(p.Limit).
+		Find(&returns).Error
+	if err != nil {
+		log.Printf("error on fetching returns %v", err)
+		return nil, 0, errors.New("failed to fetch returns")
+	}
+	return returns, total, nil
+}
+
+// UpdateReturnStatus implements [ReturnRepository].
+// The change only applies if the return is still in e.FromStatus. The restock movements
+// are applied in the same transaction.
+func (r *returnRepository) UpdateReturnStatus(ret *domain.ReturnRequest, e domain.ReturnEvent, restock []domain.StockMovement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.ReturnRequest{}).
+			Where("id = ? AND status = ?", ret.ID, e.FromStatus).
+			Updates(map[string]interface{}{
+				"status":     e.ToStatus,
+				"restocked":  ret.Restocked,
+				"updated_at": time.Now(),
+			})
+		if res.Error != nil {
+			log.Printf("error on updating return status %v", res.Error)
+			return errors.New("failed to update return")
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("return was changed by another request, please retry")
+		}
+
+		for _, m := range restock {
+			if _, err := changeStock(tx, m); err != nil {
+				return err
+			}
+		}
+
+		e.ReturnId = ret.ID
+		if err := tx.Create(&e).Error; err != nil {
+			log.Printf("error on creating return event %v", err)
+			return errors.New("failed to update return")
+		}
+
+		ret.Status = e.ToStatus
+		return nil
+	})
+}
+
+// UpdateReturnRefund implements [ReturnRepository].
+func (r *returnRepository) UpdateReturnRefund(ret *domain.ReturnRequest) error {
+	err := r.db.Model(&domain.ReturnRequest{}).
+		Where("id = ?", ret.ID).
+		Updates(map[string]interface{}{
+			"refund_id":     ret.RefundId,
+			"refund_amount": ret.RefundAmount,
+		}).Error
+	if err != nil {
+		log.Printf("error on updating return refund %v", err)
+		return errors.New("failed to update return")
+	}
+	return nil
+}
+
+// FindDeliveredAt implements [ReturnRepository].
+// It is nil when the item was not delivered through a shipment.
+func (r *returnRepository) FindDeliveredAt(orderItemId uint) (*time.Time, error) {
+	var deliveredAt *time.Time
+	err := r.db.Model(&domain.Shipment{}).
+		Select("shipments.delivered_at").
+		Joins("JOIN shipment_items si ON si.shipment_id = shipments.id").
+		Where("si.order_item_id = ? AND shipments.delivered_at IS NOT NULL", orderItemId).
+		Limit(1).
+		Scan(&deliveredAt).Error
+	if err != nil {
+		log.Printf("error on fetching delivery date %v", err)
+		return nil, errors.New("failed to fetch delivery date")
+	}
+	return deliveredAt, nil
+}
+
+func NewReturnRepository(db *gorm.DB) ReturnRepository {
+	return &returnRepository{
+		db: db,
+	}
+}
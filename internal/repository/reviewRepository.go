@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReviewRepository interface {
+	CreateReview(r *domain.Review) error
+	UpdateReview(r *domain.Review) error
+	FindReviewById(id uint) (*domain.Review, error)
+	FindUserReview(userId uint, productId uint) (*domain.Review, error)
+	FindReviews(productId uint, filter dto.ReviewFilter) ([]domain.Review, int64, error)
+	SetSellerReply(id uint, reply string) error
+	FlagReview(f domain.ReviewFlag) (*domain.Review, error)
+	// FindDeliveredItem returns a delivered order item of the product bought by the user
+	FindDeliveredItem(userId uint, productId uint) (*domain.OrderItem, error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func (r *reviewRepository) CreateReview(review *domain.Review) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return err
+		}
+		return updateProductRating(tx, review.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to create review, you may have reviewed this product already")
+	}
+	return nil
+}
+
+func (r *reviewRepository) UpdateReview(review *domain.Review) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(review).Select("rating", "title", "body").Updates(review).Error
+		if err != nil {
+			return err
+		}
+		return updateProductRating(tx, review.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to update review")
+	}
+	return nil
+}
+
+func (r *reviewRepository) FindReviewById(id uint) (*domain.Review, error) {
+	var review domain.Review
+	if err := r.db.First(&review, id).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("review does not exist")
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) FindUserReview(userId uint, productId uint) (*domain.Review, error) {
+	var review domain.Review
+	err := r.db.Where("user_id = ? AND product_id = ?", userId, productId).First(&review).Error
+	if err != nil {
+		return nil, errors.New("review does not exist")
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) FindReviews(productId uint, filter dto.ReviewFilter) ([]domain.Review, int64, error) {
+	query := r.db.Model(&domain.Review{}).Where("product_id = ? AND NOT hidden", productId)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch reviews")
+	}
+
+	reviews := make([]domain.Review, 0)
+	err := query.
+		Order(reviewOrder(filter.Sort)).
+		Offset(filter.Offset()).
+		Limit(filter.Limit).
+		Find(&reviews).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch reviews")
+	}
+	return reviews, total, nil
+}
+
+// reviewOrder maps a sort option to an ORDER BY clause
+func reviewOrder(sort string) string {
+	switch sort {
+	case dto.ReviewSortOldest:
+		return "created_at ASC, id ASC"
+	case dto.ReviewSortRatingDesc:
+		return "rating DESC, created_at DESC, id DESC"
+	case dto.ReviewSortRatingAsc:
+		return "rating ASC, created_at DESC, id DESC"
+	default:
+		return "created_at DESC, id DESC"
+	}
+}
+
+func (r *reviewRepository) SetSellerReply(id uint, reply string) error {
+	now := time.Now()
+	err := r.db.Model(&domain.Review{ID: id}).Select("seller_reply", "replied_at").
+		Updates(domain.Review{SellerReply: reply, RepliedAt: &now}).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to save reply")
+	}
+	return nil
+}
+
+// FlagReview records an abuse report, a user flagging the same review twice counts once.
+// Reaching ReviewFlagThreshold hides the review and takes it out of the product rating.
+func (r *reviewRepository) FlagReview(f domain.ReviewFlag) (*domain.Review, error) {
+	var review domain.Review
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&f)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected > 0 {
+			err := tx.Model(&domain.Review{}).Where("id = ?", f.ReviewId).Updates(map[string]interface{}{
+				"flag_count": gorm.Expr("flag_count + 1"),
+				"hidden":     gorm.Expr("hidden OR flag_count + 1 >= ?", domain.ReviewFlagThreshold),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.First(&review, f.ReviewId).Error; err != nil {
+			return err
+		}
+		return updateProductRating(tx, review.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to flag review")
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) FindDeliveredItem(userId uint, productId uint) (*domain.OrderItem, error) {
+	var item domain.OrderItem
+	err := r.db.Model(&domain.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("LEFT JOIN sub_orders ON sub_orders.id = order_items.sub_order_id").
+		Where("orders.user_id = ? AND order_items.product_id = ?", userId, productId).
+		Where("COALESCE(sub_orders.status, orders.status) = ? AND order_items.status <> ?", domain.OrderStatusDelivered, domain.OrderStatusRefunded).
+		Order("order_items.id DESC").
+		First(&item).Error
+	if err != nil {
+		return nil, errors.New("you can only review products that were delivered to you")
+	}
+	return &item, nil
+}
+
+// updateProductRating recomputes the cached rating of a product from its visible reviews
+func updateProductRating(tx *gorm.DB, productId uint) error {
+	return tx.Exec(`UPDATE products SET
+		rating_avg = COALESCE((SELECT ROUND(AVG(rating)::numeric, 2) FROM reviews WHERE product_id = @id AND NOT hidden), 0),
+		rating_count = (SELECT COUNT(*) FROM reviews WHERE product_id = @id AND NOT hidden)
+		WHERE id = @id`, map[string]interface{}{"id": productId}).Error
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrTokenReused is returned when a refresh token is exchanged a second time
+var ErrTokenReused = errors.New("refresh token has already been used")
+
+type SessionRepository interface {
+	CreateSession(s *domain.Session, t *domain.RefreshToken) error
+	FindSession(id string) (*domain.Session, error)
+	FindRefreshToken(hash string) (*domain.RefreshToken, error)
+	RotateRefreshToken(old *domain.RefreshToken, next *domain.RefreshToken) error
+	RevokeSession(id string) error
+	RevokeUserSessions(userId uint) error
+	IsSessionActive(id string) bool
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// CreateSession implements [SessionRepository].
+func (r *sessionRepository) CreateSession(s *domain.Session, t *domain.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(s).Error; err != nil {
+			log.Printf("error on creating session %v", err)
+			return errors.New("failed to create session")
+		}
+		t.SessionId = s.ID
+		if err := tx.Create(t).Error; err != nil {
+			log.Printf("error on creating refresh token %v", err)
+			return errors.New("failed to create session")
+		}
+		return nil
+	})
+}
+
+// FindSession implements [SessionRepository].
+func (r *sessionRepository) FindSession(id string) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, errors.New("session does not exist")
+	}
+	return &session, nil
+}
+
+// FindRefreshToken implements [SessionRepository].
+func (r *sessionRepository) FindRefreshToken(hash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, errors.New("refresh token does not exist")
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken implements [SessionRepository].
+// The old token is only marked used if nobody used it before, so of two requests racing
+// with the same token one gets ErrTokenReused.
+func (r *sessionRepository) RotateRefreshToken(old *domain.RefreshToken, next *domain.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", old.ID).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			log.Printf("error on rotating refresh token %v", res.Error)
+			return errors.New("failed to refresh token")
+		}
+		if res.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+
+		next.SessionId = old.SessionId
+		if err := tx.Create(next).Error; err != nil {
+			log.Printf("error on creating refresh token %v", err)
+			return errors.New("failed to refresh token")
+		}
+		return nil
+	})
+}
+
+// RevokeSession implements [SessionRepository].
+func (r *sessionRepository) RevokeSession(id string) error {
+	err := r.db.Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		log.Printf("error on revoking session %v", err)
+		return errors.New("failed to revoke session")
+	}
+	return nil
+}
+
+// RevokeUserSessions implements [SessionRepository].
+func (r *sessionRepository) RevokeUserSessions(userId uint) error {
+	err := r.db.Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		log.Printf("error on revoking sessions %v", err)
+		return errors.New("failed to revoke sessions")
+	}
+	return nil
+}
+
+// IsSessionActive implements [SessionRepository].
+// It is also what the auth middleware checks every access token against.
+func (r *sessionRepository) IsSessionActive(id string) bool {
+	var count int64
+	err := r.db.Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("error on checking session %v", err)
+		return false
+	}
+	return count > 0
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
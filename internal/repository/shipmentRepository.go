@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+type ShipmentRepository interface {
+	CreateShipment(s *domain.Shipment) error
+	FindShipmentById(id uint) (*domain.Shipment, error)
+	FindOrderShipments(orderId uint, sellerId uint) ([]domain.Shipment, error)
+	AddShipmentEvent(s *domain.Shipment, e domain.ShipmentEvent) error
+}
+
+type shipmentRepository struct {
+	db *gorm.DB
+}
+
+// unshippedItemStatuses are the order item states a shipment can pick items from
+var unshippedItemStatuses = []domain.OrderStatus{domain.OrderStatusPaid, domain.OrderStatusProcessing}
+
+// CreateShipment implements [ShipmentRepository].
+// The items are marked shipped only if none of them shipped in the meantime, so two
+// concurrent shipments cannot both take the same item.
+func (r *shipmentRepository) CreateShipment(s *domain.Shipment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		ids := s.OrderItemIds()
+		res := tx.Model(&domain.OrderItem{}).
+			Where("id IN ? AND order_id = ? AND seller_id = ? AND status IN ?", ids, s.OrderId, s.SellerId, unshippedItemStatuses).
+			Update("status", domain.OrderStatusShipped)
+		if res.Error != nil {
+			log.Printf("db_err: %v", res.Error)
+			return errors.New("failed to create shipment")
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return errors.New("some items were already shipped, please retry")
+		}
+
+		if err := tx.Create(s).Error; err != nil {
+			log.Printf("db_err: %v", err)
+			return errors.New("failed to create shipment")
+		}
+		return nil
+	})
+}
+
+// FindShipmentById implements [ShipmentRepository].
+func (r *shipmentRepository) FindShipmentById(id uint) (*domain.Shipment, error) {
+	var shipment domain.Shipment
+	err := r.db.Preload("Items").Preload("Events", shipmentEventOrder).First(&shipment, id).Error
+	if err != nil {
+		return nil, errors.New("shipment does not exist")
+	}
+	return &shipment, nil
+}
+
+// FindOrderShipments implements [ShipmentRepository]. A sellerId of 0 returns the
+// shipments of every seller.
+func (r *shipmentRepository) FindOrderShipments(orderId uint, sellerId uint) ([]domain.Shipment, error) {
+	query := r.db.Preload("Items").Preload("Events", shipmentEventOrder).Where("order_id = ?", orderId)
+	if sellerId > 0 {
+		query = query.Where("seller_id = ?", sellerId)
+	}
+
+	shipments := make([]domain.Shipment, 0)
+	if err := query.Order("shipped_at, id").Find(&shipments).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to fetch shipments")
+	}
+	return shipments, nil
+}
+
+// AddShipmentEvent implements [ShipmentRepository].
+// The shipment only moves if it is still in the status it was read with. A delivered
+// shipment marks its items delivered.
+func (r *shipmentRepository) AddShipmentEvent(s *domain.Shipment, e domain.ShipmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": e.Status}
+		if e.Status == domain.ShipmentStatusDelivered {
+			updates["delivered_at"] = e.OccurredAt
+		}
+
+		res := tx.Model(&domain.Shipment{}).Where("id = ? AND status = ?", s.ID, s.Status).Updates(updates)
+		if res.Error != nil {
+			log.Printf("db_err: %v", res.Error)
+			return errors.New("failed to update shipment")
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("shipment was changed by another request, please retry")
+		}
+
+		e.ShipmentId = s.ID
+		if err := tx.Create(&e).Error; err != nil {
+			log.Printf("db_err: %v", err)
+			return errors.New("failed to update shipment")
+		}
+
+		if e.Status == domain.ShipmentStatusDelivered {
+			err := tx.Model(&domain.OrderItem{}).
+				Where("id IN ? AND status = ?", s.OrderItemIds(), domain.OrderStatusShipped).
+				Update("status", domain.OrderStatusDelivered).Error
+			if err != nil {
+				log.Printf("db_err: %v", err)
+				return errors.New("failed to update shipment")
+			}
+			s.DeliveredAt = &e.OccurredAt
+		}
+
+		s.Status = e.Status
+		s.Events = append(s.Events, e)
+		return nil
+	})
+}
+
+// shipmentEventOrder preloads a shipment timeline oldest first
+func shipmentEventOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("occurred_at, id")
+}
+
+func NewShipmentRepository(db *gorm.DB) ShipmentRepository {
+	return &shipmentRepository{
+		db: db,
+	}
+}
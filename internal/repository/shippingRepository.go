@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ShippingRepository interface {
+	CreateMethod(m *domain.ShippingMethod) error
+	UpdateMethod(m *domain.ShippingMethod) error
+	FindMethodById(id uint) (*domain.ShippingMethod, error)
+	FindSellerMethods(sellerId uint) ([]domain.ShippingMethod, error)
+	FindActiveMethods(sellerIds []uint) ([]domain.ShippingMethod, error)
+
+	// the methods a buyer picked, one per seller in the cart
+	SetCartShipping(userId uint, sellerId uint, methodId uint) error
+	FindCartShipping(userId uint) ([]domain.CartShipping, error)
+}
+
+type shippingRepository struct {
+	db *gorm.DB
+}
+
+func (r *shippingRepository) CreateMethod(m *domain.ShippingMethod) error {
+	if err := r.db.Create(m).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to create shipping method")
+	}
+	return nil
+}
+
+func (r *shippingRepository) UpdateMethod(m *domain.ShippingMethod) error {
+	if err := r.db.Save(m).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to update shipping method")
+	}
+	return nil
+}
+
+func (r *shippingRepository) FindMethodById(id uint) (*domain.ShippingMethod, error) {
+	var method domain.ShippingMethod
+	if err := r.db.First(&method, id).Error; err != nil {
+		return nil, errors.New("shipping method does not exist")
+	}
+	return &method, nil
+}
+
+func (r *shippingRepository) FindSellerMethods(sellerId uint) ([]domain.ShippingMethod, error) {
+	methods := make([]domain.ShippingMethod, 0)
+	if err := r.db.Where("seller_id = ?", sellerId).Order("id").Find(&methods).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to fetch shipping methods")
+	}
+	return methods, nil
+}
+
+func (r *shippingRepository) FindActiveMethods(sellerIds []uint) ([]domain.ShippingMethod, error) {
+	methods := make([]domain.ShippingMethod, 0)
+	if len(sellerIds) == 0 {
+		return methods, nil
+	}
+	err := r.db.Where("seller_id IN ? AND active = ?", sellerIds, true).Order("seller_id, id").Find(&methods).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to fetch shipping methods")
+	}
+	return methods, nil
+}
+
+func (r *shippingRepository) SetCartShipping(userId uint, sellerId uint, methodId uint) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "seller_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"method_id", "updated_at"}),
+	}).Create(&domain.CartShipping{UserId: userId, SellerId: sellerId, MethodId: methodId}).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to select shipping method")
+	}
+	return nil
+}
+
+func (r *shippingRepository) FindCartShipping(userId uint) ([]domain.CartShipping, error) {
+	selected := make([]domain.CartShipping, 0)
+	if err := r.db.Where("user_id = ?", userId).Find(&selected).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to fetch shipping selection")
+	}
+	return selected, nil
+}
+
+func NewShippingRepository(db *gorm.DB) ShippingRepository {
+	return &shippingRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// changeStock applies m.Delta to the stock of the product, or of its variant when m.VariantId
+// is set, and writes m to the ledger with the resulting balance. It must run inside a
+// transaction. Stock is never allowed to go negative.
+func changeStock(tx *gorm.DB, m domain.StockMovement) (int, error) {
+
+	// RETURNING fills the model with the stock after the update
+	var product domain.Product
+	var variant domain.ProductVariant
+
+	query := tx.Model(&product).Where("id = ?", m.ProductId)
+	if m.VariantId > 0 {
+		query = tx.Model(&variant).Where("id = ? AND product_id = ?", m.VariantId, m.ProductId)
+	}
+	if m.Delta < 0 {
+		query = query.Where("stock >= ?", -m.Delta)
+	}
+
+	res := query.
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "stock"}}}).
+		Update("stock", gorm.Expr("stock + ?", m.Delta))
+	if res.Error != nil {
+		log.Printf("error on changing stock %v", res.Error)
+		return 0, errors.New("failed to update stock")
+	}
+	if res.RowsAffected == 0 {
+		if m.VariantId > 0 {
+			return 0, fmt.Errorf("insufficient stock for product %d variant %d", m.ProductId, m.VariantId)
+		}
+		return 0, fmt.Errorf("insufficient stock for product %d", m.ProductId)
+	}
+
+	balance := product.Stock
+	if m.VariantId > 0 {
+		balance = variant.Stock
+	}
+	return balance, recordMovement(tx, m, balance)
+}
+
+// currentStock reads the stock a movement applies to
+func currentStock(tx *gorm.DB, productId uint, variantId uint) (int, error) {
+	var stock int
+	query := tx.Model(&domain.Product{}).Where("id = ?", productId)
+	if variantId > 0 {
+		query = tx.Model(&domain.ProductVariant{}).Where("id = ? AND product_id = ?", variantId, productId)
+	}
+	if err := query.Select("stock").Scan(&stock).Error; err != nil {
+		log.Printf("error on fetching stock %v", err)
+		return 0, errors.New("failed to fetch stock")
+	}
+	return stock, nil
+}
+
+// recordMovement writes a ledger entry for a change that has already been applied
+func recordMovement(tx *gorm.DB, m domain.StockMovement, balance int) error {
+	m.ID = 0
+	m.Balance = balance
+	if err := tx.Create(&m).Error; err != nil {
+		log.Printf("error on recording stock movement %v", err)
+		return errors.New("failed to record stock movement")
+	}
+	return nil
+}
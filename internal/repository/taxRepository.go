@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+type TaxRepository interface {
+	CreateTaxRate(t *domain.TaxRate) error
+	UpdateTaxRate(t *domain.TaxRate) error
+	DeleteTaxRate(id uint) error
+	FindTaxRateById(id uint) (*domain.TaxRate, error)
+	FindTaxRates(p dto.PaginationRequest) ([]domain.TaxRate, int64, error)
+	FindTaxRatesForCountry(country string) ([]domain.TaxRate, error)
+}
+
+type taxRepository struct {
+	db *gorm.DB
+}
+
+func (r *taxRepository) CreateTaxRate(t *domain.TaxRate) error {
+	if err := r.db.Create(t).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to create tax rate")
+	}
+	return nil
+}
+
+func (r *taxRepository) UpdateTaxRate(t *domain.TaxRate) error {
+	if err := r.db.Save(t).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("failed to update tax rate")
+	}
+	return nil
+}
+
+func (r *taxRepository) DeleteTaxRate(id uint) error {
+	result := r.db.Delete(&domain.TaxRate{}, id)
+	if result.Error != nil {
+		log.Printf("db_err: %v", result.Error)
+		return errors.New("failed to delete tax rate")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("tax rate does not exist")
+	}
+	return nil
+}
+
+func (r *taxRepository) FindTaxRateById(id uint) (*domain.TaxRate, error) {
+	var rate domain.TaxRate
+	if err := r.db.First(&rate, id).Error; err != nil {
+		return nil, errors.New("tax rate does not exist")
+	}
+	return &rate, nil
+}
+
+func (r *taxRepository) FindTaxRates(p dto.PaginationRequest) ([]domain.TaxRate, int64, error) {
+	var total int64
+	if err := r.db.Model(&domain.TaxRate{}).Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch tax rates")
+	}
+
+	rates := make([]domain.TaxRate, 0)
+	err := r.db.Order("country, region, postcode_prefix, tax_class, id").Offset(p.Offset()).Limit(p.Limit).Find(&rates).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("failed to fetch tax rates")
+	}
+	return rates, total, nil
+}
+
+func (r *taxRepository) FindTaxRatesForCountry(country string) ([]domain.TaxRate, error) {
+	rates := make([]domain.TaxRate, 0)
+	if err := r.db.Where("country = ?", country).Find(&rates).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to fetch tax rates")
+	}
+	return rates, nil
+}
+
+func NewTaxRepository(db *gorm.DB) TaxRepository {
+	return &taxRepository{
+		db: db,
+	}
+}
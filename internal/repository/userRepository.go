@@ -15,6 +15,9 @@ type UserRepository interface {
 	FindUser(email string) (domain.User, error)
 	FindUserById(id uint) (domain.User, error)
 	UpdateUser(id uint, u domain.User) (domain.User, error)
+	VerifyUser(id uint, channel domain.VerifyChannel) error
+	BackfillPhoneVerified() (int64, error)
+	PromoteAdmins(emails []string) (int64, error)
 
 	//more function will come as we progress
 
@@ -22,7 +25,7 @@ type UserRepository interface {
 
 	//cart
 	FindCartItems(uId uint) ([]domain.Cart, error)
-	FindCartItem(uId uint, pId uint) (domain.Cart, error)
+	FindCartItem(uId uint, pId uint, vId uint) (domain.Cart, error)
 	CreateCart(c domain.Cart) error
 	UpdateCart(c domain.Cart) error
 	DeleteCartById(id uint) error
@@ -32,6 +35,7 @@ type UserRepository interface {
 	CreateOrder(o domain.Order) error
 	FindOrders(uId uint) ([]domain.Order, error)
 	FindOrderById(id uint, uId uint) (domain.Order, error)
+	FindOrderByRef(ref string) (domain.Order, error)
 
 	//profile
 	CreateProfile(e domain.Address) error
@@ -43,8 +47,15 @@ type userRepository struct {
 }
 
 // CreateOrder implements [UserRepository].
+// Items and shipping are created with the order and then linked to their sub-order.
 func (r *userRepository) CreateOrder(o domain.Order) error {
-	err := r.db.Create(&o).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		subOrders := o.SubOrders
+		if err := tx.Omit("SubOrders").Create(&o).Error; err != nil {
+			return err
+		}
+		return createSubOrders(tx, o.ID, subOrders)
+	})
 	if err != nil {
 		log.Printf("error on creating order %v", err)
 		return errors.New("failed to create order in database")
@@ -57,16 +68,30 @@ func (r *userRepository) CreateOrder(o domain.Order) error {
 func (r *userRepository) FindOrderById(id uint, uId uint) (domain.Order, error) {
 
 	var order domain.Order
-	err := r.db.Preload("items").Where("id=? AND user_id=?").First(&order).Error
+	err := r.db.Preload("Items").
+		Preload("Shipping").
+		Preload("SubOrders", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("SubOrders.Shipping").
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB { return db.Order("shipped_at, id") }).
+		Preload("Shipments.Items").
+		Preload("Shipments.Events", shipmentEventOrder).
+		Where("id=? AND user_id=?", id, uId).First(&order).Error
 	if err != nil {
 		log.Printf("error on fetching orders %v", err)
-		return domain.Order{}, errors.New("failed to fetch orders")
+		return domain.Order{}, errors.New("order does not exist")
 
 	}
 
 	return order, nil
 }
 
+// FindOrderByRef implements [UserRepository].
+func (r *userRepository) FindOrderByRef(ref string) (domain.Order, error) {
+	var order domain.Order
+	err := r.db.Where("order_ref_number=?", ref).Find(&order).Error
+	return order, err
+}
+
 // FindOrders implements [UserRepository].
 func (r *userRepository) FindOrders(uId uint) ([]domain.Order, error) {
 	var orders []domain.Order
@@ -121,9 +146,9 @@ func (r *userRepository) DeleteCartItems(uId uint) error {
 }
 
 // FindCartItem implements UserRepository.
-func (r *userRepository) FindCartItem(uId uint, pId uint) (domain.Cart, error) {
+func (r *userRepository) FindCartItem(uId uint, pId uint, vId uint) (domain.Cart, error) {
 	cartItem := domain.Cart{}
-	err := r.db.Where("user_id=? AND product_id=?", uId).Find(&cartItem).Error
+	err := r.db.Where("user_id=? AND product_id=? AND variant_id=?", uId, pId, vId).Find(&cartItem).Error
 	return cartItem, err
 
 }
@@ -192,6 +217,46 @@ func (r *userRepository) FindUserById(id uint) (domain.User, error) {
 }
 
 // ✔ matches interface: UpdatedUser(id uint, u domain.User)
+// VerifyUser marks a channel of the user verified and uses up the pending code
+func (r *userRepository) VerifyUser(id uint, channel domain.VerifyChannel) error {
+	column := "phone_verified"
+	if channel == domain.VerifyChannelEmail {
+		column = "email_verified"
+	}
+	err := r.db.Model(&domain.User{}).Where("id=?", id).Updates(map[string]interface{}{
+		column:         true,
+		"verified":     true,
+		"code":         "",
+		"code_channel": "",
+	}).Error
+	if err != nil {
+		log.Printf("verify user error %v", err)
+		return errors.New("unable to verify user")
+	}
+	return nil
+}
+
+// BackfillPhoneVerified carries the verification of users who confirmed their phone before
+// channels were tracked separately
+func (r *userRepository) BackfillPhoneVerified() (int64, error) {
+	res := r.db.Model(&domain.User{}).
+		Where("verified AND NOT phone_verified AND NOT email_verified").
+		Update("phone_verified", true)
+	return res.RowsAffected, res.Error
+}
+
+// PromoteAdmins makes the users with these emails admins. Only verified emails count, so
+// whoever signs up first with an admin email does not get the role.
+func (r *userRepository) PromoteAdmins(emails []string) (int64, error) {
+	if len(emails) == 0 {
+		return 0, nil
+	}
+	res := r.db.Model(&domain.User{}).
+		Where("email IN ? AND email_verified AND user_type <> ?", emails, domain.ADMIN).
+		Update("user_type", domain.ADMIN)
+	return res.RowsAffected, res.Error
+}
+
 func (r *userRepository) UpdateUser(id uint, u domain.User) (domain.User, error) {
 	var user domain.User
 	if err := r.db.First(&user, id).Error; err != nil {
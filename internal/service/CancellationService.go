@@ -0,0 +1,223 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"log"
+	"time"
+)
+
+// CancellationService cancels orders before they ship. The sub-orders concerned are closed,
+// their stock goes back on sale, the buyer is refunded and the other party is told.
+type CancellationService struct {
+	Repo      repository.CancellationRepository
+	OrderSvc  *OrderService
+	RefundSvc *RefundService
+	Inventory *InventoryService
+	UserRepo  repository.UserRepository
+	Notifier  *NotificationService
+	Config    config.AppConfig
+	Auth      helper.Auth
+}
+
+func NewCancellationService(r repository.CancellationRepository, orderSvc *OrderService, refundSvc *RefundService, inventory *InventoryService, userRepo repository.UserRepository, notifier *NotificationService, cfg config.AppConfig, auth helper.Auth) *CancellationService {
+	return &CancellationService{
+		Repo:      r,
+		OrderSvc:  orderSvc,
+		RefundSvc: refundSvc,
+		Inventory: inventory,
+		UserRepo:  userRepo,
+		Notifier:  notifier,
+		Config:    cfg,
+		Auth:      auth,
+	}
+}
+
+// Cancel cancels an order on behalf of a party to it. A seller rejects their own sub-order,
+// a buyer cancels the sub-order subOrderId, or every group still open when it is 0.
+// All groups are checked before any is cancelled.
+func (s CancellationService) Cancel(u domain.User, actor domain.OrderActor, orderId uint, subOrderId uint, input dto.CancelOrderRequest) ([]domain.OrderCancellation, error) {
+
+	reason := domain.CancelReason(input.Reason)
+	if !reason.AllowedFor(actor) {
+		return nil, fmt.Errorf("reason must be one of %v", domain.CancelReasons[actor])
+	}
+
+	order, err := s.OrderSvc.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkWindow(order, actor); err != nil {
+		return nil, err
+	}
+
+	groups, err := cancelGroups(order, u.ID, actor, subOrderId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if err := checkCancellable(order, &groups[i], actor); err != nil {
+			return nil, err
+		}
+	}
+
+	cancellations := make([]domain.OrderCancellation, 0, len(groups))
+	for i := range groups {
+		c, err := s.cancelSubOrder(u, actor, order, &groups[i], reason, input.Note)
+		if c != nil {
+			cancellations = append(cancellations, *c)
+		}
+		if err != nil {
+			return cancellations, err
+		}
+	}
+	return cancellations, nil
+}
+
+// GetCancellations lists the cancellations of an order, sellers only see their own sub-order
+func (s CancellationService) GetCancellations(u domain.User, actor domain.OrderActor, orderId uint) ([]domain.OrderCancellation, error) {
+	order, err := s.OrderSvc.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+	cancellations, err := s.Repo.FindCancellations(orderId)
+	if err != nil || actor != domain.OrderActorSeller {
+		return cancellations, err
+	}
+
+	own := make([]domain.OrderCancellation, 0, len(cancellations))
+	if so, ok := order.FindSubOrder(u.ID); ok {
+		for _, c := range cancellations {
+			if c.SubOrderId == so.ID {
+				own = append(own, c)
+			}
+		}
+	}
+	return own, nil
+}
+
+// cancelSubOrder closes one group. Once the status has changed the cancellation stands, a
+// failed restock or refund is logged and reported but does not undo it.
+func (s CancellationService) cancelSubOrder(u domain.User, actor domain.OrderActor, order *domain.Order, so *domain.SubOrder, reason domain.CancelReason, note string) (*domain.OrderCancellation, error) {
+
+	history := fmt.Sprintf("cancelled by %s: %s", actor, reason)
+	if note != "" {
+		history += ", " + note
+	}
+	// only the items that had not shipped are cancelled, they alone go back on sale and are refunded
+	items, err := s.OrderSvc.CancelSubOrder(order, so, u.ID, actor, history)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Inventory.Restock(order.OrderRefNumber, u.ID, items, "order cancelled"); err != nil {
+		log.Printf("order %d: stock of sub-order %d not restored: %v", order.ID, so.ID, err)
+	}
+
+	c := &domain.OrderCancellation{
+		OrderId:    order.ID,
+		SubOrderId: so.ID,
+		ActorId:    u.ID,
+		ActorRole:  actor,
+		Reason:     reason,
+		Note:       note,
+	}
+
+	refund, refundErr := s.RefundSvc.RefundSubOrder(order, so, items, u.ID, "order cancelled: "+string(reason))
+	if refundErr == nil && refund != nil {
+		c.RefundId = refund.ID
+		c.RefundAmount = refund.Amount
+	}
+
+	if err := s.Repo.CreateCancellation(c); err != nil {
+		log.Printf("order %d: cancellation of sub-order %d not recorded: %v", order.ID, so.ID, err)
+	}
+	s.notify(order, so, actor, reason)
+
+	if refundErr != nil {
+		log.Printf("order %d: refund of cancelled sub-order %d failed: %v", order.ID, so.ID, refundErr)
+		return c, fmt.Errorf("order was cancelled but the refund failed: %v", refundErr)
+	}
+	return c, nil
+}
+
+// checkWindow applies the configured time limit of the actor, counted from checkout
+func (s CancellationService) checkWindow(order *domain.Order, actor domain.OrderActor) error {
+	window := s.Config.CancelSellerWindow
+	if actor == domain.OrderActorBuyer {
+		window = s.Config.CancelBuyerWindow
+	}
+	if window > 0 && time.Since(order.CreatedAt) > window {
+		return fmt.Errorf("orders can only be cancelled within %s of checkout", window)
+	}
+	return nil
+}
+
+// notify tells the other party about the cancellation over their channels
+func (s CancellationService) notify(order *domain.Order, so *domain.SubOrder, actor domain.OrderActor, reason domain.CancelReason) {
+
+	recipient, event := so.SellerId, domain.EventOrderCancelledByBuyer
+	if actor == domain.OrderActorSeller {
+		recipient, event = order.UserId, domain.EventOrderCancelledBySeller
+	}
+
+	user, err := s.UserRepo.FindUserById(recipient)
+	if err != nil {
+		return
+	}
+	data := map[string]any{"OrderRef": order.OrderRefNumber, "Reason": reason}
+	if err := s.Notifier.Dispatch(event, user, data); err != nil {
+		log.Printf("order %d: cancellation notice to user %d failed: %v", order.ID, recipient, err)
+	}
+}
+
+// cancelGroups picks the sub-orders a cancel request is about. They are copies, the order's
+// sub-orders are reloaded as each one changes.
+func cancelGroups(order *domain.Order, userId uint, actor domain.OrderActor, subOrderId uint) ([]domain.SubOrder, error) {
+
+	if actor == domain.OrderActorSeller {
+		so, ok := order.FindSubOrder(userId)
+		if !ok {
+			return nil, errors.New("order does not exist")
+		}
+		return []domain.SubOrder{*so}, nil
+	}
+
+	if subOrderId > 0 {
+		for _, so := range order.SubOrders {
+			if so.ID == subOrderId {
+				return []domain.SubOrder{so}, nil
+			}
+		}
+		return nil, errors.New("sub-order does not exist")
+	}
+
+	groups := make([]domain.SubOrder, 0, len(order.SubOrders))
+	for _, so := range order.SubOrders {
+		if so.Status != domain.OrderStatusCancelled && so.Status != domain.OrderStatusRefunded {
+			groups = append(groups, so)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("order is already cancelled")
+	}
+	return groups, nil
+}
+
+// checkCancellable allows cancelling only before anything of the group has shipped
+func checkCancellable(order *domain.Order, so *domain.SubOrder, actor domain.OrderActor) error {
+	if !so.Status.CanTransitionTo(domain.OrderStatusCancelled, actor) {
+		return fmt.Errorf("%s cannot cancel an order that is %s", actor, so.Status)
+	}
+	for _, item := range order.Items {
+		if item.SubOrderId == so.ID && (item.Status == domain.OrderStatusShipped || item.Status == domain.OrderStatusDelivered) {
+			return errors.New("part of the order has already shipped and can no longer be cancelled")
+		}
+	}
+	return nil
+}
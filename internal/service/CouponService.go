@@ -0,0 +1,418 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CouponService manages coupon codes and prices carts with them
+type CouponService struct {
+	Repo        repository.CouponRepository
+	UserRepo    repository.UserRepository
+	CatalogRepo repository.CatalogRepository
+	Auth        helper.Auth
+}
+
+func NewCouponService(r repository.CouponRepository, u repository.UserRepository, c repository.CatalogRepository, auth helper.Auth) *CouponService {
+	return &CouponService{
+		Repo:        r,
+		UserRepo:    u,
+		CatalogRepo: c,
+		Auth:        auth,
+	}
+}
+
+func (s CouponService) CreateCoupon(u domain.User, input dto.CouponRequest) (*domain.Coupon, error) {
+	coupon := &domain.Coupon{Active: true, CreatedBy: u.ID}
+	if err := applyCouponInput(coupon, input); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.CreateCoupon(coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (s CouponService) UpdateCoupon(id uint, input dto.CouponRequest) (*domain.Coupon, error) {
+	coupon, err := s.Repo.FindCouponById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyCouponInput(coupon, input); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.UpdateCoupon(coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// DeactivateCoupon stops a coupon from being applied, past redemptions are kept
+func (s CouponService) DeactivateCoupon(id uint) error {
+	coupon, err := s.Repo.FindCouponById(id)
+	if err != nil {
+		return err
+	}
+	coupon.Active = false
+	return s.Repo.UpdateCoupon(coupon)
+}
+
+func (s CouponService) GetCoupons(p dto.PaginationRequest) (dto.CouponList, error) {
+	p.Normalize()
+	coupons, total, err := s.Repo.FindCoupons(p)
+	if err != nil {
+		return dto.CouponList{}, err
+	}
+	return dto.CouponList{
+		Coupons: coupons,
+		Pagination: dto.PaginationResponse{
+			Page:  p.Page,
+			Limit: p.Limit,
+			Total: total,
+		},
+	}, nil
+}
+
+// applyCouponInput copies a create or update request onto c and validates the result
+func applyCouponInput(c *domain.Coupon, input dto.CouponRequest) error {
+	code := strings.ToUpper(strings.TrimSpace(input.Code))
+	if code == "" {
+		return errors.New("coupon code is required")
+	}
+	if !input.Type.IsValid() {
+		return errors.New("type must be one of percentage, fixed_amount, free_shipping, buy_x_get_y")
+	}
+
+	c.Code = code
+	c.Description = input.Description
+	c.Type = input.Type
+	c.Value = input.Value
+	c.MaxDiscount = input.MaxDiscount
+	c.BuyQty = input.BuyQty
+	c.GetQty = input.GetQty
+	c.MinSpend = input.MinSpend
+	c.StartsAt = input.StartsAt
+	c.EndsAt = input.EndsAt
+	c.UsageLimit = input.UsageLimit
+	c.PerUserLimit = input.PerUserLimit
+	c.CategoryIds = input.CategoryIds
+	c.SellerIds = input.SellerIds
+	if input.Active != nil {
+		c.Active = *input.Active
+	}
+
+	switch c.Type {
+	case domain.CouponTypePercentage:
+		if c.Value <= 0 || c.Value > 100 {
+			return errors.New("percentage value must be between 0 and 100")
+		}
+	case domain.CouponTypeFixedAmount:
+		if c.Value <= 0 {
+			return errors.New("fixed amount value must be greater than 0")
+		}
+	case domain.CouponTypeBuyXGetY:
+		if c.BuyQty < 1 || c.GetQty < 1 {
+			return errors.New("buy_qty and get_qty must be at least 1")
+		}
+	}
+	if c.MaxDiscount < 0 || c.MinSpend < 0 || c.UsageLimit < 0 || c.PerUserLimit < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// ApplyCoupon validates a code against the buyer's cart and keeps it on the cart
+func (s CouponService) ApplyCoupon(userId uint, code string) (dto.CartPricing, error) {
+	coupon, err := s.Repo.FindCouponByCode(strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return dto.CartPricing{}, err
+	}
+	items, err := s.UserRepo.FindCartItems(userId)
+	if err != nil || len(items) == 0 {
+		return dto.CartPricing{}, errors.New("your cart is empty")
+	}
+
+	pricing, err := s.priceWith(coupon, userId, items, 0, true)
+	if err != nil {
+		return dto.CartPricing{}, err
+	}
+	if err := s.Repo.SetCartCoupon(userId, coupon.ID); err != nil {
+		return dto.CartPricing{}, err
+	}
+	return pricing, nil
+}
+
+func (s CouponService) RemoveCoupon(userId uint) error {
+	return s.Repo.DeleteCartCoupon(userId)
+}
+
+// PriceCart prices the cart with the coupon the buyer applied. A coupon that stopped
+// applying (expired, cart changed...) is left out and the reason is reported.
+func (s CouponService) PriceCart(userId uint, items []domain.Cart, shipping float64) dto.CartPricing {
+	coupon, err := s.Repo.FindCartCoupon(userId)
+	if err != nil {
+		return basePricing(items, shipping)
+	}
+
+	pricing, err := s.priceWith(coupon, userId, items, shipping, true)
+	if err != nil {
+		pricing = basePricing(items, shipping)
+		pricing.CouponCode = coupon.Code
+		pricing.CouponError = err.Error()
+	}
+	return pricing
+}
+
+// PriceOrder prices the cart of a payment with the coupon reserved for it. Limits and
+// validity were checked when the payment started, so they are not checked again.
+func (s CouponService) PriceOrder(orderRef string, userId uint, items []domain.Cart, shipping float64) dto.CartPricing {
+	redemption, err := s.Repo.FindRedemption(orderRef)
+	if err != nil || redemption.Status == domain.RedemptionStatusReleased {
+		return basePricing(items, shipping)
+	}
+	coupon, err := s.Repo.FindCouponById(redemption.CouponId)
+	if err != nil {
+		return basePricing(items, shipping)
+	}
+
+	pricing, err := s.priceWith(coupon, userId, items, shipping, false)
+	if err != nil {
+		log.Printf("coupon %s no longer prices order %s: %v", coupon.Code, orderRef, err)
+		return basePricing(items, shipping)
+	}
+	return pricing
+}
+
+// ReserveCoupon holds one use of the priced coupon for a payment
+func (s CouponService) ReserveCoupon(userId uint, orderRef string, pricing dto.CartPricing) error {
+	if pricing.CouponCode == "" || pricing.CouponError != "" {
+		return nil
+	}
+	coupon, err := s.Repo.FindCouponByCode(pricing.CouponCode)
+	if err != nil {
+		return err
+	}
+	return s.Repo.ReserveRedemption(&domain.CouponRedemption{
+		CouponId: coupon.ID,
+		UserId:   userId,
+		OrderRef: orderRef,
+		Amount:   roundAmount(pricing.DiscountTotal + pricing.ShippingDiscount),
+	})
+}
+
+// Redeem makes the coupon use of a paid order final
+func (s CouponService) Redeem(orderRef string) error {
+	return s.Repo.RedeemRedemption(orderRef)
+}
+
+// Release gives back the coupon use of a payment that did not go through
+func (s CouponService) Release(orderRef string) error {
+	return s.Repo.ReleaseRedemption(orderRef)
+}
+
+// priceWith applies the coupon to the cart, checkLimits also enforces validity and usage
+func (s CouponService) priceWith(c *domain.Coupon, userId uint, items []domain.Cart, shipping float64, checkLimits bool) (dto.CartPricing, error) {
+	if checkLimits {
+		if !c.IsRunning(time.Now()) {
+			return dto.CartPricing{}, errors.New("coupon is not active")
+		}
+		if c.UsageLimit > 0 && c.UsedCount >= c.UsageLimit {
+			return dto.CartPricing{}, errors.New("coupon has reached its usage limit")
+		}
+		if c.PerUserLimit > 0 {
+			used, err := s.Repo.CountUserRedemptions(c.ID, userId)
+			if err != nil {
+				return dto.CartPricing{}, err
+			}
+			if used >= int64(c.PerUserLimit) {
+				return dto.CartPricing{}, errors.New("you have already used this coupon the maximum number of times")
+			}
+		}
+	}
+
+	eligible, err := s.eligibleItems(c, items)
+	if err != nil {
+		return dto.CartPricing{}, err
+	}
+	if len(eligible) == 0 {
+		return dto.CartPricing{}, errors.New("coupon does not apply to any item in your cart")
+	}
+	if spend := lineTotal(eligible); spend < c.MinSpend {
+		return dto.CartPricing{}, fmt.Errorf("spend %.2f more on eligible items to use this coupon", c.MinSpend-spend)
+	}
+
+	pricing := basePricing(items, shipping)
+	discount := couponDiscount(c, eligible, shipping)
+	pricing.CouponCode = c.Code
+	pricing.Discounts = append(pricing.Discounts, discount)
+	if c.Type == domain.CouponTypeFreeShipping {
+		pricing.ShippingDiscount = discount.Amount
+	} else {
+		pricing.DiscountTotal = discount.Amount
+	}
+	pricing.UpdateTotal()
+	return pricing, nil
+}
+
+// eligibleItems keeps the cart items in the coupon's seller and category scope,
+// subcategories of a scoped category included
+func (s CouponService) eligibleItems(c *domain.Coupon, items []domain.Cart) ([]domain.Cart, error) {
+	var categories map[uint]bool
+	if len(c.CategoryIds) > 0 {
+		all, err := s.CatalogRepo.FindCategories()
+		if err != nil {
+			return nil, err
+		}
+		categories = categoryScope(c.CategoryIds, all)
+	}
+
+	eligible := make([]domain.Cart, 0, len(items))
+	for _, item := range items {
+		if !c.AppliesToSeller(item.SellerId) {
+			continue
+		}
+		if categories != nil {
+			product, err := s.CatalogRepo.FindProductById(int(item.ProductId))
+			if err != nil || !categories[product.CategoryId] {
+				continue
+			}
+		}
+		eligible = append(eligible, item)
+	}
+	return eligible, nil
+}
+
+// categoryScope expands category ids with all their descendants
+func categoryScope(ids []uint, all []*domain.Category) map[uint]bool {
+	children := map[uint][]uint{}
+	for _, cat := range all {
+		children[cat.ParentId] = append(children[cat.ParentId], cat.ID)
+	}
+
+	scope := map[uint]bool{}
+	queue := append([]uint(nil), ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if scope[id] {
+			continue
+		}
+		scope[id] = true
+		queue = append(queue, children[id]...)
+	}
+	return scope
+}
+
+func basePricing(items []domain.Cart, shipping float64) dto.CartPricing {
+	pricing := dto.CartPricing{
+		Subtotal:      roundAmount(lineTotal(items)),
+		Discounts:     []dto.DiscountBreakdown{},
+		Shipping:      roundAmount(shipping),
+		ShippingLines: []dto.ShippingLine{},
+		TaxLines:      []dto.TaxLine{},
+	}
+	pricing.UpdateTotal()
+	return pricing
+}
+
+func lineTotal(items []domain.Cart) float64 {
+	var total float64
+	for _, item := range items {
+		total += item.Price * float64(item.Qty)
+	}
+	return total
+}
+
+// couponDiscount computes the discount of a coupon over its eligible items
+func couponDiscount(c *domain.Coupon, eligible []domain.Cart, shipping float64) dto.DiscountBreakdown {
+	d := dto.DiscountBreakdown{Code: c.Code, Type: c.Type, Description: c.Description}
+
+	switch c.Type {
+	case domain.CouponTypePercentage:
+		amount := lineTotal(eligible) * c.Value / 100
+		if c.MaxDiscount > 0 {
+			amount = min(amount, c.MaxDiscount)
+		}
+		d.Lines = allocateDiscount(roundAmount(amount), eligible)
+	case domain.CouponTypeFixedAmount:
+		d.Lines = allocateDiscount(roundAmount(min(c.Value, lineTotal(eligible))), eligible)
+	case domain.CouponTypeBuyXGetY:
+		d.Lines = buyXGetYDiscount(c.BuyQty, c.GetQty, eligible)
+	case domain.CouponTypeFreeShipping:
+		d.Amount = roundAmount(shipping)
+		return d
+	}
+
+	for _, l := range d.Lines {
+		d.Amount += l.Amount
+	}
+	d.Amount = roundAmount(d.Amount)
+	return d
+}
+
+// allocateDiscount spreads amount over the items in proportion to their totals, the last
+// item takes the rounding difference so the lines add up exactly
+func allocateDiscount(amount float64, items []domain.Cart) []dto.LineDiscount {
+	total := lineTotal(items)
+	lines := make([]dto.LineDiscount, 0, len(items))
+	if amount <= 0 || total <= 0 {
+		return lines
+	}
+
+	remaining := amount
+	for i, item := range items {
+		share := roundAmount(amount * item.Price * float64(item.Qty) / total)
+		if i == len(items)-1 {
+			share = roundAmount(remaining)
+		}
+		remaining -= share
+		if share > 0 {
+			lines = append(lines, lineDiscount(item, share))
+		}
+	}
+	return lines
+}
+
+// buyXGetYDiscount walks the units from the most to the least expensive in groups of
+// buy+get, the units after the first buy of every group are free
+func buyXGetYDiscount(buy, get int, items []domain.Cart) []dto.LineDiscount {
+	sorted := append([]domain.Cart(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Price > sorted[j].Price })
+
+	group := buy + get
+	freeBefore := func(n int) int {
+		return (n/group)*get + max(n%group-buy, 0)
+	}
+
+	lines := make([]dto.LineDiscount, 0)
+	position := 0
+	for _, item := range sorted {
+		qty := int(item.Qty)
+		free := freeBefore(position+qty) - freeBefore(position)
+		position += qty
+		if free > 0 {
+			lines = append(lines, lineDiscount(item, roundAmount(item.Price*float64(free))))
+		}
+	}
+	return lines
+}
+
+func lineDiscount(item domain.Cart, amount float64) dto.LineDiscount {
+	return dto.LineDiscount{
+		CartItemId: item.ID,
+		ProductId:  item.ProductId,
+		Name:       item.Name,
+		Amount:     amount,
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"go-ecommerce-app/config"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/repository"
+	"log"
+	"time"
+)
+
+// InventoryService reserves stock while a payment is pending
+type InventoryService struct {
+	InventoryRepo repository.InventoryRepository
+	Config        config.AppConfig
+}
+
+func NewInventoryService(r repository.InventoryRepository, cfg config.AppConfig) *InventoryService {
+	return &InventoryService{
+		InventoryRepo: r,
+		Config:        cfg,
+	}
+}
+
+// ReserveCart holds stock for every cart line under the order reference of a new payment
+func (s InventoryService) ReserveCart(uId uint, orderRef string, cartItems []domain.Cart) error {
+
+	if len(cartItems) == 0 {
+		return errors.New("cart is empty")
+	}
+
+	expiresAt := time.Now().Add(s.Config.StockReservationTTL)
+	reservations := make([]domain.StockReservation, 0, len(cartItems))
+	for _, item := range cartItems {
+		reservations = append(reservations, domain.StockReservation{
+			OrderRef:  orderRef,
+			UserId:    uId,
+			ProductId: item.ProductId,
+			VariantId: item.VariantId,
+			Qty:       item.Qty,
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	return s.InventoryRepo.ReserveStock(reservations)
+}
+
+// IsReserved reports whether the order reference still holds its stock
+func (s InventoryService) IsReserved(orderRef string) bool {
+	reservations, err := s.InventoryRepo.FindReservations(orderRef)
+	if err != nil || len(reservations) == 0 {
+		return false
+	}
+	for _, res := range reservations {
+		if res.Status != domain.ReservationStatusActive || time.Now().After(res.ExpiresAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// Commit turns the reservation into a sale once the order exists
+func (s InventoryService) Commit(orderRef string) error {
+	return s.InventoryRepo.CommitReservations(orderRef)
+}
+
+// Release gives the stock back when the payment fails or is abandoned
+func (s InventoryService) Release(orderRef string) error {
+	return s.InventoryRepo.ReleaseReservations(orderRef)
+}
+
+// Restock puts the units of the order items back on sale under the order reference
+func (s InventoryService) Restock(orderRef string, actorId uint, items []domain.OrderItem, reason string) error {
+	movements := make([]domain.StockMovement, 0, len(items))
+	for _, item := range items {
+		movements = append(movements, domain.StockMovement{
+			ProductId: item.ProductId,
+			VariantId: item.VariantId,
+			Type:      domain.StockMovementCancel,
+			Qty:       item.Qty,
+			Delta:     int(item.Qty),
+			Reason:    reason,
+			Reference: orderRef,
+			ActorId:   actorId,
+		})
+	}
+	if len(movements) == 0 {
+		return nil
+	}
+	return s.InventoryRepo.RestoreStock(movements)
+}
+
+// StartExpiryWorker periodically releases reservations whose payment never completed, onExpired
+// is called with each released order reference to settle the rest of its checkout
+func (s InventoryService) StartExpiryWorker(interval time.Duration, onExpired func(orderRef string)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			released, err := s.InventoryRepo.ReleaseExpired(time.Now())
+			if err != nil {
+				log.Printf("stock reservation expiry failed: %v", err)
+				continue
+			}
+			if len(released) > 0 {
+				log.Printf("released the expired stock reservations of %d checkouts", len(released))
+			}
+			for _, orderRef := range released {
+				onExpired(orderRef)
+			}
+		}
+	}()
+}
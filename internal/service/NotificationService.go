@@ -0,0 +1,388 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/pkg/notification"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// NotificationService renders the stored template of an event and sends it over the
+// recipient's channels, logging every attempt
+type NotificationService struct {
+	Repo   repository.NotificationRepository
+	Client notification.NotificationClient
+	Auth   helper.Auth
+}
+
+func NewNotificationService(r repository.NotificationRepository, client notification.NotificationClient, auth helper.Auth) *NotificationService {
+	return &NotificationService{
+		Repo:   r,
+		Client: client,
+		Auth:   auth,
+	}
+}
+
+// DefaultNotificationTemplates are stored at startup for every event that has none yet
+var DefaultNotificationTemplates = []domain.NotificationTemplate{
+	{
+		Event:   domain.EventVerificationCode,
+		Locale:  domain.DefaultLocale,
+		Subject: "Verify your {{.Channel}}",
+		Text:    "Your verification code is {{.Code}}, it expires in {{.Minutes}} minutes",
+		HTML:    "<p>Your verification code is <strong>{{.Code}}</strong>, it expires in {{.Minutes}} minutes.</p>",
+	},
+	{
+		Event:   domain.EventPasswordReset,
+		Locale:  domain.DefaultLocale,
+		Subject: "Reset your password",
+		Text:    "Your password reset code is {{.Code}}, it expires in {{.Minutes}} minutes",
+		HTML:    "<p>Your password reset code is <strong>{{.Code}}</strong>, it expires in {{.Minutes}} minutes.</p><p>If you did not ask for it, you can ignore this email.</p>",
+	},
+	{
+		Event:   domain.EventOrderCancelledByBuyer,
+		Locale:  domain.DefaultLocale,
+		Subject: "Order {{.OrderRef}} was cancelled",
+		Text:    "Order {{.OrderRef}} was cancelled by the buyer ({{.Reason}}), do not ship it",
+		HTML:    "<p>Order <strong>{{.OrderRef}}</strong> was cancelled by the buyer ({{.Reason}}), do not ship it.</p>",
+	},
+	{
+		Event:   domain.EventOrderCancelledBySeller,
+		Locale:  domain.DefaultLocale,
+		Subject: "Items of your order {{.OrderRef}} were cancelled",
+		Text:    "Items of your order {{.OrderRef}} were cancelled by the seller ({{.Reason}}), your payment for them will be refunded",
+		HTML:    "<p>Items of your order <strong>{{.OrderRef}}</strong> were cancelled by the seller ({{.Reason}}), your payment for them will be refunded.</p>",
+	},
+}
+
+// notificationSamples is the data each event is rendered with, templates are checked
+// against it before they are saved
+var notificationSamples = map[domain.NotificationEvent]map[string]any{
+	domain.EventVerificationCode:       {"Code": "123456", "Minutes": 30, "Channel": domain.VerifyChannelEmail},
+	domain.EventPasswordReset:          {"Code": "123456", "Minutes": 15},
+	domain.EventOrderCancelledByBuyer:  {"OrderRef": "100001", "Reason": domain.CancelReasonChangedMind},
+	domain.EventOrderCancelledBySeller: {"OrderRef": "100001", "Reason": domain.CancelReasonOutOfStock},
+}
+
+// message is a template rendered for one recipient
+type message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Dispatch tells a user about an event over the channels they opted into, or only over the
+// given channels for messages that must not go anywhere else, such as one-time codes.
+// Every attempt is logged. It fails when the message reached none of the channels.
+func (s NotificationService) Dispatch(event domain.NotificationEvent, user domain.User, data map[string]any, only ...domain.NotificationChannel) error {
+
+	pref, err := s.Repo.FindPreference(user.ID)
+	if err != nil {
+		return err
+	}
+
+	channels := only
+	if len(channels) == 0 {
+		channels = pref.Channels()
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	templates, err := s.Repo.FindTemplates(event, pref.Locale, domain.DefaultLocale)
+	if err != nil {
+		return err
+	}
+
+	vars := map[string]any{"Name": user.FirstName}
+	for k, v := range data {
+		vars[k] = v
+	}
+
+	var lastErr error
+	delivered := false
+	for _, channel := range channels {
+		delivery := &domain.NotificationDelivery{UserId: user.ID, Event: event, Channel: channel}
+		err := s.send(delivery, templates, pref, user, vars)
+
+		switch {
+		case err != nil:
+			delivery.Status = domain.DeliveryStatusFailed
+			delivery.Error = err.Error()
+			lastErr = err
+			log.Printf("notification %s over %s to user %d failed: %v", event, channel, user.ID, err)
+		case delivery.Status == domain.DeliveryStatusSkipped:
+			lastErr = errors.New(delivery.Error)
+		default:
+			delivery.Status = domain.DeliveryStatusSent
+			delivered = true
+		}
+
+		if err := s.Repo.CreateDelivery(delivery); err != nil {
+			log.Printf("notification %s over %s to user %d: %v", event, channel, user.ID, err)
+		}
+	}
+
+	if !delivered {
+		return fmt.Errorf("notification could not be delivered: %w", lastErr)
+	}
+	return nil
+}
+
+// send renders and delivers over delivery.Channel. A recipient without an address for the
+// channel is marked skipped.
+func (s NotificationService) send(delivery *domain.NotificationDelivery, templates []domain.NotificationTemplate, pref *domain.NotificationPreference, user domain.User, vars map[string]any) error {
+
+	switch delivery.Channel {
+	case domain.ChannelSMS:
+		delivery.Recipient = user.Phone
+	case domain.ChannelEmail:
+		delivery.Recipient = user.Email
+	case domain.ChannelWebhook:
+		delivery.Recipient = pref.WebhookUrl
+	case domain.ChannelInApp:
+	default:
+		return fmt.Errorf("unknown channel %q", delivery.Channel)
+	}
+	if delivery.Recipient == "" && delivery.Channel != domain.ChannelInApp {
+		delivery.Status = domain.DeliveryStatusSkipped
+		delivery.Error = fmt.Sprintf("no %s address on file", delivery.Channel)
+		return nil
+	}
+
+	tpl, err := pickTemplate(templates, delivery.Channel, pref.Locale)
+	if err != nil {
+		return err
+	}
+	msg, err := renderTemplate(tpl, vars)
+	if err != nil {
+		return err
+	}
+
+	switch delivery.Channel {
+	case domain.ChannelSMS:
+		return s.Client.SendSMS(user.Phone, msg.Text)
+	case domain.ChannelEmail:
+		return s.Client.SendEmail(notification.Email{To: user.Email, Subject: msg.Subject, Body: msg.Text, HTML: msg.HTML})
+	case domain.ChannelWebhook:
+		body, err := json.Marshal(map[string]any{
+			"event":      delivery.Event,
+			"user_id":    user.ID,
+			"subject":    msg.Subject,
+			"text":       msg.Text,
+			"html":       msg.HTML,
+			"created_at": time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		return s.Client.SendWebhook(pref.WebhookUrl, pref.WebhookSecret, body)
+	default:
+		return s.Repo.CreateNotification(&domain.Notification{
+			UserId:  user.ID,
+			Event:   delivery.Event,
+			Subject: msg.Subject,
+			Body:    msg.Text,
+		})
+	}
+}
+
+// pickTemplate prefers the user's locale over the default one and, within a locale, the
+// channel's own template over the shared one
+func pickTemplate(templates []domain.NotificationTemplate, channel domain.NotificationChannel, locale string) (*domain.NotificationTemplate, error) {
+	for _, l := range []string{locale, domain.DefaultLocale} {
+		for _, c := range []domain.NotificationChannel{channel, ""} {
+			for i := range templates {
+				if templates[i].Locale == l && templates[i].Channel == c {
+					return &templates[i], nil
+				}
+			}
+		}
+	}
+	return nil, errors.New("no template for the event")
+}
+
+// renderTemplate fills in the subject and text as plain text and the html escaped.
+// Unknown keys are an error rather than "<no value>" in a message.
+func renderTemplate(tpl *domain.NotificationTemplate, vars map[string]any) (message, error) {
+	var msg message
+	var err error
+
+	if msg.Subject, err = renderText("subject", tpl.Subject, vars); err != nil {
+		return msg, err
+	}
+	if msg.Text, err = renderText("text", tpl.Text, vars); err != nil {
+		return msg, err
+	}
+	if tpl.HTML == "" {
+		return msg, nil
+	}
+
+	t, err := htmltemplate.New("html").Option("missingkey=error").Parse(tpl.HTML)
+	if err != nil {
+		return msg, fmt.Errorf("html template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return msg, fmt.Errorf("html template: %w", err)
+	}
+	msg.HTML = b.String()
+	return msg, nil
+}
+
+func renderText(name string, text string, vars map[string]any) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%s template: %w", name, err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("%s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+func (s NotificationService) GetTemplates() ([]domain.NotificationTemplate, error) {
+	return s.Repo.ListTemplates()
+}
+
+// SaveTemplate creates or replaces a template once it renders with the event's data
+func (s NotificationService) SaveTemplate(input dto.NotificationTemplateRequest) (*domain.NotificationTemplate, error) {
+	tpl := &domain.NotificationTemplate{
+		Event:   domain.NotificationEvent(input.Event),
+		Channel: domain.NotificationChannel(input.Channel),
+		Locale:  strings.ToLower(strings.TrimSpace(input.Locale)),
+		Subject: input.Subject,
+		Text:    input.Text,
+		HTML:    input.HTML,
+	}
+	if tpl.Locale == "" {
+		tpl.Locale = domain.DefaultLocale
+	}
+
+	if !tpl.Event.IsValid() {
+		return nil, errors.New("event is not valid")
+	}
+	if tpl.Channel != "" && !tpl.Channel.IsValid() {
+		return nil, errors.New("channel must be sms, email, in_app or webhook")
+	}
+	if strings.TrimSpace(tpl.Text) == "" {
+		return nil, errors.New("text is required")
+	}
+
+	sample := map[string]any{"Name": "Jane"}
+	for k, v := range notificationSamples[tpl.Event] {
+		sample[k] = v
+	}
+	if _, err := renderTemplate(tpl, sample); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.SaveTemplate(tpl); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+func (s NotificationService) DeleteTemplate(id uint) error {
+	return s.Repo.DeleteTemplate(id)
+}
+
+func (s NotificationService) GetPreference(userId uint) (*domain.NotificationPreference, error) {
+	return s.Repo.FindPreference(userId)
+}
+
+// SavePreference replaces the user's channels. A new secret is issued whenever the webhook
+// url changes.
+func (s NotificationService) SavePreference(userId uint, input dto.NotificationPreferenceRequest) (*domain.NotificationPreference, error) {
+	pref, err := s.Repo.FindPreference(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookUrl := strings.TrimSpace(input.WebhookUrl)
+	if webhookUrl != "" {
+		if err := notification.CheckWebhookURL(webhookUrl); err != nil {
+			return nil, err
+		}
+	}
+	if input.Webhook && webhookUrl == "" {
+		return nil, errors.New("webhook url is required to receive webhooks")
+	}
+	if webhookUrl != pref.WebhookUrl {
+		pref.WebhookSecret = ""
+		if webhookUrl != "" {
+			if pref.WebhookSecret, err = newWebhookSecret(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	pref.SMS = input.SMS
+	pref.Email = input.Email
+	pref.InApp = input.InApp
+	pref.Webhook = input.Webhook
+	pref.WebhookUrl = webhookUrl
+	pref.Locale = strings.ToLower(strings.TrimSpace(input.Locale))
+	if pref.Locale == "" {
+		pref.Locale = domain.DefaultLocale
+	}
+
+	if err := s.Repo.SavePreference(pref); err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
+
+// GetNotifications lists the in-app inbox, newest first
+func (s NotificationService) GetNotifications(userId uint, unreadOnly bool, p dto.PaginationRequest) (*dto.NotificationList, error) {
+	p.Normalize()
+	notifications, total, err := s.Repo.FindNotifications(userId, unreadOnly, p)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.NotificationList{
+		Notifications: notifications,
+		Pagination:    dto.PaginationResponse{Page: p.Page, Limit: p.Limit, Total: total},
+	}, nil
+}
+
+func (s NotificationService) MarkRead(userId uint, id uint) error {
+	return s.Repo.MarkNotificationRead(id, userId)
+}
+
+// GetDeliveries lists logged send attempts, newest first
+func (s NotificationService) GetDeliveries(f dto.DeliveryFilter, p dto.PaginationRequest) (*dto.DeliveryList, error) {
+	if f.Status != "" && f.Status != domain.DeliveryStatusSent && f.Status != domain.DeliveryStatusFailed && f.Status != domain.DeliveryStatusSkipped {
+		return nil, errors.New("status must be sent, failed or skipped")
+	}
+	p.Normalize()
+	deliveries, total, err := s.Repo.FindDeliveries(f, p)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.DeliveryList{
+		Deliveries: deliveries,
+		Pagination: dto.PaginationResponse{Page: p.Page, Limit: p.Limit, Total: total},
+	}, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
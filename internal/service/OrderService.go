@@ -0,0 +1,398 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"slices"
+)
+
+// OrderService owns the order lifecycle. Every seller has a sub-order in the order that moves
+// on its own, the parent order status is derived from them.
+type OrderService struct {
+	OrderRepo repository.OrderRepository
+	Auth      helper.Auth
+}
+
+func NewOrderService(r repository.OrderRepository, auth helper.Auth) *OrderService {
+	return &OrderService{
+		OrderRepo: r,
+		Auth:      auth,
+	}
+}
+
+// UpdateStatus moves an order on behalf of a buyer or seller after checking they are a party to it.
+// A seller moves their own sub-order, a buyer moves every sub-order still in progress.
+func (s OrderService) UpdateStatus(u domain.User, actor domain.OrderActor, orderId uint, input dto.UpdateOrderStatusRequest) (*domain.Order, error) {
+
+	order, err := s.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	to := domain.OrderStatus(input.Status)
+	if err := checkManualCancel(to, actor); err != nil {
+		return nil, err
+	}
+	if actor == domain.OrderActorSeller {
+		so, ok := order.FindSubOrder(u.ID)
+		if !ok {
+			return nil, errors.New("order does not exist")
+		}
+		if err := s.TransitionSubOrder(order, so, to, u.ID, actor, input.Note); err != nil {
+			return nil, err
+		}
+		return sellerOrder(order, u.ID), nil
+	}
+
+	if err := s.transitionAll(order, to, u.ID, actor, input.Note); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// UpdateSubOrderStatus lets a buyer move the group of a single seller, e.g. to confirm its
+// delivery without touching the others
+func (s OrderService) UpdateSubOrderStatus(u domain.User, actor domain.OrderActor, orderId uint, subOrderId uint, input dto.UpdateOrderStatusRequest) (*domain.Order, error) {
+
+	if err := checkManualCancel(domain.OrderStatus(input.Status), actor); err != nil {
+		return nil, err
+	}
+
+	order, err := s.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range order.SubOrders {
+		so := &order.SubOrders[i]
+		if so.ID != subOrderId {
+			continue
+		}
+		if err := s.TransitionSubOrder(order, so, domain.OrderStatus(input.Status), u.ID, actor, input.Note); err != nil {
+			return nil, err
+		}
+		return order, nil
+	}
+	return nil, errors.New("sub-order does not exist")
+}
+
+// Transition validates and applies a status change to the parent order, recording it in the
+// order history. It is only used directly for orders without sub-orders.
+// actorId is 0 for system initiated changes.
+func (s OrderService) Transition(order *domain.Order, to domain.OrderStatus, actorId uint, actor domain.OrderActor, note string) error {
+
+	if !to.IsValid() {
+		return fmt.Errorf("unknown order status %q", to)
+	}
+
+	from := order.Status
+	if !from.CanTransitionTo(to, actor) {
+		return fmt.Errorf("%s cannot move order from %s to %s", actor, from, to)
+	}
+
+	return s.OrderRepo.UpdateOrderStatus(order, domain.OrderStatusHistory{
+		FromStatus: from,
+		ToStatus:   to,
+		ActorId:    actorId,
+		ActorRole:  actor,
+		Note:       note,
+	})
+}
+
+// TransitionSubOrder validates and applies a status change to one seller's sub-order, then
+// brings the parent order in line
+func (s OrderService) TransitionSubOrder(order *domain.Order, so *domain.SubOrder, to domain.OrderStatus, actorId uint, actor domain.OrderActor, note string) error {
+	_, err := s.transitionSubOrder(order, so, to, actorId, actor, note)
+	return err
+}
+
+// CancelSubOrder cancels one seller's sub-order like TransitionSubOrder and returns the items
+// that were cancelled with it, items that shipped in the meantime are left out
+func (s OrderService) CancelSubOrder(order *domain.Order, so *domain.SubOrder, actorId uint, actor domain.OrderActor, note string) ([]domain.OrderItem, error) {
+
+	ids, err := s.transitionSubOrder(order, so, domain.OrderStatusCancelled, actorId, actor, note)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]domain.OrderItem, 0, len(ids))
+	for _, item := range order.Items {
+		if slices.Contains(ids, item.ID) {
+			item.Status = domain.OrderStatusCancelled
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s OrderService) transitionSubOrder(order *domain.Order, so *domain.SubOrder, to domain.OrderStatus, actorId uint, actor domain.OrderActor, note string) ([]uint, error) {
+
+	if !to.IsValid() {
+		return nil, fmt.Errorf("unknown order status %q", to)
+	}
+
+	from := so.Status
+	if !from.CanTransitionTo(to, actor) {
+		return nil, fmt.Errorf("%s cannot move order from %s to %s", actor, from, to)
+	}
+
+	cancelled, err := s.OrderRepo.UpdateSubOrderStatus(so, domain.OrderStatusHistory{
+		FromStatus: from,
+		ToStatus:   to,
+		ActorId:    actorId,
+		ActorRole:  actor,
+		Note:       note,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, s.syncOrderStatus(order, note)
+}
+
+// transitionAll moves every sub-order that is not already there. Cancelled and refunded groups
+// that cannot make the move are skipped, any other group that cannot stops the whole change.
+func (s OrderService) transitionAll(order *domain.Order, to domain.OrderStatus, actorId uint, actor domain.OrderActor, note string) error {
+
+	if len(order.SubOrders) == 0 {
+		return s.Transition(order, to, actorId, actor, note)
+	}
+	if !to.IsValid() {
+		return fmt.Errorf("unknown order status %q", to)
+	}
+
+	moving := make([]*domain.SubOrder, 0, len(order.SubOrders))
+	for i := range order.SubOrders {
+		so := &order.SubOrders[i]
+		switch {
+		case so.Status == to:
+		case so.Status.CanTransitionTo(to, actor):
+			moving = append(moving, so)
+		case so.Status == domain.OrderStatusCancelled || so.Status == domain.OrderStatusRefunded:
+		default:
+			return fmt.Errorf("%s cannot move the items of seller %d from %s to %s", actor, so.SellerId, so.Status, to)
+		}
+	}
+	if len(moving) == 0 {
+		return fmt.Errorf("%s cannot move order from %s to %s", actor, order.Status, to)
+	}
+
+	for _, so := range moving {
+		_, err := s.OrderRepo.UpdateSubOrderStatus(so, domain.OrderStatusHistory{
+			FromStatus: so.Status,
+			ToStatus:   to,
+			ActorId:    actorId,
+			ActorRole:  actor,
+			Note:       note,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.syncOrderStatus(order, note)
+}
+
+// syncOrderStatus sets the parent order to the status derived from its sub-orders. They are
+// read again so concurrent changes by other sellers are taken into account.
+func (s OrderService) syncOrderStatus(order *domain.Order, note string) error {
+
+	latest, err := s.OrderRepo.FindOrderById(order.ID)
+	if err != nil {
+		return err
+	}
+	order.Status = latest.Status
+	order.SubOrders = latest.SubOrders
+
+	statuses := make([]domain.OrderStatus, 0, len(order.SubOrders))
+	for _, so := range order.SubOrders {
+		statuses = append(statuses, so.Status)
+	}
+
+	to := domain.AggregateStatus(statuses)
+	if to == order.Status {
+		return nil
+	}
+	return s.OrderRepo.UpdateOrderStatus(order, domain.OrderStatusHistory{
+		FromStatus: order.Status,
+		ToStatus:   to,
+		ActorRole:  domain.OrderActorSystem,
+		Note:       note,
+	})
+}
+
+// TransitionById is used by internal flows (payments, refunds) that only hold an order id,
+// every sub-order is moved
+func (s OrderService) TransitionById(orderId uint, to domain.OrderStatus, note string) error {
+	order, err := s.OrderRepo.FindOrderById(orderId)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureSubOrders(order); err != nil {
+		return err
+	}
+	return s.transitionAll(order, to, 0, domain.OrderActorSystem, note)
+}
+
+// GetOrderHistory returns the history of the whole order to the buyer and of their own
+// sub-order to a seller
+func (s OrderService) GetOrderHistory(u domain.User, actor domain.OrderActor, orderId uint) ([]domain.OrderStatusHistory, error) {
+	order, err := s.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if actor == domain.OrderActorSeller {
+		if so, ok := order.FindSubOrder(u.ID); ok {
+			return s.OrderRepo.FindSubOrderHistory(so.ID)
+		}
+	}
+	return s.OrderRepo.FindOrderHistory(orderId)
+}
+
+// findOrderFor loads an order and verifies the user is its buyer, or a seller of one of its items
+func (s OrderService) findOrderFor(u domain.User, actor domain.OrderActor, orderId uint) (*domain.Order, error) {
+
+	order, err := s.OrderRepo.FindOrderById(orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch actor {
+	case domain.OrderActorBuyer:
+		if order.UserId != u.ID {
+			return nil, errors.New("order does not exist")
+		}
+	case domain.OrderActorSeller:
+		if !hasSellerItems(order, u.ID) {
+			return nil, errors.New("order does not exist")
+		}
+	case domain.OrderActorAdmin:
+	default:
+		return nil, errors.New("order does not exist")
+	}
+
+	if err := s.ensureSubOrders(order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// checkManualCancel keeps buyers and sellers on the cancellation flow, which also restocks
+// and refunds
+func checkManualCancel(to domain.OrderStatus, actor domain.OrderActor) error {
+	if to == domain.OrderStatusCancelled && (actor == domain.OrderActorBuyer || actor == domain.OrderActorSeller) {
+		return errors.New("use the cancel endpoint to cancel an order")
+	}
+	return nil
+}
+
+func hasSellerItems(order *domain.Order, sellerId uint) bool {
+	for _, item := range order.Items {
+		if item.SellerId == sellerId {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureSubOrders splits orders placed before sub-orders existed, their groups start in the
+// status of the order
+func (s OrderService) ensureSubOrders(order *domain.Order) error {
+	if len(order.SubOrders) > 0 || len(order.Items) == 0 {
+		return nil
+	}
+	if err := s.OrderRepo.CreateSubOrders(order.ID, buildSubOrders(order.Items, order.Shipping, 0, order.Status)); err != nil {
+		return err
+	}
+
+	latest, err := s.OrderRepo.FindOrderById(order.ID)
+	if err != nil {
+		return err
+	}
+	*order = *latest
+	return nil
+}
+
+// buildSubOrders groups order items by seller with their totals. The shipping discount is
+// shared by the groups in proportion to their shipping cost.
+func buildSubOrders(items []domain.OrderItem, shipping []domain.OrderShipping, shippingDiscount float64, status domain.OrderStatus) []domain.SubOrder {
+	subOrders := make([]domain.SubOrder, 0)
+	for _, item := range items {
+		so, ok := findSubOrder(subOrders, item.SellerId)
+		if !ok {
+			subOrders = append(subOrders, domain.SubOrder{SellerId: item.SellerId, Status: status})
+			so = &subOrders[len(subOrders)-1]
+		}
+		so.Subtotal += item.Price * float64(item.Qty)
+		so.DiscountAmount += item.Discount
+		so.TaxAmount += item.TaxAmount
+		so.Total += item.Total()
+	}
+
+	var shippingTotal float64
+	for _, line := range shipping {
+		if so, ok := findSubOrder(subOrders, line.SellerId); ok {
+			so.ShippingAmount += line.Cost
+			shippingTotal += line.Cost
+		}
+	}
+
+	remaining := shippingDiscount
+	for i := range subOrders {
+		so := &subOrders[i]
+		share := 0.0
+		if shippingTotal > 0 {
+			share = roundAmount(shippingDiscount * so.ShippingAmount / shippingTotal)
+		}
+		if i == len(subOrders)-1 {
+			share = roundAmount(remaining)
+		}
+		remaining -= share
+
+		so.DiscountAmount = roundAmount(so.DiscountAmount + share)
+		so.Subtotal = roundAmount(so.Subtotal)
+		so.TaxAmount = roundAmount(so.TaxAmount)
+		so.ShippingAmount = roundAmount(so.ShippingAmount)
+		so.Total = roundAmount(so.Total + so.ShippingAmount - share)
+	}
+	return subOrders
+}
+
+func findSubOrder(subOrders []domain.SubOrder, sellerId uint) (*domain.SubOrder, bool) {
+	for i := range subOrders {
+		if subOrders[i].SellerId == sellerId {
+			return &subOrders[i], true
+		}
+	}
+	return nil, false
+}
+
+// sellerOrder is the part of an order a seller may see: their own items, sub-order and
+// shipping, with the status and totals of their sub-order
+func sellerOrder(order *domain.Order, sellerId uint) *domain.Order {
+	view := *order
+	view.Items = make([]domain.OrderItem, 0)
+	for _, item := range order.Items {
+		if item.SellerId == sellerId {
+			view.Items = append(view.Items, item)
+		}
+	}
+	view.SubOrders = make([]domain.SubOrder, 0)
+	if so, ok := order.FindSubOrder(sellerId); ok {
+		view.SubOrders = append(view.SubOrders, *so)
+		view.Status = so.Status
+		view.Amount = so.Total
+		view.DiscountAmount = so.DiscountAmount
+		view.TaxAmount = so.TaxAmount
+		view.ShippingAmount = so.ShippingAmount
+	}
+	view.Shipping = make([]domain.OrderShipping, 0)
+	for _, line := range order.Shipping {
+		if line.SellerId == sellerId {
+			view.Shipping = append(view.Shipping, line)
+		}
+	}
+	return &view
+}
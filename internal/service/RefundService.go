@@ -0,0 +1,271 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/pkg/payment"
+	"log"
+	"math"
+)
+
+type RefundService struct {
+	RefundRepo      repository.RefundRepository
+	TransactionRepo repository.TransactionRepository
+	OrderSvc        *OrderService
+	PaymentClient   payment.PaymentClient
+	Auth            helper.Auth
+}
+
+func NewRefundService(r repository.RefundRepository, t repository.TransactionRepository, orderSvc *OrderService, pc payment.PaymentClient, auth helper.Auth) *RefundService {
+	return &RefundService{
+		RefundRepo:      r,
+		TransactionRepo: t,
+		OrderSvc:        orderSvc,
+		PaymentClient:   pc,
+		Auth:            auth,
+	}
+}
+
+// CreateRefund refunds a whole order or one of its items, fully or partially.
+// Sellers may only refund their own items; admins may refund anything.
+func (s RefundService) CreateRefund(u domain.User, actor domain.OrderActor, orderId uint, input dto.CreateRefundRequest) (*domain.Refund, error) {
+
+	order, err := s.OrderSvc.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.TransactionRepo.FindPaymentByPaymentId(order.PaymentId)
+	if err != nil {
+		return nil, errors.New("payment for the order does not exist")
+	}
+	if !p.Status.IsCaptured() {
+		return nil, errors.New("payment was not captured, nothing to refund")
+	}
+
+	// what is still refundable at the requested level
+	refundable := p.Amount - p.RefundedAmount
+
+	if input.OrderItemId > 0 {
+		item, err := findItem(order, input.OrderItemId)
+		if err != nil {
+			return nil, err
+		}
+		if actor == domain.OrderActorSeller && item.SellerId != u.ID {
+			return nil, errors.New("you can only refund your own items")
+		}
+		refundable = math.Min(refundable, item.Total()-item.RefundedAmount)
+	} else if actor == domain.OrderActorSeller {
+		for _, item := range order.Items {
+			if item.SellerId != u.ID {
+				return nil, errors.New("order has items from other sellers, refund per item instead")
+			}
+		}
+	}
+
+	amount := roundAmount(input.Amount)
+	if amount == 0 {
+		amount = roundAmount(refundable)
+	}
+	if amount <= 0 {
+		return nil, errors.New("refund amount must be greater than zero")
+	}
+	if amount > roundAmount(refundable) {
+		return nil, fmt.Errorf("refund amount exceeds the refundable amount of %.2f", refundable)
+	}
+
+	refund := &domain.Refund{
+		PaymentId:   p.ID,
+		OrderId:     order.ID,
+		OrderItemId: input.OrderItemId,
+		Amount:      amount,
+		Reason:      input.Reason,
+		RequestedBy: u.ID,
+	}
+	return s.issueRefund(p, refund)
+}
+
+// RefundSubOrder pays back the cancelled items of a sub-order. When they are all of its items
+// what is left of the sub-order, shipping included, is refunded.
+// It returns nil when the sub-order was never captured or has been refunded already.
+func (s RefundService) RefundSubOrder(order *domain.Order, so *domain.SubOrder, items []domain.OrderItem, requestedBy uint, reason string) (*domain.Refund, error) {
+
+	p, err := s.TransactionRepo.FindPaymentByPaymentId(order.PaymentId)
+	if err != nil || !p.Status.IsCaptured() {
+		return nil, nil
+	}
+
+	owed := so.Total - so.RefundedAmount
+	groupItems := 0
+	for _, item := range order.Items {
+		if item.SubOrderId == so.ID {
+			groupItems++
+		}
+	}
+	if len(items) < groupItems {
+		itemsTotal := 0.0
+		for _, item := range items {
+			itemsTotal += item.Total() - item.RefundedAmount
+		}
+		owed = math.Min(owed, itemsTotal)
+	}
+
+	amount := roundAmount(math.Min(owed, p.Amount-p.RefundedAmount))
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	refund := &domain.Refund{
+		PaymentId:   p.ID,
+		OrderId:     order.ID,
+		SubOrderId:  so.ID,
+		Amount:      amount,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+	}
+	return s.issueRefund(p, refund)
+}
+
+// issueRefund reserves the refund, sends it to the payment provider and records the outcome
+func (s RefundService) issueRefund(p *domain.Payment, refund *domain.Refund) (*domain.Refund, error) {
+
+	// hold the amount first so concurrent requests cannot over refund
+	if err := s.RefundRepo.ReserveRefund(refund); err != nil {
+		return nil, err
+	}
+
+	amount := refund.Amount
+	result, err := s.PaymentClient.RefundPayment(p.PaymentId, amount, refund.Reason, refund.IdempotencyKey())
+	if err != nil {
+		// the provider may still have paid it out, the amount stays reserved until a webhook
+		// carrying the idempotency key settles the refund
+		log.Printf("refund %d outcome unknown: %v", refund.ID, err)
+		return refund, errors.New("refund was sent but the payment provider did not confirm it, it stays pending")
+	}
+	if result.Status == payment.RefundStatusFailed {
+		refund.Response = string(result.Raw)
+		if failErr := s.RefundRepo.FailRefund(refund); failErr != nil {
+			log.Printf("unable to release failed refund %d: %v", refund.ID, failErr)
+		}
+		return refund, errors.New("refund was declined by the payment provider")
+	}
+
+	refund.ProviderRefundId = result.ID
+	refund.Response = string(result.Raw)
+	if result.Status == payment.RefundStatusPending {
+		// the amount stays reserved until the provider reports the outcome, see ResolveRefund
+		if err := s.RefundRepo.UpdateRefund(refund); err != nil {
+			return refund, err
+		}
+		return refund, nil
+	}
+
+	if err := s.completeRefund(refund, "refund "+result.ID); err != nil {
+		return refund, err
+	}
+	return refund, nil
+}
+
+// ResolveRefund applies the outcome of a refund the provider reported as pending. Refunds
+// that were settled already are left alone, so repeated webhook deliveries are harmless.
+// Refunds are found by their idempotency key, so one the provider did not confirm in time is
+// settled too; refunds created before keys were sent are found by the provider id.
+func (s RefundService) ResolveRefund(providerRefundId string, idempotencyKey string, status payment.RefundStatus, response string) error {
+
+	var refund *domain.Refund
+	var err error
+	if refundId := domain.RefundIdFromKey(idempotencyKey); refundId > 0 {
+		refund, err = s.RefundRepo.FindRefundById(refundId)
+	} else {
+		refund, err = s.RefundRepo.FindRefundByProviderId(providerRefundId)
+	}
+	if err != nil {
+		return err
+	}
+	if refund.Status != domain.RefundStatusPending {
+		return nil
+	}
+	refund.ProviderRefundId = providerRefundId
+	refund.Response = response
+
+	switch status {
+	case payment.RefundStatusPending:
+		err = s.RefundRepo.UpdateRefund(refund)
+	case payment.RefundStatusSucceeded:
+		err = s.completeRefund(refund, "refund "+providerRefundId)
+	case payment.RefundStatusFailed:
+		err = s.RefundRepo.FailRefund(refund)
+	}
+	if errors.Is(err, repository.ErrRefundSettled) {
+		return nil
+	}
+	return err
+}
+
+// completeRefund records a refund the provider paid out. Once the whole capture has been
+// returned the order is closed; sub-order refunds follow a cancellation which already
+// closed the group.
+func (s RefundService) completeRefund(refund *domain.Refund, note string) error {
+	fullyRefunded, err := s.RefundRepo.CompleteRefund(refund)
+	if err != nil {
+		return err
+	}
+	if !fullyRefunded || refund.SubOrderId > 0 {
+		return nil
+	}
+	if order, err := s.OrderSvc.OrderRepo.FindOrderById(refund.OrderId); err == nil && order.Status != domain.OrderStatusRefunded {
+		if err := s.OrderSvc.TransitionById(order.ID, domain.OrderStatusRefunded, note); err != nil {
+			log.Printf("refund %d: order %d not moved to refunded: %v", refund.ID, order.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetRefunds lists the refunds of an order, sellers only see the refunds of their own items
+func (s RefundService) GetRefunds(u domain.User, actor domain.OrderActor, orderId uint) ([]domain.Refund, error) {
+	order, err := s.OrderSvc.findOrderFor(u, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := s.RefundRepo.FindRefunds(orderId)
+	if err != nil || actor != domain.OrderActorSeller {
+		return refunds, err
+	}
+
+	own := make([]domain.Refund, 0, len(refunds))
+	for _, r := range refunds {
+		if r.SubOrderId > 0 {
+			if so, ok := order.FindSubOrder(u.ID); ok && so.ID == r.SubOrderId {
+				own = append(own, r)
+			}
+			continue
+		}
+		// order level refunds cover every seller of the order
+		if r.OrderItemId == 0 {
+			own = append(own, r)
+			continue
+		}
+		if item, err := findItem(order, r.OrderItemId); err == nil && item.SellerId == u.ID {
+			own = append(own, r)
+		}
+	}
+	return own, nil
+}
+
+func findItem(order *domain.Order, itemId uint) (*domain.OrderItem, error) {
+	for i := range order.Items {
+		if order.Items[i].ID == itemId {
+			return &order.Items[i], nil
+		}
+	}
+	return nil, errors.New("order item does not exist")
+}
+
+// roundAmount rounds money to cents
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/pkg/storage"
+	"io"
+	"log"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+const MaxReturnPhotos = 5
+
+// ReturnService runs returns (RMA) of delivered items: the buyer asks, the seller approves,
+// receives the goods back, restocks them and refunds the buyer
+type ReturnService struct {
+	Repo      repository.ReturnRepository
+	OrderSvc  *OrderService
+	RefundSvc *RefundService
+	Storage   storage.Storage // where return photos are uploaded to
+	Config    config.AppConfig
+	Auth      helper.Auth
+}
+
+func NewReturnService(r repository.ReturnRepository, orderSvc *OrderService, refundSvc *RefundService, store storage.Storage, cfg config.AppConfig, auth helper.Auth) *ReturnService {
+	return &ReturnService{
+		Repo:      r,
+		OrderSvc:  orderSvc,
+		RefundSvc: refundSvc,
+		Storage:   store,
+		Config:    cfg,
+		Auth:      auth,
+	}
+}
+
+// RequestReturn opens a return for some or all units of a delivered item of the buyer's order
+func (s ReturnService) RequestReturn(u domain.User, orderId uint, input dto.CreateReturnRequest, photos []*multipart.FileHeader) (*domain.ReturnRequest, error) {
+
+	reason := domain.ReturnReason(input.Reason)
+	if !reason.IsValid() {
+		return nil, fmt.Errorf("reason must be one of %v", domain.ReturnReasons)
+	}
+	if len(photos) > MaxReturnPhotos {
+		return nil, fmt.Errorf("a return can have at most %d photos", MaxReturnPhotos)
+	}
+	if len(photos) > 0 && s.Storage == nil {
+		return nil, errors.New("photo storage is not configured")
+	}
+
+	order, err := s.OrderSvc.findOrderFor(u, domain.OrderActorBuyer, orderId)
+	if err != nil {
+		return nil, err
+	}
+	item, err := findItem(order, input.OrderItemId)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != domain.OrderStatusDelivered {
+		return nil, errors.New("only delivered items can be returned")
+	}
+	if err := s.checkWindow(item); err != nil {
+		return nil, err
+	}
+
+	qty := input.Qty
+	if qty == 0 {
+		qty = item.Qty
+	}
+	if qty > item.Qty {
+		return nil, fmt.Errorf("only %d units of the item were bought", item.Qty)
+	}
+
+	ret := &domain.ReturnRequest{
+		OrderId:     order.ID,
+		SubOrderId:  item.SubOrderId,
+		OrderItemId: item.ID,
+		BuyerId:     u.ID,
+		SellerId:    item.SellerId,
+		Qty:         qty,
+		Reason:      reason,
+		Note:        input.Note,
+	}
+
+	ctx := context.Background()
+	for _, file := range photos {
+		photo, err := s.storePhoto(ctx, fmt.Sprintf("returns/%d", order.ID), file)
+		if err != nil {
+			s.removePhotos(ctx, ret.Photos)
+			return nil, err
+		}
+		ret.Photos = append(ret.Photos, *photo)
+	}
+
+	if err := s.Repo.CreateReturn(ret); err != nil {
+		s.removePhotos(ctx, ret.Photos)
+		return nil, err
+	}
+	return s.Repo.FindReturnById(ret.ID)
+}
+
+// UpdateStatus moves a return on behalf of its buyer or seller. Receiving the goods can put
+// them back on sale, refunding pays the buyer back through the payment provider.
+func (s ReturnService) UpdateStatus(u domain.User, actor domain.OrderActor, id uint, input dto.UpdateReturnStatusRequest) (*domain.ReturnRequest, error) {
+
+	ret, err := s.findReturnFor(u, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	to := domain.ReturnStatus(input.Status)
+	if !to.IsValid() {
+		return nil, fmt.Errorf("unknown return status %q", to)
+	}
+	if !ret.Status.CanTransitionTo(to, actor) {
+		return nil, fmt.Errorf("%s cannot move return from %s to %s", actor, ret.Status, to)
+	}
+
+	event := domain.ReturnEvent{
+		FromStatus: ret.Status,
+		ToStatus:   to,
+		ActorId:    u.ID,
+		ActorRole:  actor,
+		Note:       input.Note,
+	}
+
+	var restock []domain.StockMovement
+	switch to {
+	case domain.ReturnStatusReceived:
+		if input.Restock {
+			order, item, err := s.findItem(ret)
+			if err != nil {
+				return nil, err
+			}
+			restock = append(restock, domain.StockMovement{
+				ProductId: item.ProductId,
+				VariantId: item.VariantId,
+				Type:      domain.StockMovementReturn,
+				Qty:       ret.Qty,
+				Delta:     int(ret.Qty),
+				Reason:    fmt.Sprintf("return %d received", ret.ID),
+				Reference: order.OrderRefNumber,
+				ActorId:   u.ID,
+			})
+			ret.Restocked = true
+		}
+	case domain.ReturnStatusRefunded:
+		if err := s.refund(u, actor, ret, event, input.Amount); err != nil {
+			return nil, err
+		}
+		return s.Repo.FindReturnById(ret.ID)
+	}
+
+	if err := s.Repo.UpdateReturnStatus(ret, event, restock); err != nil {
+		return nil, err
+	}
+	return s.Repo.FindReturnById(ret.ID)
+}
+
+// refund pays back the returned units, by default their share of the item total. The return
+// is marked refunded first so it cannot be paid twice, and goes back to received if the
+// provider declines.
+func (s ReturnService) refund(u domain.User, actor domain.OrderActor, ret *domain.ReturnRequest, event domain.ReturnEvent, amount float64) error {
+
+	_, item, err := s.findItem(ret)
+	if err != nil {
+		return err
+	}
+
+	due := roundAmount(math.Min(item.Total()*float64(ret.Qty)/float64(item.Qty), item.Total()-item.RefundedAmount))
+	if amount == 0 {
+		amount = due
+	}
+	amount = roundAmount(amount)
+	if amount <= 0 || amount > due {
+		return fmt.Errorf("refund amount must be greater than zero and at most %.2f", due)
+	}
+
+	if err := s.Repo.UpdateReturnStatus(ret, event, nil); err != nil {
+		return err
+	}
+
+	refund, err := s.RefundSvc.CreateRefund(u, actor, ret.OrderId, dto.CreateRefundRequest{
+		OrderItemId: ret.OrderItemId,
+		Amount:      amount,
+		Reason:      fmt.Sprintf("return %d: %s", ret.ID, ret.Reason),
+	})
+	if err != nil {
+		undo := domain.ReturnEvent{
+			FromStatus: domain.ReturnStatusRefunded,
+			ToStatus:   domain.ReturnStatusReceived,
+			ActorRole:  domain.OrderActorSystem,
+			Note:       "refund failed: " + err.Error(),
+		}
+		if undoErr := s.Repo.UpdateReturnStatus(ret, undo, nil); undoErr != nil {
+			log.Printf("return %d: not moved back to received: %v", ret.ID, undoErr)
+		}
+		return err
+	}
+
+	ret.RefundId = refund.ID
+	ret.RefundAmount = refund.Amount
+	return s.Repo.UpdateReturnRefund(ret)
+}
+
+// GetReturn shows a return with its photos and every step it went through
+func (s ReturnService) GetReturn(u domain.User, actor domain.OrderActor, id uint) (*domain.ReturnRequest, error) {
+	return s.findReturnFor(u, actor, id)
+}
+
+// GetReturns lists the returns of the buyer, or those of the seller's items
+func (s ReturnService) GetReturns(u domain.User, actor domain.OrderActor, status string, p dto.PaginationRequest) (dto.ReturnList, error) {
+	p.Normalize()
+
+	filter := dto.ReturnFilter{Status: domain.ReturnStatus(status)}
+	switch actor {
+	case domain.OrderActorBuyer:
+		filter.BuyerId = u.ID
+	case domain.OrderActorSeller:
+		filter.SellerId = u.ID
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return dto.ReturnList{}, fmt.Errorf("unknown return status %q", status)
+	}
+
+	returns, total, err := s.Repo.FindReturns(filter, p)
+	if err != nil {
+		return dto.ReturnList{}, err
+	}
+	return dto.ReturnList{
+		Returns: returns,
+		Pagination: dto.PaginationResponse{
+			Page:  p.Page,
+			Limit: p.Limit,
+			Total: total,
+		},
+	}, nil
+}
+
+// findReturnFor loads a return and verifies the user is its buyer or seller
+func (s ReturnService) findReturnFor(u domain.User, actor domain.OrderActor, id uint) (*domain.ReturnRequest, error) {
+	ret, err := s.Repo.FindReturnById(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case actor == domain.OrderActorBuyer && ret.BuyerId == u.ID:
+	case actor == domain.OrderActorSeller && ret.SellerId == u.ID:
+	case actor == domain.OrderActorAdmin:
+	default:
+		return nil, errors.New("return does not exist")
+	}
+	return ret, nil
+}
+
+// findItem loads the order item being returned with its order
+func (s ReturnService) findItem(ret *domain.ReturnRequest) (*domain.Order, *domain.OrderItem, error) {
+	order, err := s.OrderSvc.OrderRepo.FindOrderById(ret.OrderId)
+	if err != nil {
+		return nil, nil, err
+	}
+	item, err := findItem(order, ret.OrderItemId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, item, nil
+}
+
+// checkWindow applies the configured return window, counted from delivery
+func (s ReturnService) checkWindow(item *domain.OrderItem) error {
+	if s.Config.ReturnWindow == 0 {
+		return nil
+	}
+
+	// items confirmed delivered without a shipment changed status last on delivery
+	deliveredAt := item.UpdatedAt
+	if at, err := s.Repo.FindDeliveredAt(item.ID); err == nil && at != nil {
+		deliveredAt = *at
+	}
+	if time.Since(deliveredAt) > s.Config.ReturnWindow {
+		return fmt.Errorf("returns can only be requested within %s of delivery", s.Config.ReturnWindow)
+	}
+	return nil
+}
+
+// storePhoto validates an uploaded photo and stores it as is, photos are not resized
+func (s ReturnService) storePhoto(ctx context.Context, prefix string, file *multipart.FileHeader) (*domain.ReturnPhoto, error) {
+	if file.Size > MaxImageSize {
+		return nil, fmt.Errorf("%s is larger than %dMB", file.Filename, MaxImageSize>>20)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s", file.Filename)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxImageSize+1))
+	if err != nil || len(data) > MaxImageSize {
+		return nil, fmt.Errorf("cannot read %s", file.Filename)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a jpeg, png, webp or gif image", file.Filename)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, errors.New("failed to store photo")
+	}
+	key := prefix + "/" + name + ext
+
+	url, err := s.Storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		log.Printf("storage_err: %v", err)
+		return nil, errors.New("failed to store photo")
+	}
+	return &domain.ReturnPhoto{
+		Url:         url,
+		StorageKey:  key,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}, nil
+}
+
+func (s ReturnService) removePhotos(ctx context.Context, photos []domain.ReturnPhoto) {
+	for _, photo := range photos {
+		if err := s.Storage.Delete(ctx, photo.StorageKey); err != nil {
+			log.Printf("storage_err: failed to delete %s: %v", photo.StorageKey, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxReviewBody  = 5000
+	maxReviewTitle = 200
+)
+
+type ReviewService struct {
+	Repo        repository.ReviewRepository
+	CatalogRepo repository.CatalogRepository
+	UserRepo    repository.UserRepository
+	Auth        helper.Auth
+}
+
+func NewReviewService(r repository.ReviewRepository, c repository.CatalogRepository, u repository.UserRepository, auth helper.Auth) *ReviewService {
+	return &ReviewService{
+		Repo:        r,
+		CatalogRepo: c,
+		UserRepo:    u,
+		Auth:        auth,
+	}
+}
+
+// CreateReview lets a buyer rate a product once it was delivered to them
+func (s ReviewService) CreateReview(u domain.User, productId uint, input dto.ReviewRequest) (*domain.Review, error) {
+	if err := validateReview(&input); err != nil {
+		return nil, err
+	}
+	if _, err := s.CatalogRepo.FindProductById(int(productId)); err != nil {
+		return nil, errors.New("product not found")
+	}
+	if _, err := s.Repo.FindUserReview(u.ID, productId); err == nil {
+		return nil, errors.New("you have already reviewed this product, edit your review instead")
+	}
+
+	item, err := s.Repo.FindDeliveredItem(u.ID, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token only carries the id, load the name shown with the review
+	if profile, err := s.UserRepo.FindUserById(u.ID); err == nil {
+		u = profile
+	}
+
+	review := &domain.Review{
+		ProductId:    productId,
+		UserId:       u.ID,
+		OrderItemId:  item.ID,
+		ReviewerName: reviewerName(u),
+		Rating:       input.Rating,
+		Title:        input.Title,
+		Body:         input.Body,
+	}
+	if err := s.Repo.CreateReview(review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// UpdateReview changes the rating and text of the buyer's own review
+func (s ReviewService) UpdateReview(u domain.User, productId uint, input dto.ReviewRequest) (*domain.Review, error) {
+	if err := validateReview(&input); err != nil {
+		return nil, err
+	}
+	review, err := s.Repo.FindUserReview(u.ID, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	review.Rating = input.Rating
+	review.Title = input.Title
+	review.Body = input.Body
+	if err := s.Repo.UpdateReview(review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (s ReviewService) GetReviews(productId uint, filter dto.ReviewFilter) (dto.ReviewList, error) {
+	switch filter.Sort {
+	case "", dto.ReviewSortNewest, dto.ReviewSortOldest, dto.ReviewSortRatingDesc, dto.ReviewSortRatingAsc:
+	default:
+		return dto.ReviewList{}, errors.New("sort must be one of newest, oldest, rating_desc, rating_asc")
+	}
+	filter.Normalize()
+
+	reviews, total, err := s.Repo.FindReviews(productId, filter)
+	if err != nil {
+		return dto.ReviewList{}, err
+	}
+
+	return dto.ReviewList{
+		Reviews: reviews,
+		Pagination: dto.PaginationResponse{
+			Page:  filter.Page,
+			Limit: filter.Limit,
+			Total: total,
+		},
+	}, nil
+}
+
+// ReplyToReview stores the seller's public answer, replying again replaces it
+func (s ReviewService) ReplyToReview(u domain.User, reviewId uint, input dto.ReviewReplyRequest) (*domain.Review, error) {
+	reply := strings.TrimSpace(input.Reply)
+	if reply == "" {
+		return nil, errors.New("reply cannot be empty")
+	}
+	if utf8.RuneCountInString(reply) > maxReviewBody {
+		return nil, errors.New("reply is too long")
+	}
+
+	review, err := s.Repo.FindReviewById(reviewId)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.CatalogRepo.FindProductById(int(review.ProductId))
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+	if product.UserId != int(u.ID) {
+		return nil, errors.New("you can only reply to reviews of your own products")
+	}
+
+	if err := s.Repo.SetSellerReply(review.ID, reply); err != nil {
+		return nil, err
+	}
+	return s.Repo.FindReviewById(review.ID)
+}
+
+// FlagReview reports a review as abusive
+func (s ReviewService) FlagReview(u domain.User, reviewId uint, input dto.FlagReviewRequest) error {
+	review, err := s.Repo.FindReviewById(reviewId)
+	if err != nil {
+		return err
+	}
+	if review.UserId == u.ID {
+		return errors.New("you cannot flag your own review")
+	}
+
+	_, err = s.Repo.FlagReview(domain.ReviewFlag{
+		ReviewId: review.ID,
+		UserId:   u.ID,
+		Reason:   strings.TrimSpace(input.Reason),
+	})
+	return err
+}
+
+func validateReview(input *dto.ReviewRequest) error {
+	if input.Rating < 1 || input.Rating > 5 {
+		return errors.New("rating must be between 1 and 5")
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	input.Body = strings.TrimSpace(input.Body)
+	if utf8.RuneCountInString(input.Title) > maxReviewTitle {
+		return errors.New("review title is too long")
+	}
+	if utf8.RuneCountInString(input.Body) > maxReviewBody {
+		return errors.New("review text is too long")
+	}
+	return nil
+}
+
+// reviewerName shows the first name and last initial, e.g. "Jane D."
+func reviewerName(u domain.User) string {
+	name := strings.TrimSpace(u.FirstName)
+	if last := strings.TrimSpace(u.LastName); last != "" {
+		r, _ := utf8.DecodeRuneInString(last)
+		name = strings.TrimSpace(name + " " + string(r) + ".")
+	}
+	if name == "" {
+		return "Anonymous"
+	}
+	return name
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ShipmentService lets sellers ship order items and track their delivery. Item and order
+// statuses follow the shipments.
+type ShipmentService struct {
+	Repo   repository.ShipmentRepository
+	Orders *OrderService
+	Auth   helper.Auth
+}
+
+func NewShipmentService(r repository.ShipmentRepository, orders *OrderService, auth helper.Auth) *ShipmentService {
+	return &ShipmentService{
+		Repo:   r,
+		Orders: orders,
+		Auth:   auth,
+	}
+}
+
+// orderProgress is the part of the lifecycle shipments move an order through
+var orderProgress = []domain.OrderStatus{
+	domain.OrderStatusPaid,
+	domain.OrderStatusProcessing,
+	domain.OrderStatusShipped,
+	domain.OrderStatusDelivered,
+}
+
+func (s ShipmentService) CreateShipment(u domain.User, orderId uint, input dto.CreateShipmentRequest) (*domain.Shipment, error) {
+	carrier := strings.TrimSpace(input.Carrier)
+	tracking := strings.TrimSpace(input.TrackingNumber)
+	if carrier == "" || tracking == "" {
+		return nil, errors.New("carrier and tracking number are required")
+	}
+
+	order, err := s.Orders.findOrderFor(u, domain.OrderActorSeller, orderId)
+	if err != nil {
+		return nil, err
+	}
+	so, ok := order.FindSubOrder(u.ID)
+	if !ok {
+		return nil, errors.New("order does not exist")
+	}
+	if so.Status != domain.OrderStatusPaid && so.Status != domain.OrderStatusProcessing {
+		return nil, fmt.Errorf("a %s order cannot be shipped", so.Status)
+	}
+
+	items, err := shippableItems(order, u.ID, input.OrderItemIds)
+	if err != nil {
+		return nil, err
+	}
+
+	shippedAt := time.Now()
+	if input.ShippedAt != nil {
+		shippedAt = *input.ShippedAt
+	}
+
+	shipment := &domain.Shipment{
+		OrderId:        order.ID,
+		SubOrderId:     so.ID,
+		SellerId:       u.ID,
+		Carrier:        carrier,
+		TrackingNumber: tracking,
+		TrackingUrl:    strings.TrimSpace(input.TrackingUrl),
+		Status:         domain.ShipmentStatusShipped,
+		ShippedAt:      shippedAt,
+		Events: []domain.ShipmentEvent{{
+			Status:     domain.ShipmentStatusShipped,
+			Note:       input.Note,
+			OccurredAt: shippedAt,
+		}},
+	}
+	for _, item := range items {
+		shipment.Items = append(shipment.Items, domain.ShipmentItem{OrderItemId: item.ID})
+	}
+
+	if err := s.Repo.CreateShipment(shipment); err != nil {
+		return nil, err
+	}
+
+	if err := s.syncSubOrderStatus(order.ID, u.ID, fmt.Sprintf("shipment %d via %s %s", shipment.ID, carrier, tracking)); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// shippableItems returns the seller's items in ids, or all their unshipped items when ids is empty
+func shippableItems(order *domain.Order, sellerId uint, ids []uint) ([]domain.OrderItem, error) {
+	unshipped := func(item domain.OrderItem) bool {
+		return item.Status == domain.OrderStatusPaid || item.Status == domain.OrderStatusProcessing
+	}
+
+	items := make([]domain.OrderItem, 0)
+	if len(ids) == 0 {
+		for _, item := range order.Items {
+			if item.SellerId == sellerId && unshipped(item) {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			return nil, errors.New("there are no items left to ship")
+		}
+		return items, nil
+	}
+
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(ids))) {
+		i := slices.IndexFunc(order.Items, func(item domain.OrderItem) bool {
+			return item.ID == id && item.SellerId == sellerId
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("order item %d is not part of your order", id)
+		}
+		if !unshipped(order.Items[i]) {
+			return nil, fmt.Errorf("order item %d is %s and cannot be shipped", id, order.Items[i].Status)
+		}
+		items = append(items, order.Items[i])
+	}
+	return items, nil
+}
+
+// UpdateStatus records a delivery status update reported by the carrier
+func (s ShipmentService) UpdateStatus(u domain.User, shipmentId uint, input dto.UpdateShipmentStatusRequest) (*domain.Shipment, error) {
+	shipment, err := s.Repo.FindShipmentById(shipmentId)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.SellerId != u.ID {
+		return nil, errors.New("shipment does not exist")
+	}
+
+	to := domain.ShipmentStatus(input.Status)
+	if !to.IsValid() {
+		return nil, fmt.Errorf("unknown shipment status %q", input.Status)
+	}
+	if !shipment.Status.CanTransitionTo(to) {
+		return nil, fmt.Errorf("shipment cannot move from %s to %s", shipment.Status, to)
+	}
+
+	occurredAt := time.Now()
+	if input.OccurredAt != nil {
+		occurredAt = *input.OccurredAt
+	}
+
+	err = s.Repo.AddShipmentEvent(shipment, domain.ShipmentEvent{
+		Status:     to,
+		Location:   input.Location,
+		Note:       input.Note,
+		OccurredAt: occurredAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if to == domain.ShipmentStatusDelivered {
+		if err := s.syncSubOrderStatus(shipment.OrderId, u.ID, fmt.Sprintf("shipment %d delivered", shipment.ID)); err != nil {
+			return nil, err
+		}
+	}
+	return shipment, nil
+}
+
+// GetOrderShipments returns the shipment timeline of an order, sellers only see their own shipments
+func (s ShipmentService) GetOrderShipments(u domain.User, actor domain.OrderActor, orderId uint) ([]domain.Shipment, error) {
+	if _, err := s.Orders.findOrderFor(u, actor, orderId); err != nil {
+		return nil, err
+	}
+	sellerId := uint(0)
+	if actor == domain.OrderActorSeller {
+		sellerId = u.ID
+	}
+	return s.Repo.FindOrderShipments(orderId, sellerId)
+}
+
+// syncSubOrderStatus moves the seller's sub-order as far as its items went: processing once
+// some shipped, shipped once all did and delivered once all arrived. Refunded and cancelled
+// items are left out. The parent order follows its sub-orders.
+func (s ShipmentService) syncSubOrderStatus(orderId uint, sellerId uint, note string) error {
+	order, err := s.Orders.OrderRepo.FindOrderById(orderId)
+	if err != nil {
+		return err
+	}
+	so, ok := order.FindSubOrder(sellerId)
+	if !ok {
+		return nil
+	}
+
+	current := slices.Index(orderProgress, so.Status)
+	if current < 0 {
+		return nil
+	}
+
+	items := make([]domain.OrderItem, 0)
+	for _, item := range order.Items {
+		if item.SellerId == sellerId {
+			items = append(items, item)
+		}
+	}
+
+	target := slices.Index(orderProgress, itemsStatus(items))
+	for next := current + 1; next <= target; next++ {
+		if err := s.Orders.TransitionSubOrder(order, so, orderProgress[next], sellerId, domain.OrderActorSeller, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itemsStatus returns the order status the order items have reached
+func itemsStatus(items []domain.OrderItem) domain.OrderStatus {
+	shipped, delivered, active := 0, 0, 0
+	for _, item := range items {
+		switch item.Status {
+		case domain.OrderStatusRefunded, domain.OrderStatusCancelled:
+			continue
+		case domain.OrderStatusShipped:
+			shipped++
+		case domain.OrderStatusDelivered:
+			shipped++
+			delivered++
+		}
+		active++
+	}
+
+	switch {
+	case active > 0 && delivered == active:
+		return domain.OrderStatusDelivered
+	case active > 0 && shipped == active:
+		return domain.OrderStatusShipped
+	case shipped > 0:
+		return domain.OrderStatusProcessing
+	}
+	return domain.OrderStatusPaid
+}
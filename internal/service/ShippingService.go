@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/helper"
+	"go-ecommerce-app/internal/repository"
+	"slices"
+	"strings"
+)
+
+// ShippingService manages the shipping methods of sellers and prices shipping for carts
+type ShippingService struct {
+	Repo     repository.ShippingRepository
+	UserRepo repository.UserRepository
+	Auth     helper.Auth
+}
+
+func NewShippingService(r repository.ShippingRepository, u repository.UserRepository, auth helper.Auth) *ShippingService {
+	return &ShippingService{
+		Repo:     r,
+		UserRepo: u,
+		Auth:     auth,
+	}
+}
+
+func (s ShippingService) CreateMethod(u domain.User, input dto.ShippingMethodRequest) (*domain.ShippingMethod, error) {
+	method := &domain.ShippingMethod{SellerId: u.ID, Active: true}
+	if err := applyShippingInput(method, input); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.CreateMethod(method); err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+func (s ShippingService) UpdateMethod(u domain.User, id uint, input dto.ShippingMethodRequest) (*domain.ShippingMethod, error) {
+	method, err := s.findSellerMethod(u, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyShippingInput(method, input); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.UpdateMethod(method); err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+// DeactivateMethod stops offering a method, orders shipped with it keep their snapshot
+func (s ShippingService) DeactivateMethod(u domain.User, id uint) error {
+	method, err := s.findSellerMethod(u, id)
+	if err != nil {
+		return err
+	}
+	method.Active = false
+	return s.Repo.UpdateMethod(method)
+}
+
+func (s ShippingService) GetSellerMethods(u domain.User) ([]domain.ShippingMethod, error) {
+	return s.Repo.FindSellerMethods(u.ID)
+}
+
+func (s ShippingService) findSellerMethod(u domain.User, id uint) (*domain.ShippingMethod, error) {
+	method, err := s.Repo.FindMethodById(id)
+	if err != nil {
+		return nil, err
+	}
+	if method.SellerId != u.ID {
+		return nil, errors.New("you don't have manage rights of this shipping method")
+	}
+	return method, nil
+}
+
+// applyShippingInput copies a create or update request onto m and validates the result
+func applyShippingInput(m *domain.ShippingMethod, input dto.ShippingMethodRequest) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return errors.New("shipping method name is required")
+	}
+	if !input.Type.IsValid() {
+		return errors.New("type must be one of flat, weight_based, free_over")
+	}
+	if input.Rate < 0 || input.PerKg < 0 || input.FreeAbove < 0 || input.EstimatedDays < 0 {
+		return errors.New("rates cannot be negative")
+	}
+	if input.Type == domain.ShippingRateWeightBased && input.PerKg == 0 {
+		return errors.New("per_kg is required for weight based shipping")
+	}
+
+	countries := make([]string, 0, len(input.Countries))
+	for _, c := range input.Countries {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c != "" && !slices.Contains(countries, c) {
+			countries = append(countries, c)
+		}
+	}
+
+	m.Name = name
+	m.Description = input.Description
+	m.Type = input.Type
+	m.Rate = input.Rate
+	m.PerKg = input.PerKg
+	m.FreeAbove = input.FreeAbove
+	m.Countries = countries
+	m.EstimatedDays = input.EstimatedDays
+	if input.Active != nil {
+		m.Active = *input.Active
+	}
+	return nil
+}
+
+// sellerShipment is the part of a cart one seller ships
+type sellerShipment struct {
+	sellerId uint
+	subtotal float64
+	weight   float64
+	methods  []domain.ShippingMethod // active methods delivering to the buyer
+	offered  bool                    // the seller has active methods, whatever the destination
+}
+
+// shipments groups cart items by seller with the methods that can deliver them to addr
+func (s ShippingService) shipments(items []domain.Cart, addr domain.Address) ([]sellerShipment, error) {
+	shipments := make([]sellerShipment, 0)
+	sellerIds := make([]uint, 0)
+	for _, item := range items {
+		i := slices.IndexFunc(shipments, func(sh sellerShipment) bool { return sh.sellerId == item.SellerId })
+		if i < 0 {
+			shipments = append(shipments, sellerShipment{sellerId: item.SellerId})
+			sellerIds = append(sellerIds, item.SellerId)
+			i = len(shipments) - 1
+		}
+		shipments[i].subtotal += item.Price * float64(item.Qty)
+		shipments[i].weight += item.Weight * float64(item.Qty)
+	}
+
+	methods, err := s.Repo.FindActiveMethods(sellerIds)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shipments {
+		for _, m := range methods {
+			if m.SellerId != shipments[i].sellerId {
+				continue
+			}
+			shipments[i].offered = true
+			if addr.Country != "" && m.ShipsTo(addr.Country) {
+				shipments[i].methods = append(shipments[i].methods, m)
+			}
+		}
+	}
+	return shipments, nil
+}
+
+// Quote prices shipping for the methods the buyer picked. Sellers without shipping methods
+// ship for free, a seller with a single method delivering to the buyer needs no pick.
+func (s ShippingService) Quote(userId uint, items []domain.Cart, addr domain.Address) (dto.ShippingQuote, error) {
+	quote := dto.ShippingQuote{Lines: []dto.ShippingLine{}}
+
+	shipments, err := s.shipments(items, addr)
+	if err != nil {
+		return quote, err
+	}
+	selected, err := s.selectedMethods(userId)
+	if err != nil {
+		return quote, err
+	}
+
+	for _, sh := range shipments {
+		if !sh.offered {
+			continue
+		}
+
+		method, problem := pickMethod(sh, selected[sh.sellerId], addr.Country)
+		if problem != "" {
+			if quote.Error == "" {
+				quote.Error = problem
+			}
+			continue
+		}
+
+		cost := method.Cost(roundAmount(sh.subtotal), sh.weight)
+		quote.Lines = append(quote.Lines, dto.ShippingLine{
+			SellerId: sh.sellerId,
+			MethodId: method.ID,
+			Name:     method.Name,
+			Type:     method.Type,
+			Cost:     cost,
+		})
+		quote.Total += cost
+	}
+	quote.Total = roundAmount(quote.Total)
+	return quote, nil
+}
+
+func pickMethod(sh sellerShipment, selectedId uint, country string) (domain.ShippingMethod, string) {
+	if country == "" {
+		return domain.ShippingMethod{}, "add a delivery address to choose shipping"
+	}
+	if len(sh.methods) == 0 {
+		return domain.ShippingMethod{}, fmt.Sprintf("seller %d does not ship to %s", sh.sellerId, strings.ToUpper(country))
+	}
+	if selectedId == 0 && len(sh.methods) == 1 {
+		return sh.methods[0], ""
+	}
+	for _, m := range sh.methods {
+		if m.ID == selectedId {
+			return m, ""
+		}
+	}
+	if selectedId > 0 {
+		return domain.ShippingMethod{}, fmt.Sprintf("the shipping method picked for seller %d is not available for your address, choose another one", sh.sellerId)
+	}
+	return domain.ShippingMethod{}, fmt.Sprintf("choose a shipping method for seller %d", sh.sellerId)
+}
+
+func (s ShippingService) selectedMethods(userId uint) (map[uint]uint, error) {
+	selection, err := s.Repo.FindCartShipping(userId)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[uint]uint, len(selection))
+	for _, cs := range selection {
+		selected[cs.SellerId] = cs.MethodId
+	}
+	return selected, nil
+}
+
+// GetShippingOptions lists, for every seller in the buyer's cart, the methods that deliver to
+// the buyer's address with their cost
+func (s ShippingService) GetShippingOptions(userId uint) ([]dto.SellerShippingOptions, error) {
+	items, err := s.UserRepo.FindCartItems(userId)
+	if err != nil || len(items) == 0 {
+		return nil, errors.New("your cart is empty")
+	}
+	user, err := s.UserRepo.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	shipments, err := s.shipments(items, user.Address)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := s.selectedMethods(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]dto.SellerShippingOptions, 0, len(shipments))
+	for _, sh := range shipments {
+		if !sh.offered {
+			continue
+		}
+		seller := dto.SellerShippingOptions{SellerId: sh.sellerId, Options: []dto.ShippingOption{}}
+		if method, problem := pickMethod(sh, selected[sh.sellerId], user.Address.Country); problem == "" {
+			seller.SelectedMethodId = method.ID
+		}
+		for _, m := range sh.methods {
+			seller.Options = append(seller.Options, dto.ShippingOption{
+				MethodId:      m.ID,
+				Name:          m.Name,
+				Description:   m.Description,
+				Type:          m.Type,
+				EstimatedDays: m.EstimatedDays,
+				Cost:          m.Cost(roundAmount(sh.subtotal), sh.weight),
+			})
+		}
+		options = append(options, seller)
+	}
+	return options, nil
+}
+
+// SelectShipping keeps the buyer's pick of shipping method, one per seller in the cart
+func (s ShippingService) SelectShipping(userId uint, methodIds []uint) ([]dto.SellerShippingOptions, error) {
+	if len(methodIds) == 0 {
+		return nil, errors.New("please choose a shipping method")
+	}
+	items, err := s.UserRepo.FindCartItems(userId)
+	if err != nil || len(items) == 0 {
+		return nil, errors.New("your cart is empty")
+	}
+	user, err := s.UserRepo.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	shipments, err := s.shipments(items, user.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	picked := make(map[uint]uint, len(methodIds))
+	for _, id := range methodIds {
+		method, err := s.Repo.FindMethodById(id)
+		if err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(shipments, func(sh sellerShipment) bool { return sh.sellerId == method.SellerId })
+		if i < 0 {
+			return nil, fmt.Errorf("shipping method %d is not offered for the items in your cart", id)
+		}
+		if !slices.ContainsFunc(shipments[i].methods, func(m domain.ShippingMethod) bool { return m.ID == id }) {
+			return nil, fmt.Errorf("shipping method %s does not deliver to your address", method.Name)
+		}
+		if _, ok := picked[method.SellerId]; ok {
+			return nil, fmt.Errorf("choose a single shipping method for seller %d", method.SellerId)
+		}
+		picked[method.SellerId] = id
+	}
+
+	for sellerId, methodId := range picked {
+		if err := s.Repo.SetCartShipping(userId, sellerId, methodId); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetShippingOptions(userId)
+}
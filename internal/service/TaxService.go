@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"go-ecommerce-app/internal/domain"
+	"go-ecommerce-app/internal/dto"
+	"go-ecommerce-app/internal/repository"
+	"strconv"
+	"strings"
+)
+
+// TaxService manages the tax rate table and taxes priced carts
+type TaxService struct {
+	Repo repository.TaxRepository
+}
+
+func NewTaxService(r repository.TaxRepository) *TaxService {
+	return &TaxService{
+		Repo: r,
+	}
+}
+
+func (s TaxService) CreateTaxRate(input dto.TaxRateRequest) (*domain.TaxRate, error) {
+	rate := &domain.TaxRate{}
+	if err := applyTaxRateInput(rate, input); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.CreateTaxRate(rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (s TaxService) UpdateTaxRate(id uint, input dto.TaxRateRequest) (*domain.TaxRate, error) {
+	rate, err := s.Repo.FindTaxRateById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyTaxRateInput(rate, input); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.UpdateTaxRate(rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (s TaxService) DeleteTaxRate(id uint) error {
+	return s.Repo.DeleteTaxRate(id)
+}
+
+func (s TaxService) GetTaxRates(p dto.PaginationRequest) (dto.TaxRateList, error) {
+	p.Normalize()
+	rates, total, err := s.Repo.FindTaxRates(p)
+	if err != nil {
+		return dto.TaxRateList{}, err
+	}
+	return dto.TaxRateList{
+		TaxRates: rates,
+		Pagination: dto.PaginationResponse{
+			Page:  p.Page,
+			Limit: p.Limit,
+			Total: total,
+		},
+	}, nil
+}
+
+// applyTaxRateInput copies a create or update request onto t and validates the result
+func applyTaxRateInput(t *domain.TaxRate, input dto.TaxRateRequest) error {
+	country := strings.ToUpper(strings.TrimSpace(input.Country))
+	if country == "" {
+		return errors.New("country is required")
+	}
+	if input.Rate < 0 || input.Rate > 100 {
+		return errors.New("rate must be between 0 and 100")
+	}
+
+	t.Name = strings.TrimSpace(input.Name)
+	t.Country = country
+	t.Region = strings.TrimSpace(input.Region)
+	t.PostcodePrefix = strings.TrimSpace(input.PostcodePrefix)
+	if err := checkPostcodePrefix(t.PostcodePrefix); err != nil {
+		return err
+	}
+	t.TaxClass = taxClass(input.TaxClass)
+	t.Rate = input.Rate
+	t.Inclusive = input.Inclusive
+	return nil
+}
+
+// checkPostcodePrefix only accepts prefixes that can match an address. Addresses keep their
+// postcode as a number, so letters and leading zeros would never match.
+func checkPostcodePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if strings.HasPrefix(prefix, "0") {
+		return errors.New("postcode prefix cannot start with 0, addresses store postcodes as numbers")
+	}
+	for _, c := range prefix {
+		if c < '0' || c > '9' {
+			return errors.New("postcode prefix must only contain digits")
+		}
+	}
+	return nil
+}
+
+// ApplyTax adds the tax of every item shipped to addr to a priced cart. Tax is computed on
+// the line amount after discounts, carts without a delivery country are not taxed.
+func (s TaxService) ApplyTax(p *dto.CartPricing, items []domain.Cart, addr domain.Address) error {
+	p.Tax = 0
+	p.TaxLines = []dto.TaxLine{}
+	defer p.UpdateTotal()
+
+	country := strings.ToUpper(strings.TrimSpace(addr.Country))
+	if country == "" {
+		return nil
+	}
+	rates, err := s.Repo.FindTaxRatesForCountry(country)
+	if err != nil {
+		return err
+	}
+
+	postcode := ""
+	if addr.PostCode > 0 {
+		postcode = strconv.FormatUint(uint64(addr.PostCode), 10)
+	}
+
+	for _, item := range items {
+		rate, ok := bestTaxRate(rates, country, addr.Region, postcode, taxClass(item.TaxClass))
+		if !ok {
+			continue
+		}
+
+		taxable := roundAmount(max(item.Price*float64(item.Qty)-p.ItemDiscount(item.ID), 0))
+		var amount float64
+		if rate.Inclusive {
+			amount = roundAmount(taxable - taxable/(1+rate.Rate/100))
+		} else {
+			amount = roundAmount(taxable * rate.Rate / 100)
+		}
+
+		p.TaxLines = append(p.TaxLines, dto.TaxLine{
+			CartItemId: item.ID,
+			Name:       rate.Name,
+			Rate:       rate.Rate,
+			Inclusive:  rate.Inclusive,
+			Taxable:    taxable,
+			Amount:     amount,
+		})
+		p.Tax += amount
+	}
+	p.Tax = roundAmount(p.Tax)
+	return nil
+}
+
+// bestTaxRate picks the most specific rate matching an address and tax class
+func bestTaxRate(rates []domain.TaxRate, country, region, postcode, class string) (domain.TaxRate, bool) {
+	var best domain.TaxRate
+	found := false
+	for _, r := range rates {
+		if !r.Matches(country, region, postcode, class) {
+			continue
+		}
+		if !found || r.Specificity() > best.Specificity() {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// taxClass normalises a product tax class, empty falls back to the default class
+func taxClass(class string) string {
+	class = strings.ToLower(strings.TrimSpace(class))
+	if class == "" {
+		return domain.DefaultTaxClass
+	}
+	return class
+}
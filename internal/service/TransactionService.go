@@ -12,20 +12,26 @@ type TransactionService struct {
 	Auth            helper.Auth
 }
 
-func (s TransactionService) GetOrders(u domain.User) ([]domain.OrderItem, error) {
-	orders, err := s.TransactionRepo.FindOrders(u.ID)
+func (s TransactionService) GetOrders(u domain.User, filter dto.SellerOrderFilter) (dto.SellerOrderList, error) {
+	filter.Normalize()
+
+	orders, total, err := s.TransactionRepo.FindOrders(u.ID, filter)
 	if err != nil {
-		return nil, err
+		return dto.SellerOrderList{}, err
 	}
-	return orders, nil
+	return dto.SellerOrderList{
+		Orders: orders,
+		Pagination: dto.PaginationResponse{
+			Page:  filter.Page,
+			Limit: filter.Limit,
+			Total: total,
+		},
+	}, nil
 }
 
-func (s TransactionService) GetOrderDetails(u domain.User, id uint) (dto.SellerOrderDetails, error) {
-	orders, err := s.TransactionRepo.FindOrderById(u.ID, id)
-	if err != nil {
-		return dto.SellerOrderDetails{}, err
-	}
-	return orders, nil
+// GetOrderDetails returns the seller's items of an order with the buyer's contact details
+func (s TransactionService) GetOrderDetails(u domain.User, id uint) ([]dto.SellerOrderDetails, error) {
+	return s.TransactionRepo.FindOrderById(u.ID, id)
 }
 
 func (s TransactionService) GetActivePayment(uId uint) (*domain.Payment, error) {
@@ -40,10 +46,26 @@ func (s TransactionService) StoreCreatedPayment(input dto.CreatePaymentRequest)
 		PaymentId:    input.PaymentId,
 		ClientSecret: input.ClientSecret,
 		OrderId:      input.OrderId,
+		Checkout:     input.Checkout,
 	}
 	return s.TransactionRepo.CreatePayment(&payment)
 }
 
+func (s TransactionService) GetPaymentByPaymentId(pId string) (*domain.Payment, error) {
+	return s.TransactionRepo.FindPaymentByPaymentId(pId)
+}
+
+func (s TransactionService) GetPaymentByOrderId(orderId string) (*domain.Payment, error) {
+	return s.TransactionRepo.FindPaymentByOrderId(orderId)
+}
+
+// SetPaymentStatus records the final status of a known payment
+func (s TransactionService) SetPaymentStatus(p *domain.Payment, status domain.PaymentStatus, paymentlog string) error {
+	p.Status = status
+	p.Response = paymentlog
+	return s.TransactionRepo.UpdatePayment(p)
+}
+
 func (s TransactionService) UpdatePayment(userId uint, status string, paymentlog string) error {
 	p, err := s.GetActivePayment(userId)
 	if err != nil {
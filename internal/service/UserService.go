@@ -8,8 +8,8 @@ import (
 	"go-ecommerce-app/internal/dto"
 	"go-ecommerce-app/internal/helper"
 	"go-ecommerce-app/internal/repository"
-	"go-ecommerce-app/pkg/notification"
 	"log"
+	"slices"
 
 	"time"
 )
@@ -17,19 +17,40 @@ import (
 // UserService handles all business logic related to users
 
 type UserService struct {
-	UserRepo    repository.UserRepository // DB operations for user
-	CatalogRepo repository.CatalogRepository
-	Auth        helper.Auth // Auth tools: hashing, token, verify
-	Config      config.AppConfig
+	UserRepo      repository.UserRepository // DB operations for user
+	CatalogRepo   repository.CatalogRepository
+	Auth          helper.Auth // Auth tools: hashing, token, verify
+	Config        config.AppConfig
+	Coupons       *CouponService   // prices carts with coupons, nil prices without discounts
+	Taxes         *TaxService      // taxes carts at the buyer's address, nil prices without tax
+	Shipping      *ShippingService // prices the shipping methods the buyer picked, nil ships for free
+	Sessions      repository.SessionRepository
+	Resets        repository.PasswordResetRepository
+	Notifications *NotificationService
 }
 
-// Signup creates a new user and returns a JWT
-func (s *UserService) Signup(input dto.UserSignup) (string, error) {
+// password reset rules
+const (
+	ResetCodeTTL         = 15 * time.Minute
+	ResetRequestInterval = time.Minute // between two codes for the same account
+	MaxResetRequests     = 3           // codes per account per hour
+	MaxResetAttempts     = 5           // guesses before a code stops working
+)
+
+// VerificationCodeTTL is how long an email or phone verification code stays valid
+const VerificationCodeTTL = 30 * time.Minute
+
+// ErrCodeNotSent means a verification code could not be generated, stored or delivered,
+// as opposed to a request that cannot be served such as an already verified channel
+var ErrCodeNotSent = errors.New("verification code could not be sent, please retry")
+
+// Signup creates a new user and opens their first session
+func (s *UserService) Signup(input dto.UserSignup) (dto.TokenPair, error) {
 
 	// Hash the user's password before storing it
 	hashedPassword, err := s.Auth.CreateHashedPassword(input.Password)
 	if err != nil {
-		return "", err
+		return dto.TokenPair{}, err
 	}
 
 	// Save the user in the database
@@ -39,11 +60,10 @@ func (s *UserService) Signup(input dto.UserSignup) (string, error) {
 		Phone:    input.Phone,
 	})
 	if err != nil {
-		return "", err
+		return dto.TokenPair{}, err
 	}
 
-	// Generate JWT token for the newly created user
-	return s.Auth.GenerateToken(user.ID, user.Email, user.UserType)
+	return s.StartSession(user)
 }
 
 // findUserByEmail fetches a user from DB using email (internal helper method)
@@ -55,83 +75,281 @@ func (s *UserService) findUserByEmail(email string) (*domain.User, error) {
 	return &user, nil
 }
 
-// Login verifies credentials and returns a JWT
-func (s *UserService) Login(email, password string) (string, error) {
+// Login verifies credentials and opens a new session
+func (s *UserService) Login(email, password string) (dto.TokenPair, error) {
 
 	// Find user by email
 	user, err := s.findUserByEmail(email)
 	if err != nil {
-		return "", errors.New("user does not exist with the provided email id")
+		return dto.TokenPair{}, errors.New("user does not exist with the provided email id")
 	}
 
 	// Compare hashed password with user input
 	if err := s.Auth.VerifyPassword(password, user.Password); err != nil {
-		return "", err
+		return dto.TokenPair{}, err
+	}
+
+	return s.StartSession(*user)
+}
+
+// StartSession opens a session for the user and returns its first token pair
+func (s UserService) StartSession(user domain.User) (dto.TokenPair, error) {
+
+	sessionId, err := s.Auth.GenerateSessionId()
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	refreshToken, hash, err := s.Auth.GenerateRefreshToken()
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+
+	err = s.Sessions.CreateSession(&domain.Session{
+		ID:     sessionId,
+		UserId: user.ID,
+	}, &domain.RefreshToken{
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.Config.RefreshTokenTTL),
+	})
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	return s.tokenPair(user, sessionId, refreshToken)
+}
+
+// RefreshSession exchanges a refresh token for a new pair. Each refresh token works once,
+// presenting a used one means it was stolen and the whole session is revoked.
+func (s UserService) RefreshSession(refreshToken string) (dto.TokenPair, error) {
+
+	invalid := errors.New("refresh token is not valid")
+
+	old, err := s.Sessions.FindRefreshToken(helper.HashToken(refreshToken))
+	if err != nil {
+		return dto.TokenPair{}, invalid
+	}
+	session, err := s.Sessions.FindSession(old.SessionId)
+	if err != nil || !session.IsActive() {
+		return dto.TokenPair{}, invalid
+	}
+	if old.UsedAt != nil {
+		return dto.TokenPair{}, s.revokeReused(session)
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return dto.TokenPair{}, errors.New("refresh token expired, please log in again")
 	}
 
-	// Generate JWT token for the user
-	return s.Auth.GenerateToken(user.ID, user.Email, user.UserType)
+	// the role is read again so promotions apply from the next refresh
+	user, err := s.UserRepo.FindUserById(session.UserId)
+	if err != nil {
+		return dto.TokenPair{}, invalid
+	}
+
+	next, hash, err := s.Auth.GenerateRefreshToken()
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	err = s.Sessions.RotateRefreshToken(old, &domain.RefreshToken{
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.Config.RefreshTokenTTL),
+	})
+	if errors.Is(err, repository.ErrTokenReused) {
+		return dto.TokenPair{}, s.revokeReused(session)
+	}
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	return s.tokenPair(user, session.ID, next)
 }
 
-func (s UserService) isVerifiedUser(id uint) bool {
+// Logout revokes the session of the current access token
+func (s UserService) Logout(sessionId string) error {
+	if sessionId == "" {
+		return errors.New("no session to log out of")
+	}
+	return s.Sessions.RevokeSession(sessionId)
+}
 
-	currentUser, err := s.UserRepo.FindUserById(id)
+// RevokeSessions logs the user out everywhere, e.g. after their password or role changed
+func (s UserService) RevokeSessions(userId uint) error {
+	return s.Sessions.RevokeUserSessions(userId)
+}
 
-	return err == nil && currentUser.Verified
+func (s UserService) revokeReused(session *domain.Session) error {
+	log.Printf("refresh token reused in session %s of user %d, revoking it", session.ID, session.UserId)
+	if err := s.Sessions.RevokeSession(session.ID); err != nil {
+		return err
+	}
+	return errors.New("refresh token was already used, please log in again")
+}
 
+func (s UserService) tokenPair(user domain.User, sessionId string, refreshToken string) (dto.TokenPair, error) {
+	accessToken, err := s.Auth.GenerateToken(user.ID, user.Email, user.UserType, sessionId)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	return dto.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.Auth.AccessTTL.Seconds()),
+	}, nil
 }
 
-func (s UserService) GetVerificationCode(e domain.User) error {
-	// 1) Block already verified users (signup verification flow)
-	if s.isVerifiedUser(e.ID) {
-		return errors.New("user already verified")
+// RequestPasswordReset sends a one-time reset code to the account's phone. It reports no
+// error for unknown emails or throttled requests so accounts cannot be probed.
+func (s UserService) RequestPasswordReset(email string) error {
+
+	user, err := s.UserRepo.FindUser(email)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	recent, err := s.Resets.CountResetsSince(user.ID, now.Add(-ResetRequestInterval))
+	if err != nil {
+		return err
+	}
+	hourly, err := s.Resets.CountResetsSince(user.ID, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent > 0 || hourly >= MaxResetRequests {
+		log.Printf("password reset for user %d throttled", user.ID)
+		return nil
 	}
 
-	// 2) Generate verification code
 	code, err := s.Auth.GenerateCode()
 	if err != nil {
 		return fmt.Errorf("failed to generate code: %w", err)
 	}
+	hash, err := s.Auth.CreateHashedPassword(code)
+	if err != nil {
+		return err
+	}
+
+	err = s.Resets.CreateReset(&domain.PasswordReset{
+		UserId:    user.ID,
+		CodeHash:  hash,
+		ExpiresAt: now.Add(ResetCodeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{"Code": code, "Minutes": int(ResetCodeTTL.Minutes())}
+	return s.Notifications.Dispatch(domain.EventPasswordReset, user, data, domain.ChannelSMS, domain.ChannelEmail)
+}
+
+// ResetPassword sets a new password with a reset code and logs the user out everywhere
+func (s UserService) ResetPassword(input dto.ResetPasswordInput) error {
+
+	invalid := errors.New("reset code is not valid or has expired")
+
+	user, err := s.UserRepo.FindUser(input.Email)
+	if err != nil {
+		return invalid
+	}
+	reset, err := s.Resets.FindActiveReset(user.ID)
+	if err != nil {
+		return invalid
+	}
+	if err := s.Resets.ReserveResetAttempt(reset.ID, MaxResetAttempts); err != nil {
+		return err
+	}
+	if err := s.Auth.VerifyPassword(input.Code, reset.CodeHash); err != nil {
+		return invalid
+	}
+
+	hashedPassword, err := s.Auth.CreateHashedPassword(input.Password)
+	if err != nil {
+		return err
+	}
+	if err := s.Resets.CompleteReset(reset, hashedPassword); err != nil {
+		return err
+	}
+	return s.RevokeSessions(user.ID)
+}
 
-	// 3) Update user with code + expiry
-	e.Code = code
-	e.Expiry = time.Now().Add(30 * time.Minute)
+// ChangePassword replaces the password of a signed in user once the old one is confirmed.
+// Every session is revoked and a new one opened for the caller.
+func (s UserService) ChangePassword(id uint, input dto.ChangePasswordInput) (dto.TokenPair, error) {
 
-	if _, err := s.UserRepo.UpdateUser(e.ID, e); err != nil {
-		return fmt.Errorf("unable to update verification code: %w", err)
+	user, err := s.UserRepo.FindUserById(id)
+	if err != nil {
+		return dto.TokenPair{}, errors.New("user does not exist")
+	}
+	if err := s.Auth.VerifyPassword(input.OldPassword, user.Password); err != nil {
+		return dto.TokenPair{}, errors.New("current password is not correct")
 	}
 
-	// 4) Re-fetch user to be sure we have fresh data (phone etc.)
-	dbUser, err := s.UserRepo.FindUserById(e.ID)
+	hashedPassword, err := s.Auth.CreateHashedPassword(input.NewPassword)
 	if err != nil {
-		return fmt.Errorf("failed to fetch user after update: %w", err)
+		return dto.TokenPair{}, err
+	}
+	if _, err := s.UserRepo.UpdateUser(id, domain.User{Password: hashedPassword}); err != nil {
+		return dto.TokenPair{}, err
 	}
 
-	// 5) Send SMS
-	notificationClient := notification.NewNotificationClient(s.Config)
+	if err := s.RevokeSessions(id); err != nil {
+		return dto.TokenPair{}, err
+	}
+	return s.StartSession(user)
+}
 
-	msg := fmt.Sprintf("Your verification code is %v", code)
-	if err := notificationClient.SendSMS(dbUser.Phone, msg); err != nil {
-		return fmt.Errorf("error sending sms: %w", err)
+// GetVerificationCode sends a code confirming the user's email or phone, whichever channel
+// is asked for. A new code replaces any pending one.
+func (s UserService) GetVerificationCode(e domain.User, channel domain.VerifyChannel) error {
+	if !channel.IsValid() {
+		return errors.New("channel must be email or phone")
 	}
 
+	user, err := s.UserRepo.FindUserById(e.ID)
+	if err != nil {
+		return errors.New("user does not exist")
+	}
+	if user.IsVerified(channel) {
+		return fmt.Errorf("%s already verified", channel)
+	}
+	if channel == domain.VerifyChannelPhone && user.Phone == "" {
+		return errors.New("add a phone number before verifying it")
+	}
+
+	code, err := s.Auth.GenerateCode()
+	if err != nil {
+		log.Printf("failed to generate verification code: %v", err)
+		return ErrCodeNotSent
+	}
+
+	update := domain.User{
+		Code:        code,
+		CodeChannel: channel,
+		Expiry:      time.Now().Add(VerificationCodeTTL),
+	}
+	if _, err := s.UserRepo.UpdateUser(user.ID, update); err != nil {
+		log.Printf("unable to update verification code: %v", err)
+		return ErrCodeNotSent
+	}
+
+	send := domain.ChannelSMS
+	if channel == domain.VerifyChannelEmail {
+		send = domain.ChannelEmail
+	}
+	data := map[string]any{"Code": code, "Minutes": int(VerificationCodeTTL.Minutes()), "Channel": channel}
+	if err := s.Notifications.Dispatch(domain.EventVerificationCode, user, data, send); err != nil {
+		log.Printf("verification code for user %d not sent: %v", user.ID, err)
+		return ErrCodeNotSent
+	}
 	return nil
 }
 
+// VerifyCode confirms the channel the pending code was sent to
 func (s UserService) VerifyCode(id uint, code string) error {
-	// verify logic here
-	if s.isVerifiedUser(id) {
-		log.Println("verified...")
-		return errors.New("user already verified")
-	}
 
 	user, err := s.UserRepo.FindUserById(id)
-
 	if err != nil {
 		return err
 	}
 
-	if user.Code != code {
+	if user.Code == "" || user.Code != code {
 		return errors.New("Verifcation code does not match")
 	}
 
@@ -139,16 +357,16 @@ func (s UserService) VerifyCode(id uint, code string) error {
 		return errors.New("verification code expired")
 	}
 
-	updateUser := domain.User{
-		Verified: true,
+	// codes sent before channels were tracked went out by sms
+	channel := user.CodeChannel
+	if channel == "" {
+		channel = domain.VerifyChannelPhone
 	}
-
-	_, err = s.UserRepo.UpdateUser(id, updateUser)
-
-	if err != nil {
-		return errors.New("unable to verify user")
+	if user.IsVerified(channel) {
+		return fmt.Errorf("%s already verified", channel)
 	}
-	return nil
+
+	return s.UserRepo.VerifyUser(id, channel)
 }
 
 func (s UserService) CreateProfile(id uint, input dto.ProfileInput) error {
@@ -178,6 +396,7 @@ func (s UserService) CreateProfile(id uint, input dto.ProfileInput) error {
 		AddressLine1: input.AddressInput.AddressLine1,
 		AddressLine2: input.AddressInput.AddressLine2,
 		City:         input.AddressInput.City,
+		Region:       input.AddressInput.Region,
 		Country:      input.AddressInput.Country,
 		PostCode:     input.AddressInput.PostCode,
 		UserId:       id,
@@ -220,6 +439,7 @@ func (s UserService) UpdateProfile(id uint, input dto.ProfileInput) error {
 		AddressLine1: input.AddressInput.AddressLine1,
 		AddressLine2: input.AddressInput.AddressLine2,
 		City:         input.AddressInput.City,
+		Region:       input.AddressInput.Region,
 		Country:      input.AddressInput.Country,
 		PostCode:     input.AddressInput.PostCode,
 		UserId:       id,
@@ -232,13 +452,17 @@ func (s UserService) UpdateProfile(id uint, input dto.ProfileInput) error {
 
 	return nil
 }
-func (s UserService) BecomeSeller(id uint, input dto.SellerInput) (string, error) {
+func (s UserService) BecomeSeller(id uint, input dto.SellerInput) (dto.TokenPair, error) {
 
 	//find existing user
 	user, _ := s.UserRepo.FindUserById(id)
 
 	if user.UserType == domain.SELLER {
-		return "", errors.New("You are already a seller.")
+		return dto.TokenPair{}, errors.New("You are already a seller.")
+	}
+	// staff accounts keep their role
+	if user.UserType != domain.BUYER {
+		return dto.TokenPair{}, errors.New("only buyer accounts can join the seller program")
 	}
 
 	// update user
@@ -250,11 +474,13 @@ func (s UserService) BecomeSeller(id uint, input dto.SellerInput) (string, error
 	})
 
 	if err != nil {
-		return "", err
+		return dto.TokenPair{}, err
 	}
 
-	// generating token
-	token, err := s.Auth.GenerateToken(user.ID, user.Email, seller.UserType)
+	// tokens issued to the buyer account still carry the old role
+	if err := s.RevokeSessions(id); err != nil {
+		return dto.TokenPair{}, err
+	}
 
 	// create bank account information
 
@@ -264,9 +490,30 @@ func (s UserService) BecomeSeller(id uint, input dto.SellerInput) (string, error
 		PaymentType: input.PaymentType,
 		UserId:      id,
 	})
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
 
-	return token, err
+	user.UserType = seller.UserType
+	return s.StartSession(user)
 }
+
+// AssignRole changes the role of a user. Their sessions are revoked so no token keeps the
+// old permissions.
+func (s UserService) AssignRole(id uint, role string) error {
+	if !domain.IsValidRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if _, err := s.UserRepo.FindUserById(id); err != nil {
+		return errors.New("user does not exist")
+	}
+
+	if _, err := s.UserRepo.UpdateUser(id, domain.User{UserType: role}); err != nil {
+		return err
+	}
+	return s.RevokeSessions(id)
+}
+
 func (s UserService) FindCart(id uint) ([]domain.Cart, float64, error) {
 
 	cartItems, err := s.UserRepo.FindCartItems(id)
@@ -283,10 +530,75 @@ func (s UserService) FindCart(id uint) ([]domain.Cart, float64, error) {
 
 	return cartItems, totalAmount, nil
 }
+
+// PriceCart returns the cart with its itemised price: shipping, coupon discounts and tax
+func (s UserService) PriceCart(id uint) ([]domain.Cart, dto.CartPricing, error) {
+
+	cartItems, _, err := s.FindCart(id)
+	if err != nil {
+		return nil, dto.CartPricing{}, err
+	}
+
+	pricing, _, err := s.priceItems(id, "", cartItems)
+	if err != nil {
+		return nil, dto.CartPricing{}, err
+	}
+	return cartItems, pricing, nil
+}
+
+// PriceCheckout prices the cart like PriceCart and snapshots it for the payment about to be made
+func (s UserService) PriceCheckout(id uint) ([]domain.Cart, dto.CartPricing, *domain.Checkout, error) {
+
+	cartItems, _, err := s.FindCart(id)
+	if err != nil {
+		return nil, dto.CartPricing{}, nil, err
+	}
+
+	pricing, address, err := s.priceItems(id, "", cartItems)
+	if err != nil {
+		return nil, dto.CartPricing{}, nil, err
+	}
+	return cartItems, pricing, newCheckout(cartItems, pricing, address), nil
+}
+
+// priceItems prices cart items delivered to the buyer's address. Without an orderRef the coupon
+// on the cart applies, with one the coupon reserved for that payment does.
+func (s UserService) priceItems(uId uint, orderRef string, items []domain.Cart) (dto.CartPricing, domain.Address, error) {
+	user, err := s.UserRepo.FindUserById(uId)
+	if err != nil {
+		return dto.CartPricing{}, domain.Address{}, err
+	}
+
+	quote := dto.ShippingQuote{Lines: []dto.ShippingLine{}}
+	if s.Shipping != nil {
+		if quote, err = s.Shipping.Quote(uId, items, user.Address); err != nil {
+			return dto.CartPricing{}, domain.Address{}, err
+		}
+	}
+
+	pricing := basePricing(items, quote.Total)
+	switch {
+	case s.Coupons == nil:
+	case orderRef == "":
+		pricing = s.Coupons.PriceCart(uId, items, quote.Total)
+	default:
+		pricing = s.Coupons.PriceOrder(orderRef, uId, items, quote.Total)
+	}
+	pricing.ShippingLines = quote.Lines
+	pricing.ShippingError = quote.Error
+
+	if s.Taxes != nil {
+		if err := s.Taxes.ApplyTax(&pricing, items, user.Address); err != nil {
+			return dto.CartPricing{}, domain.Address{}, err
+		}
+	}
+	return pricing, user.Address, nil
+}
+
 func (s UserService) CreateCart(input dto.CreateCartRequest, u domain.User) ([]domain.Cart, error) {
 	// check if the cart is Exist
 
-	cart, _ := s.UserRepo.FindCartItem(u.ID, input.ProductId)
+	cart, _ := s.UserRepo.FindCartItem(u.ID, input.ProductId, input.VariantId)
 
 	if cart.ID > 0 {
 		if input.ProductId == 0 {
@@ -302,6 +614,9 @@ func (s UserService) CreateCart(input dto.CreateCartRequest, u domain.User) ([]d
 
 		} else {
 			// => update the cart item
+			if err := s.checkStock(cart.ProductId, cart.VariantId, input.Qty); err != nil {
+				return nil, err
+			}
 			cart.Qty = input.Qty
 			err := s.UserRepo.UpdateCart(cart)
 			if err != nil {
@@ -313,20 +628,46 @@ func (s UserService) CreateCart(input dto.CreateCartRequest, u domain.User) ([]d
 	} else {
 		// check if product exists
 		product, _ := s.CatalogRepo.FindProductById(int(input.ProductId))
-		if product.ID < 1 {
+		if product == nil || product.ID < 1 {
 			return nil, errors.New("product not found")
 		}
+		if input.Qty < 1 {
+			return nil, errors.New("please provide a valid qty")
+		}
 
-		// create cart
-		err := s.UserRepo.CreateCart(domain.Cart{
+		item := domain.Cart{
 			UserId:    u.ID,
 			ProductId: input.ProductId,
 			Name:      product.Name,
 			ImageUrl:  product.ImageUrl,
 			Qty:       input.Qty,
 			Price:     product.Price,
+			TaxClass:  taxClass(product.TaxClass),
+			Weight:    product.Weight,
 			SellerId:  uint(product.UserId),
-		})
+		}
+
+		variant, stock, err := stockOf(product, input.VariantId)
+		if err != nil {
+			return nil, err
+		}
+		if variant != nil {
+			// the line snapshots the variant so later catalog edits do not change it
+			item.VariantId = variant.ID
+			item.Sku = variant.Sku
+			item.Options = variant.Options
+			item.Price = variant.EffectivePrice(*product)
+			item.Name = fmt.Sprintf("%s (%s)", product.Name, variant.Label(product.Options))
+			if variant.ImageUrl != "" {
+				item.ImageUrl = variant.ImageUrl
+			}
+		}
+		if stock < int(input.Qty) {
+			return nil, fmt.Errorf("only %d of %s left in stock", max(stock, 0), item.Name)
+		}
+
+		// create cart
+		err = s.UserRepo.CreateCart(item)
 		if err != nil {
 			return nil, errors.New("error on creating cart item")
 		}
@@ -334,41 +675,105 @@ func (s UserService) CreateCart(input dto.CreateCartRequest, u domain.User) ([]d
 
 	return s.UserRepo.FindCartItems(u.ID)
 }
-func (s UserService) CreateOrder(uId uint, orderRef string, pId string, amount float64) error {
 
-	// find cart items for the user
-	cartitems, _, err := s.FindCart(uId)
-	if err != nil {
-		return errors.New("error on finding cart items")
+// stockOf resolves the variant a buyer picked and the stock available for it
+func stockOf(product *domain.Product, variantId uint) (*domain.ProductVariant, int, error) {
+	if !product.HasVariants() {
+		if variantId > 0 {
+			return nil, 0, errors.New("product has no variants")
+		}
+		return nil, product.Stock, nil
 	}
-
-	if len(cartitems) == 0 {
-		return errors.New("cart is empty cannot create the order")
+	if variantId == 0 {
+		return nil, 0, errors.New("please choose a variant of this product")
 	}
+	variant, ok := product.FindVariant(variantId)
+	if !ok {
+		return nil, 0, errors.New("variant not found")
+	}
+	return variant, variant.Stock, nil
+}
 
-	// find success payment refrence status
+// checkStock makes sure the requested quantity is currently available; the stock is only
+// held once the buyer starts paying
+func (s UserService) checkStock(productId uint, variantId uint, qty uint) error {
+	product, err := s.CatalogRepo.FindProductById(int(productId))
+	if err != nil {
+		return errors.New("product not found")
+	}
+	_, stock, err := stockOf(product, variantId)
+	if err != nil {
+		return err
+	}
+	if stock < int(qty) {
+		return fmt.Errorf("only %d of %s left in stock", max(stock, 0), product.Name)
+	}
+	return nil
+}
 
-	orderItems := make([]domain.OrderItem, 0, len(cartitems))
+// CreateOrder turns the checkout a payment was made for into an order. It is safe to call more
+// than once for the same payment: payment verification and the payment webhook may both call it.
+func (s UserService) CreateOrder(p *domain.Payment) error {
 
-	for _, item := range cartitems {
-		amount += item.Price * float64(item.Qty)
+	existing, err := s.UserRepo.FindOrderByRef(p.OrderId)
+	if err != nil {
+		return errors.New("error on finding order")
+	}
+	if existing.ID > 0 {
+		return nil
+	}
 
-		orderItems = append(orderItems, domain.OrderItem{
-			ProductId: item.ProductId,
-			Qty:       item.Qty,
-			Price:     item.Price,
-			Name:      item.Name,
-			ImageUrl:  item.ImageUrl,
-			SellerId:  item.SellerId,
-		})
+	checkout := p.Checkout
+	if checkout == nil {
+		// payments started before checkouts were stored: price the cart the way the
+		// payment was priced, with the coupon reserved for it
+		cartitems, _, err := s.FindCart(p.UserId)
+		if err != nil {
+			return errors.New("error on finding cart items")
+		}
+		if len(cartitems) == 0 {
+			return errors.New("cart is empty cannot create the order")
+		}
+		pricing, address, err := s.priceItems(p.UserId, p.OrderId, cartitems)
+		if err != nil {
+			return errors.New("error on pricing the order")
+		}
+		if pricing.ShippingError != "" {
+			// the payment was taken, the order is created and the seller sorts shipping out
+			log.Printf("order %s shipping: %s", p.OrderId, pricing.ShippingError)
+		}
+		checkout = newCheckout(cartitems, pricing, address)
+	}
+	if len(checkout.Items) == 0 {
+		return errors.New("checkout is empty cannot create the order")
 	}
 
+	// copies, the checkout stays as it was stored
+	orderItems := slices.Clone(checkout.Items)
+	shipping := slices.Clone(checkout.Shipping)
+
 	order := domain.Order{
-		UserId:         uId,
-		PaymentId:      pId,
-		OrderRefNumber: orderRef, // string
-		Amount:         amount,
-		Items:          orderItems,
+		UserId:          p.UserId,
+		PaymentId:       p.PaymentId,
+		OrderRefNumber:  p.OrderId, // string
+		Amount:          p.Amount,  // what the payment captured, the checkout was priced to match it
+		DiscountAmount:  checkout.DiscountAmount,
+		CouponCode:      checkout.CouponCode,
+		TaxAmount:       checkout.TaxAmount,
+		ShippingAmount:  checkout.ShippingAmount,
+		ShippingAddress: checkout.ShippingAddress,
+		Shipping:        shipping,
+		Items:           orderItems,
+		// one fulfilment group per seller, paid like the order
+		SubOrders: buildSubOrders(orderItems, shipping, checkout.ShippingDiscount, domain.OrderStatusPaid),
+		// orders are only created once the payment has succeeded
+		Status: domain.OrderStatusPaid,
+		History: []domain.OrderStatusHistory{{
+			FromStatus: domain.OrderStatusPending,
+			ToStatus:   domain.OrderStatusPaid,
+			ActorRole:  domain.OrderActorSystem,
+			Note:       "payment " + p.PaymentId,
+		}},
 	}
 
 	if err := s.UserRepo.CreateOrder(order); err != nil {
@@ -378,7 +783,7 @@ func (s UserService) CreateOrder(uId uint, orderRef string, pId string, amount f
 	// send email to user with order details
 
 	// remove cart items from the cart
-	err = s.UserRepo.DeleteCartItems(uId)
+	err = s.UserRepo.DeleteCartItems(p.UserId)
 	log.Printf("Deleting cart items Error %v", err)
 
 	// return order number
@@ -386,6 +791,52 @@ func (s UserService) CreateOrder(uId uint, orderRef string, pId string, amount f
 	return err
 
 }
+
+// newCheckout snapshots priced cart items as the lines, shipping and address of an order
+func newCheckout(items []domain.Cart, pricing dto.CartPricing, address domain.Address) *domain.Checkout {
+	shipping := make([]domain.OrderShipping, 0, len(pricing.ShippingLines))
+	for _, line := range pricing.ShippingLines {
+		shipping = append(shipping, domain.OrderShipping{
+			SellerId: line.SellerId,
+			MethodId: line.MethodId,
+			Name:     line.Name,
+			Type:     line.Type,
+			Cost:     line.Cost,
+		})
+	}
+
+	orderItems := make([]domain.OrderItem, 0, len(items))
+	for _, item := range items {
+		tax := pricing.ItemTax(item.ID)
+		orderItems = append(orderItems, domain.OrderItem{
+			ProductId:    item.ProductId,
+			VariantId:    item.VariantId,
+			Sku:          item.Sku,
+			Options:      item.Options,
+			Qty:          item.Qty,
+			Price:        item.Price,
+			Discount:     pricing.ItemDiscount(item.ID),
+			TaxAmount:    tax.Amount,
+			TaxRate:      tax.Rate,
+			TaxInclusive: tax.Inclusive,
+			Name:         item.Name,
+			ImageUrl:     item.ImageUrl,
+			SellerId:     item.SellerId,
+		})
+	}
+
+	return &domain.Checkout{
+		Items:            orderItems,
+		Shipping:         shipping,
+		ShippingAddress:  domain.NewShippingAddress(address),
+		CouponCode:       pricing.CouponCode,
+		DiscountAmount:   pricing.DiscountTotal + pricing.ShippingDiscount,
+		ShippingDiscount: pricing.ShippingDiscount,
+		TaxAmount:        pricing.Tax,
+		ShippingAmount:   pricing.Shipping,
+	}
+}
+
 func (s UserService) GetOrders(u domain.User) ([]domain.Order, error) {
 	orders, err := s.UserRepo.FindOrders(u.ID)
 	if err != nil {
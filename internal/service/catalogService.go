@@ -1,13 +1,27 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"go-ecommerce-app/config"
+	"image"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
 
 	"go-ecommerce-app/internal/domain"
 	"go-ecommerce-app/internal/dto"
 	"go-ecommerce-app/internal/helper"
 	"go-ecommerce-app/internal/repository"
+	"go-ecommerce-app/pkg/imaging"
+	"go-ecommerce-app/pkg/storage"
 )
 
 // Catalog service handles all business logic related to users
@@ -16,12 +30,24 @@ type CatalogService struct {
 	CatalogRepo repository.CatalogRepository // DB operations for user
 	Auth        helper.Auth                  // Auth tools: hashing, token, verify
 	Config      config.AppConfig
+	Storage     storage.Storage // where product images are uploaded to
 }
 
 func (s CatalogService) CreateCategory(input dto.CreateCategoryRequest) error {
 
+	var parentId uint
+	if input.ParentId != nil {
+		parentId = *input.ParentId
+	}
+	if parentId > 0 {
+		if _, err := s.CatalogRepo.FindCategoryById(int(parentId)); err != nil {
+			return errors.New("parent category does not exist")
+		}
+	}
+
 	err := s.CatalogRepo.CreateCategory(&domain.Category{
 		Name:         input.Name,
+		ParentId:     parentId,
 		ImageUrl:     input.ImageUrl,
 		DisplayOrder: input.DisplayOrder,
 	})
@@ -40,12 +66,23 @@ func (s CatalogService) EditCategory(id int, input dto.CreateCategoryRequest) (*
 		existCat.Name = input.Name
 	}
 
-	if input.ParentId > 0 {
-		existCat.ParentId = input.ParentId
+	// 0 moves the category back to the top level, which cannot create a cycle
+	if input.ParentId != nil && *input.ParentId != existCat.ParentId {
+		if err := s.checkCategoryParent(existCat.ID, *input.ParentId); err != nil {
+			return nil, err
+		}
+		existCat.ParentId = *input.ParentId
 	}
 
-	if len(input.ImageUrl) > 0 {
+	var oldImage []string
+	if len(input.ImageUrl) > 0 && input.ImageUrl != existCat.ImageUrl {
+		// an external url replaces the uploaded image and its sizes
+		if existCat.ImageKey != "" {
+			oldImage = imageKeys(existCat.ImageKey, existCat.ImageSizes)
+		}
 		existCat.ImageUrl = input.ImageUrl
+		existCat.ImageKey = ""
+		existCat.ImageSizes = nil
 	}
 
 	if input.DisplayOrder > 0 {
@@ -53,19 +90,53 @@ func (s CatalogService) EditCategory(id int, input dto.CreateCategoryRequest) (*
 	}
 
 	updatedCat, err := s.CatalogRepo.EditCategory(existCat)
+	if err == nil {
+		s.removeFiles(context.Background(), oldImage)
+	}
 
 	return updatedCat, err
 
 }
 
-func (s CatalogService) DeleteCategory(id int) error {
+// checkCategoryParent makes sure moving a category under parentId keeps the tree acyclic
+func (s CatalogService) checkCategoryParent(id uint, parentId uint) error {
+
+	// walk up from the new parent, finding the category itself means a cycle
+	seen := map[uint]bool{}
+	for current := parentId; current > 0; {
+		if current == id {
+			return errors.New("a category cannot be moved under itself or one of its subcategories")
+		}
+		if seen[current] {
+			return errors.New("category tree is corrupted, a cycle already exists")
+		}
+		seen[current] = true
+
+		parent, err := s.CatalogRepo.FindCategoryById(int(current))
+		if err != nil {
+			return errors.New("parent category does not exist")
+		}
+		current = parent.ParentId
+	}
+	return nil
+}
 
-	err := s.CatalogRepo.DeleteCategory(id)
+func (s CatalogService) DeleteCategory(id int, policy dto.CategoryDeletePolicy) error {
+
+	switch policy {
+	case "":
+		policy = dto.CategoryDeleteRefuse
+	case dto.CategoryDeleteRefuse, dto.CategoryDeleteReparent, dto.CategoryDeleteCascade:
+	default:
+		return errors.New("policy must be one of refuse, reparent, cascade")
+	}
+
+	cat, err := s.CatalogRepo.FindCategoryById(id)
 	if err != nil {
-		// log the error
 		return errors.New("category does not exist to delete")
 	}
-	return nil
+
+	return s.CatalogRepo.DeleteCategoryWithPolicy(cat, policy)
 }
 
 func (s CatalogService) GetCategories() ([]*domain.Category, error) {
@@ -78,6 +149,45 @@ func (s CatalogService) GetCategories() ([]*domain.Category, error) {
 	return categories, nil
 }
 
+// GetCategoryTree nests categories under their parents, siblings ordered by DisplayOrder
+func (s CatalogService) GetCategoryTree() ([]*dto.CategoryNode, error) {
+
+	categories, err := s.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*dto.CategoryNode, len(categories))
+	for _, cat := range categories {
+		nodes[cat.ID] = &dto.CategoryNode{
+			ID:           cat.ID,
+			Name:         cat.Name,
+			ParentId:     cat.ParentId,
+			ImageUrl:     cat.ImageUrl,
+			ImageSizes:   cat.ImageSizes,
+			DisplayOrder: cat.DisplayOrder,
+			CreatedAt:    cat.CreatedAt,
+			UpdatedAt:    cat.UpdatedAt,
+			Children:     []*dto.CategoryNode{},
+		}
+	}
+
+	// categories come sorted, appending keeps the order within each level
+	roots := make([]*dto.CategoryNode, 0)
+	for _, cat := range categories {
+		node := nodes[cat.ID]
+		parent, ok := nodes[cat.ParentId]
+		if cat.ParentId == 0 || !ok || parent == node {
+			// orphans are shown at the top level rather than hidden
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	return roots, nil
+}
+
 func (s CatalogService) GetCategory(id int) (*domain.Category, error) {
 	cat, err := s.CatalogRepo.FindCategoryById(id)
 	if err != nil {
@@ -94,6 +204,8 @@ func (s CatalogService) CreateProduct(input dto.CreateProductRequest, user domai
 		CategoryId:  input.CategoryId,
 		UserId:      int(user.ID),
 		Stock:       int(input.Stock),
+		TaxClass:    taxClass(input.TaxClass),
+		Weight:      max(input.Weight, 0),
 	})
 	return err
 }
@@ -127,6 +239,14 @@ func (s CatalogService) EditProduct(id int, input dto.CreateProductRequest, user
 		existProduct.CategoryId = input.CategoryId
 	}
 
+	if input.TaxClass != "" {
+		existProduct.TaxClass = taxClass(input.TaxClass)
+	}
+
+	if input.Weight > 0 {
+		existProduct.Weight = input.Weight
+	}
+
 	updatedProduct, err := s.CatalogRepo.EditProduct(existProduct)
 
 	return updatedProduct, err
@@ -147,17 +267,36 @@ func (s CatalogService) DeleteProduct(id int, user domain.User) error {
 	if err != nil {
 		return errors.New("product cannot delete")
 	}
+	s.removeImages(context.Background(), existProduct.Images)
 
 	return nil
 }
 
-func (s CatalogService) GetProducts() ([]*domain.Product, error) {
-	products, err := s.CatalogRepo.FindProducts()
+func (s CatalogService) GetProducts(filter dto.ProductFilter) (dto.ProductList, error) {
+
+	switch filter.Sort {
+	case "", dto.ProductSortNewest, dto.ProductSortPriceAsc, dto.ProductSortPriceDesc, dto.ProductSortName:
+	default:
+		return dto.ProductList{}, errors.New("sort must be one of newest, price_asc, price_desc, name")
+	}
+	if filter.MaxPrice > 0 && filter.MinPrice > filter.MaxPrice {
+		return dto.ProductList{}, errors.New("min_price cannot be greater than max_price")
+	}
+	filter.Normalize()
+
+	products, total, err := s.CatalogRepo.FindProducts(filter)
 	if err != nil {
-		return nil, errors.New("products do not exist")
+		return dto.ProductList{}, errors.New("products do not exist")
 	}
 
-	return products, nil
+	return dto.ProductList{
+		Products: products,
+		Pagination: dto.PaginationResponse{
+			Page:  filter.Page,
+			Limit: filter.Limit,
+			Total: total,
+		},
+	}, nil
 }
 
 func (s CatalogService) GetProductsById(id int) (*domain.Product, error) {
@@ -169,16 +308,12 @@ func (s CatalogService) GetProductsById(id int) (*domain.Product, error) {
 	return product, nil
 }
 
-func (s CatalogService) GetSellerProducts(id int) ([]*domain.Product, error) {
-	products, err := s.CatalogRepo.FindSellerProducts(id)
-	if err != nil {
-		return nil, errors.New("products do not exist")
-	}
-
-	return products, nil
+func (s CatalogService) GetSellerProducts(id int, filter dto.ProductFilter) (dto.ProductList, error) {
+	filter.SellerId = uint(id)
+	return s.GetProducts(filter)
 }
 
-func (s CatalogService) UpdatedProductStock(e domain.Product) (*domain.Product, error) {
+func (s CatalogService) UpdatedProductStock(e domain.Product, reason string) (*domain.Product, error) {
 	product, err := s.CatalogRepo.FindProductById(int(e.ID))
 	if err != nil {
 		return nil, errors.New("product not found")
@@ -188,10 +323,592 @@ func (s CatalogService) UpdatedProductStock(e domain.Product) (*domain.Product,
 	if product.UserId != e.UserId {
 		return nil, errors.New("you don't have manage rights of this product")
 	}
-	product.Stock = e.Stock
-	editProduct, err := s.CatalogRepo.EditProduct(product)
+	if product.HasVariants() {
+		return nil, errors.New("product has variants, update the stock of each variant")
+	}
+	if e.Stock < 0 {
+		return nil, errors.New("stock cannot be negative")
+	}
+	if reason == "" {
+		reason = "manual adjustment"
+	}
+
+	return s.CatalogRepo.AdjustStock(product.ID, e.Stock, domain.StockMovement{
+		Type:    domain.StockMovementAdjustment,
+		Reason:  reason,
+		ActorId: uint(e.UserId),
+	})
+}
+
+// SetProductOptions replaces the option axes (size, colour...) variants are built from
+func (s CatalogService) SetProductOptions(id int, user domain.User, input dto.ProductOptionsRequest) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]domain.ProductOption, 0, len(input.Options))
+	names := map[string]bool{}
+	for i, opt := range input.Options {
+		name := strings.TrimSpace(opt.Name)
+		if name == "" || names[name] {
+			return nil, errors.New("option names must be set and unique")
+		}
+		names[name] = true
+
+		values := make([]string, 0, len(opt.Values))
+		seen := map[string]bool{}
+		for _, v := range opt.Values {
+			v = strings.TrimSpace(v)
+			if v == "" || seen[v] {
+				return nil, fmt.Errorf("values of option %s must be set and unique", name)
+			}
+			seen[v] = true
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("option %s needs at least one value", name)
+		}
+
+		options = append(options, domain.ProductOption{Name: name, Values: values, Position: i})
+	}
+
+	// existing variants must still be valid combinations
+	for _, v := range product.Variants {
+		if err := validateVariantOptions(options, v.Options); err != nil {
+			return nil, fmt.Errorf("variant %s does not fit the new options: %w", v.Sku, err)
+		}
+	}
+
+	if err := s.CatalogRepo.SetProductOptions(product.ID, options); err != nil {
+		return nil, err
+	}
+	return s.CatalogRepo.FindProductById(id)
+}
+
+func (s CatalogService) CreateVariant(id int, user domain.User, input dto.ProductVariantRequest) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := domain.ProductVariant{
+		ProductId: product.ID,
+		Sku:       strings.TrimSpace(input.Sku),
+		Options:   input.Options,
+		Price:     input.Price,
+		Stock:     input.Stock,
+		ImageUrl:  input.ImageUrl,
+	}
+	if variant.Stock < 0 {
+		return nil, errors.New("stock cannot be negative")
+	}
+	if err := checkVariant(product, variant); err != nil {
+		return nil, err
+	}
+
+	if err := s.CatalogRepo.CreateVariant(&variant, user.ID); err != nil {
+		return nil, err
+	}
+	return s.CatalogRepo.FindProductById(id)
+}
+
+func (s CatalogService) EditVariant(id int, variantId uint, user domain.User, input dto.ProductVariantRequest) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
 	if err != nil {
 		return nil, err
 	}
-	return editProduct, nil
+	existing, ok := product.FindVariant(variantId)
+	if !ok {
+		return nil, errors.New("variant does not exist")
+	}
+
+	variant := *existing
+	if sku := strings.TrimSpace(input.Sku); sku != "" {
+		variant.Sku = sku
+	}
+	if len(input.Options) > 0 {
+		variant.Options = input.Options
+	}
+	if input.Price != nil {
+		variant.Price = input.Price
+	}
+	if input.ImageUrl != "" {
+		variant.ImageUrl = input.ImageUrl
+	}
+	if err := checkVariant(product, variant); err != nil {
+		return nil, err
+	}
+
+	if err := s.CatalogRepo.EditVariant(&variant); err != nil {
+		return nil, err
+	}
+	return s.CatalogRepo.FindProductById(id)
+}
+
+func (s CatalogService) DeleteVariant(id int, variantId uint, user domain.User) error {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return err
+	}
+	variant, ok := product.FindVariant(variantId)
+	if !ok {
+		return errors.New("variant does not exist")
+	}
+	return s.CatalogRepo.DeleteVariant(variant)
+}
+
+func (s CatalogService) UpdateVariantStock(id int, variantId uint, user domain.User, input dto.UpdateStockRequest) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := product.FindVariant(variantId); !ok {
+		return nil, errors.New("variant does not exist")
+	}
+	if input.Stock < 0 {
+		return nil, errors.New("stock cannot be negative")
+	}
+	reason := input.Reason
+	if reason == "" {
+		reason = "manual adjustment"
+	}
+
+	return s.CatalogRepo.AdjustStock(product.ID, input.Stock, domain.StockMovement{
+		VariantId: variantId,
+		Type:      domain.StockMovementAdjustment,
+		Reason:    reason,
+		ActorId:   user.ID,
+	})
+}
+
+// checkVariant validates a variant against the product's option axes and its other variants
+func checkVariant(product *domain.Product, v domain.ProductVariant) error {
+	if v.Sku == "" {
+		return errors.New("sku is required")
+	}
+	if v.Price != nil && *v.Price < 0 {
+		return errors.New("price cannot be negative")
+	}
+	if len(product.Options) == 0 {
+		return errors.New("set the product options before adding variants")
+	}
+	if err := validateVariantOptions(product.Options, v.Options); err != nil {
+		return err
+	}
+	for _, other := range product.Variants {
+		if other.ID != v.ID && sameOptions(other.Options, v.Options) {
+			return fmt.Errorf("variant %s already has these options", other.Sku)
+		}
+	}
+	return nil
+}
+
+// validateVariantOptions requires exactly one allowed value for every option axis
+func validateVariantOptions(options []domain.ProductOption, values map[string]string) error {
+	if len(values) != len(options) {
+		return errors.New("a variant needs one value for each product option")
+	}
+	for _, opt := range options {
+		value, ok := values[opt.Name]
+		if !ok {
+			return fmt.Errorf("missing value for option %s", opt.Name)
+		}
+		if !slices.Contains(opt.Values, value) {
+			return fmt.Errorf("%s is not a valid %s", value, opt.Name)
+		}
+	}
+	return nil
+}
+
+func sameOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	MaxProductImages = 10
+	MaxImageSize     = 5 << 20 // 5MB
+)
+
+// imageExtensions lists the accepted upload types
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// storedImage is an uploaded original together with its generated sizes
+type storedImage struct {
+	Key         string
+	Url         string
+	ContentType string
+	Size        int64
+	Sizes       domain.ImageSizes
+}
+
+// UploadProductImages stores the files and appends them to the product images in order
+func (s CatalogService) UploadProductImages(id int, user domain.User, files []*multipart.FileHeader) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if s.Storage == nil {
+		return nil, errors.New("image storage is not configured")
+	}
+	if len(files) == 0 {
+		return nil, errors.New("please provide at least one image")
+	}
+	if len(product.Images)+len(files) > MaxProductImages {
+		return nil, fmt.Errorf("a product can have at most %d images", MaxProductImages)
+	}
+
+	ctx := context.Background()
+	images := make([]domain.ProductImage, 0, len(files))
+	for _, file := range files {
+		stored, err := s.storeImage(ctx, fmt.Sprintf("products/%d", product.ID), file)
+		if err != nil {
+			s.removeImages(ctx, images)
+			return nil, err
+		}
+		images = append(images, domain.ProductImage{
+			ProductId:   product.ID,
+			Url:         stored.Url,
+			StorageKey:  stored.Key,
+			ContentType: stored.ContentType,
+			Size:        stored.Size,
+			Sizes:       stored.Sizes,
+		})
+	}
+
+	if _, err := s.CatalogRepo.AddProductImages(product.ID, images); err != nil {
+		s.removeImages(ctx, images)
+		return nil, err
+	}
+	return s.CatalogRepo.FindProductById(id)
+}
+
+// UploadCategoryImage replaces the category image with an uploaded one
+func (s CatalogService) UploadCategoryImage(id int, file *multipart.FileHeader) (*domain.Category, error) {
+	cat, err := s.CatalogRepo.FindCategoryById(id)
+	if err != nil {
+		return nil, errors.New("category does not exist")
+	}
+	if s.Storage == nil {
+		return nil, errors.New("image storage is not configured")
+	}
+
+	ctx := context.Background()
+	stored, err := s.storeImage(ctx, fmt.Sprintf("categories/%d", cat.ID), file)
+	if err != nil {
+		return nil, err
+	}
+
+	oldKey, oldSizes := cat.ImageKey, cat.ImageSizes
+	cat.ImageUrl = stored.Url
+	cat.ImageKey = stored.Key
+	cat.ImageSizes = stored.Sizes
+	updated, err := s.CatalogRepo.EditCategory(cat)
+	if err != nil {
+		s.removeFiles(ctx, imageKeys(stored.Key, stored.Sizes))
+		return nil, err
+	}
+	if oldKey != "" {
+		s.removeFiles(ctx, imageKeys(oldKey, oldSizes))
+	}
+	return updated, nil
+}
+
+// storeImage validates an upload, stores it under prefix and generates its sizes
+func (s CatalogService) storeImage(ctx context.Context, prefix string, file *multipart.FileHeader) (*storedImage, error) {
+	if file.Size > MaxImageSize {
+		return nil, fmt.Errorf("%s is larger than %dMB", file.Filename, MaxImageSize>>20)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s", file.Filename)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxImageSize+1))
+	if err != nil || len(data) > MaxImageSize {
+		return nil, fmt.Errorf("cannot read %s", file.Filename)
+	}
+
+	// trust the content, not the file name or the client supplied header
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a jpeg, png, webp or gif image", file.Filename)
+	}
+	decoded, err := imaging.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s cannot be read as an image", file.Filename)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, errors.New("failed to store image")
+	}
+	key := prefix + "/" + name + ext
+
+	url, err := s.Storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		log.Printf("storage_err: %v", err)
+		return nil, errors.New("failed to store image")
+	}
+
+	sizes, err := s.storeImageSizes(ctx, key, decoded)
+	if err != nil {
+		s.removeFiles(ctx, []string{key})
+		return nil, errors.New("failed to store image")
+	}
+
+	return &storedImage{
+		Key:         key,
+		Url:         url,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Sizes:       sizes,
+	}, nil
+}
+
+// storeImageSizes generates the standard sizes of an image and stores them next to it
+func (s CatalogService) storeImageSizes(ctx context.Context, key string, img image.Image) (domain.ImageSizes, error) {
+	derivatives, err := imaging.Generate(img)
+	if err != nil {
+		log.Printf("imaging_err: %v", err)
+		return nil, err
+	}
+
+	sizes := domain.ImageSizes{}
+	stored := make([]string, 0, len(derivatives))
+	for _, d := range derivatives {
+		dKey := imaging.Key(key, d.Size, d.Format)
+		url, err := s.Storage.Put(ctx, dKey, bytes.NewReader(d.Data), int64(len(d.Data)), d.Format.ContentType())
+		if err != nil {
+			log.Printf("storage_err: %v", err)
+			s.removeFiles(ctx, stored)
+			return nil, err
+		}
+		stored = append(stored, dKey)
+
+		if sizes[d.Size] == nil {
+			sizes[d.Size] = map[string]string{}
+		}
+		sizes[d.Size][string(d.Format)] = url
+	}
+	return sizes, nil
+}
+
+// RegenerateImageSizes rebuilds the sizes of every stored product and category image from
+// the originals, e.g. after the standard sizes changed. It returns how many images were done.
+func (s CatalogService) RegenerateImageSizes(ctx context.Context) (int, error) {
+	if s.Storage == nil {
+		return 0, errors.New("image storage is not configured")
+	}
+
+	done := 0
+	var lastId uint
+	for {
+		images, err := s.CatalogRepo.FindProductImagesAfter(lastId, 100)
+		if err != nil {
+			return done, err
+		}
+		if len(images) == 0 {
+			break
+		}
+		for i := range images {
+			img := &images[i]
+			lastId = img.ID
+			sizes, err := s.regenerate(ctx, img.StorageKey)
+			if err != nil {
+				log.Printf("regenerate_err: product image %d: %v", img.ID, err)
+				continue
+			}
+			img.Sizes = sizes
+			if err := s.CatalogRepo.UpdateProductImageSizes(img); err != nil {
+				return done, err
+			}
+			done++
+		}
+	}
+
+	categories, err := s.CatalogRepo.FindCategories()
+	if err != nil {
+		return done, err
+	}
+	for _, cat := range categories {
+		if cat.ImageKey == "" {
+			continue
+		}
+		sizes, err := s.regenerate(ctx, cat.ImageKey)
+		if err != nil {
+			log.Printf("regenerate_err: category %d: %v", cat.ID, err)
+			continue
+		}
+		cat.ImageSizes = sizes
+		if _, err := s.CatalogRepo.EditCategory(cat); err != nil {
+			return done, err
+		}
+		done++
+	}
+
+	return done, nil
+}
+
+func (s CatalogService) regenerate(ctx context.Context, key string) (domain.ImageSizes, error) {
+	r, err := s.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.storeImageSizes(ctx, key, img)
+}
+
+func (s CatalogService) SetPrimaryImage(id int, imageId uint, user domain.User) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := product.FindImage(imageId); !ok {
+		return nil, errors.New("image does not exist")
+	}
+
+	if err := s.CatalogRepo.SetPrimaryImage(product.ID, imageId); err != nil {
+		return nil, err
+	}
+	return s.CatalogRepo.FindProductById(id)
+}
+
+func (s CatalogService) ReorderProductImages(id int, imageIds []uint, user domain.User) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[uint]bool{}
+	for _, imageId := range imageIds {
+		if _, ok := product.FindImage(imageId); !ok || seen[imageId] {
+			return nil, fmt.Errorf("image %d is unknown or listed twice", imageId)
+		}
+		seen[imageId] = true
+	}
+
+	if err := s.CatalogRepo.ReorderProductImages(product.ID, imageIds); err != nil {
+		return nil, err
+	}
+	return s.CatalogRepo.FindProductById(id)
+}
+
+func (s CatalogService) DeleteProductImage(id int, imageId uint, user domain.User) (*domain.Product, error) {
+	product, err := s.findOwnProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	img, ok := product.FindImage(imageId)
+	if !ok {
+		return nil, errors.New("image does not exist")
+	}
+
+	if err := s.CatalogRepo.DeleteProductImage(img); err != nil {
+		return nil, err
+	}
+	s.removeImages(context.Background(), []domain.ProductImage{*img})
+	return s.CatalogRepo.FindProductById(id)
+}
+
+// removeImages deletes the stored files of product images
+func (s CatalogService) removeImages(ctx context.Context, images []domain.ProductImage) {
+	for _, img := range images {
+		s.removeFiles(ctx, imageKeys(img.StorageKey, img.Sizes))
+	}
+}
+
+// removeFiles deletes stored files, failures only leave an unreferenced file behind
+func (s CatalogService) removeFiles(ctx context.Context, keys []string) {
+	if s.Storage == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := s.Storage.Delete(ctx, key); err != nil {
+			log.Printf("storage_err: failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// imageKeys lists the storage keys of an original and its generated sizes
+func imageKeys(key string, sizes domain.ImageSizes) []string {
+	keys := []string{key}
+	for size, formats := range sizes {
+		for format := range formats {
+			keys = append(keys, imaging.Key(key, size, imaging.Format(format)))
+		}
+	}
+	return keys
+}
+
+func randomName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s CatalogService) GetStockMovements(id int, user domain.User, p dto.PaginationRequest) (dto.StockMovementList, error) {
+	if _, err := s.findOwnProduct(id, user); err != nil {
+		return dto.StockMovementList{}, err
+	}
+
+	p.Normalize()
+	movements, total, err := s.CatalogRepo.FindStockMovements(uint(id), p)
+	if err != nil {
+		return dto.StockMovementList{}, err
+	}
+
+	return dto.StockMovementList{
+		Movements: movements,
+		Pagination: dto.PaginationResponse{
+			Page:  p.Page,
+			Limit: p.Limit,
+			Total: total,
+		},
+	}, nil
+}
+
+// RebuildProductStock resets the product stock to the sum of its ledger
+func (s CatalogService) RebuildProductStock(id int, user domain.User) (*domain.Product, error) {
+	if _, err := s.findOwnProduct(id, user); err != nil {
+		return nil, err
+	}
+	return s.CatalogRepo.RebuildStock(uint(id))
+}
+
+func (s CatalogService) findOwnProduct(id int, user domain.User) (*domain.Product, error) {
+	product, err := s.CatalogRepo.FindProductById(id)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	// verify product owner
+	if product.UserId != int(user.ID) {
+		return nil, errors.New("you don't have manage rights of this product")
+	}
+	return product, nil
 }
@@ -0,0 +1,141 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/HugoSmits86/nativewebp" // also registers the webp decoder
+	"golang.org/x/image/draw"
+)
+
+// MaxPixels guards against decompression bombs, images above it are rejected
+const MaxPixels = 40_000_000
+
+const jpegQuality = 85
+
+// Size is a bounding box images are scaled down to fit in, keeping their aspect ratio
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Sizes are the standard derivatives generated for every stored image
+var Sizes = []Size{
+	{Name: "thumbnail", Width: 150, Height: 150},
+	{Name: "card", Width: 400, Height: 400},
+	{Name: "zoom", Width: 1200, Height: 1200},
+}
+
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatWebP Format = "webp"
+)
+
+var Formats = []Format{FormatJPEG, FormatWebP}
+
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+func (f Format) Ext() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// Derivative is an encoded resized copy of an image
+type Derivative struct {
+	Size   string
+	Format Format
+	Data   []byte
+}
+
+// Decode reads a jpeg, png, gif or webp image, animated images give their first frame
+func Decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, errors.New("image dimensions are too large")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	return img, nil
+}
+
+// Generate renders every standard size in every format
+func Generate(src image.Image) ([]Derivative, error) {
+	derivatives := make([]Derivative, 0, len(Sizes)*len(Formats))
+	for _, size := range Sizes {
+		resized := Fit(src, size.Width, size.Height)
+		for _, format := range Formats {
+			data, err := Encode(resized, format)
+			if err != nil {
+				return nil, fmt.Errorf("encoding %s %s: %w", size.Name, format, err)
+			}
+			derivatives = append(derivatives, Derivative{Size: size.Name, Format: format, Data: data})
+		}
+	}
+	return derivatives, nil
+}
+
+// Fit scales src down to fit in width x height, smaller images are never enlarged
+func Fit(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= width && h <= height {
+		return src
+	}
+
+	scale := min(float64(width)/float64(w), float64(height)/float64(h))
+	dw, dh := max(int(float64(w)*scale+0.5), 1), max(int(float64(h)*scale+0.5), 1)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
+
+func Encode(img image.Image, format Format) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatJPEG:
+		// jpeg has no alpha, put transparent images on white instead of black
+		b := img.Bounds()
+		flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+		if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+	case FormatWebP:
+		if err := nativewebp.Encode(&buf, img, nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown image format %q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// Key names a derivative after the original, products/1/ab12.png -> products/1/ab12_card.webp
+func Key(original string, size string, format Format) string {
+	base := strings.TrimSuffix(original, path.Ext(original))
+	return base + "_" + size + format.Ext()
+}
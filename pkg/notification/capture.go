@@ -0,0 +1,56 @@
+package notification
+
+import (
+	"fmt"
+	"go-ecommerce-app/config"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CaptureMailer keeps emails instead of sending them, for local development and tests.
+// Every message is logged, kept in memory and, when Dir is set, written there as an .eml file.
+type CaptureMailer struct {
+	Dir  string
+	From string
+
+	mu       sync.Mutex
+	messages []Email
+}
+
+func NewCaptureMailer(dir string, from string) (*CaptureMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &CaptureMailer{Dir: dir, From: from}, nil
+}
+
+func newCaptureMailer(cfg config.AppConfig) (Mailer, error) {
+	return NewCaptureMailer(cfg.EmailCaptureDir, cfg.EmailFrom)
+}
+
+func (m *CaptureMailer) Send(msg Email) error {
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	n := len(m.messages)
+	m.mu.Unlock()
+
+	log.Printf("captured email to %s: %s", msg.To, msg.Subject)
+	if m.Dir == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().Format("20060102-150405"), n)
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage(m.From, msg), 0o644)
+}
+
+// Messages returns the emails captured so far, oldest first
+func (m *CaptureMailer) Messages() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Email(nil), m.messages...)
+}
@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"strings"
+)
+
+// Email is a message to a single recipient, sent as multipart/alternative when HTML is set
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(msg Email) error
+}
+
+// captureProvider only keeps emails on disk, it is allowed in development alone
+const captureProvider = "capture"
+
+// NewMailer returns the mailer configured in cfg.EmailProvider. Outside development a provider
+// has to be set and it cannot be capture, so emails are never silently kept instead of sent.
+func NewMailer(cfg config.AppConfig) (Mailer, error) {
+	name := strings.ToLower(cfg.EmailProvider)
+	if name == "" && cfg.IsDev() {
+		name = captureProvider
+	}
+
+	switch name {
+	case "smtp":
+		return newSMTPMailer(cfg)
+	case captureProvider:
+		if !cfg.IsDev() {
+			return nil, errors.New("the capture email provider only keeps emails on disk, it is only allowed with APP_ENV=dev")
+		}
+		return newCaptureMailer(cfg)
+	case "":
+		return nil, errors.New("no email provider configured, available: smtp, capture")
+	default:
+		return nil, fmt.Errorf("unknown email provider %q, available: smtp, capture", name)
+	}
+}
+
+func (c notificationClient) SendEmail(msg Email) error {
+	if msg.To == "" {
+		return errors.New("no email address to send the email to")
+	}
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return errors.New("invalid email address")
+	}
+	return c.mailer.Send(msg)
+}
@@ -0,0 +1,212 @@
+package notification
+
+import (
+	"bytes"
+	"go-ecommerce-app/config"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readMessage(t *testing.T, raw []byte) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("message does not parse: %v\n%s", err, raw)
+	}
+	return msg
+}
+
+func TestBuildMessagePlainText(t *testing.T) {
+	raw := buildMessage("shop@example.com", Email{
+		To:      "buyer@example.com",
+		Subject: "Your code",
+		Body:    "line one\nline two\r\nline three",
+	})
+	msg := readMessage(t, raw)
+
+	for header, want := range map[string]string{
+		"From":         "shop@example.com",
+		"To":           "buyer@example.com",
+		"Subject":      "Your code",
+		"MIME-Version": "1.0",
+		"Content-Type": "text/plain; charset=utf-8",
+	} {
+		if got := msg.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date header: %v", err)
+	}
+
+	body, _ := io.ReadAll(msg.Body)
+	if want := "line one\r\nline two\r\nline three"; string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestBuildMessageKeepsLineBreaksOutOfHeaders(t *testing.T) {
+	subject := "Hello\r\nBcc: victim@example.com\nX-Injected: yes"
+	raw := buildMessage("shop@example.com", Email{To: "buyer@example.com", Subject: subject, Body: "hi"})
+	msg := readMessage(t, raw)
+
+	if got := msg.Header.Get("Bcc"); got != "" {
+		t.Errorf("subject injected a Bcc header: %q", got)
+	}
+	if got := msg.Header.Get("X-Injected"); got != "" {
+		t.Errorf("subject injected a header: %q", got)
+	}
+
+	decoded, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("subject does not decode: %v", err)
+	}
+	if decoded != subject {
+		t.Errorf("subject = %q, want %q", decoded, subject)
+	}
+}
+
+func TestBuildMessageEncodesNonASCIISubject(t *testing.T) {
+	raw := buildMessage("shop@example.com", Email{To: "buyer@example.com", Subject: "Commande expédiée", Body: "hi"})
+	msg := readMessage(t, raw)
+
+	encoded := msg.Header.Get("Subject")
+	if !strings.HasPrefix(encoded, "=?utf-8?q?") {
+		t.Errorf("subject is not encoded: %q", encoded)
+	}
+	decoded, _ := new(mime.WordDecoder).DecodeHeader(encoded)
+	if decoded != "Commande expédiée" {
+		t.Errorf("subject = %q", decoded)
+	}
+}
+
+func TestBuildMessageAlternativeParts(t *testing.T) {
+	raw := buildMessage("shop@example.com", Email{
+		To:      "buyer@example.com",
+		Subject: "Order shipped",
+		Body:    "Your order shipped",
+		HTML:    "<p>Your order <b>shipped</b></p>",
+	})
+	msg := readMessage(t, raw)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	want := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "Your order shipped"},
+		{"text/html; charset=utf-8", "<p>Your order <b>shipped</b></p>"},
+	}
+	for i, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != w.contentType {
+			t.Errorf("part %d Content-Type = %q, want %q", i, got, w.contentType)
+		}
+		body, _ := io.ReadAll(part)
+		if string(body) != w.body {
+			t.Errorf("part %d body = %q, want %q", i, body, w.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected two parts, got more (%v)", err)
+	}
+}
+
+func TestCaptureMailerMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewCaptureMailer(dir, "shop@example.com")
+	if err != nil {
+		t.Fatalf("NewCaptureMailer: %v", err)
+	}
+
+	first := Email{To: "a@example.com", Subject: "first", Body: "1"}
+	second := Email{To: "b@example.com", Subject: "second", Body: "2", HTML: "<p>2</p>"}
+	for _, msg := range []Email{first, second} {
+		if err := m.Send(msg); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	messages := m.Messages()
+	if len(messages) != 2 || messages[0] != first || messages[1] != second {
+		t.Fatalf("Messages = %+v", messages)
+	}
+
+	// callers get a copy
+	messages[0].Subject = "changed"
+	if m.Messages()[0].Subject != "first" {
+		t.Error("Messages exposes the captured slice")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("eml files = %v (%v)", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readMessage(t, raw).Header.Get("To"); got != first.To {
+		t.Errorf("first eml is to %q, want %q", got, first.To)
+	}
+}
+
+func TestSendEmailRejectsBadRecipients(t *testing.T) {
+	capture, _ := NewCaptureMailer("", "shop@example.com")
+	client := notificationClient{mailer: capture}
+
+	for _, to := range []string{"", "a@example.com\r\nBcc: b@example.com", "a@example.com\nX: y"} {
+		if err := client.SendEmail(Email{To: to, Subject: "s", Body: "b"}); err == nil {
+			t.Errorf("SendEmail accepted recipient %q", to)
+		}
+	}
+	if n := len(capture.Messages()); n != 0 {
+		t.Errorf("%d emails were sent", n)
+	}
+}
+
+func TestNewMailerAllowsCaptureOnlyInDev(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.AppConfig
+		capture bool
+		fails   bool
+	}{
+		{name: "dev default", cfg: config.AppConfig{AppEnv: "dev"}, capture: true},
+		{name: "dev capture", cfg: config.AppConfig{AppEnv: "dev", EmailProvider: "capture"}, capture: true},
+		{name: "prod default", cfg: config.AppConfig{AppEnv: "prod"}, fails: true},
+		{name: "prod capture", cfg: config.AppConfig{AppEnv: "prod", EmailProvider: "capture"}, fails: true},
+		{name: "unknown", cfg: config.AppConfig{AppEnv: "dev", EmailProvider: "pigeon"}, fails: true},
+		{name: "smtp", cfg: config.AppConfig{EmailProvider: "smtp", SMTPHost: "mail.example.com", EmailFrom: "shop@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.EmailCaptureDir = ""
+			mailer, err := NewMailer(tt.cfg)
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("NewMailer returned %T", mailer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewMailer: %v", err)
+			}
+			if _, ok := mailer.(*CaptureMailer); ok != tt.capture {
+				t.Errorf("NewMailer returned %T", mailer)
+			}
+		})
+	}
+}
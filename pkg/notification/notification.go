@@ -0,0 +1,30 @@
+package notification
+
+import (
+	"go-ecommerce-app/config"
+)
+
+type NotificationClient interface {
+	SendSMS(phone string, message string) error
+	SendEmail(msg Email) error
+	// SendWebhook posts a JSON body to url, signed with secret
+	SendWebhook(url string, secret string, body []byte) error
+}
+
+type notificationClient struct {
+	config config.AppConfig
+	mailer Mailer
+}
+
+// NewNotificationClient sends texts through Twilio, emails through the provider
+// configured in cfg.EmailProvider and webhooks over HTTP
+func NewNotificationClient(cfg config.AppConfig) (NotificationClient, error) {
+	mailer, err := NewMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &notificationClient{
+		config: cfg,
+		mailer: mailer,
+	}, nil
+}
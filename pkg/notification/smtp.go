@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends through an SMTP relay, STARTTLS is used whenever the server offers it
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string // leave empty for relays without authentication
+	Password string
+	From     string
+}
+
+func newSMTPMailer(cfg config.AppConfig) (Mailer, error) {
+	if cfg.SMTPHost == "" || cfg.EmailFrom == "" {
+		return nil, errors.New("smtp needs SMTP_HOST and EMAIL_FROM")
+	}
+	return &SMTPMailer{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.EmailFrom,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(msg Email) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, m.From, []string{msg.To}, buildMessage(m.From, msg)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+// buildMessage renders msg as an RFC 5322 message
+func buildMessage(from string, msg Email) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		writePart(&b, "text/plain", msg.Body)
+		return []byte(b.String())
+	}
+
+	boundary := fmt.Sprintf("alt-%d", time.Now().UnixNano())
+	b.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	writePart(&b, "text/plain", msg.Body)
+	b.WriteString("\r\n--" + boundary + "\r\n")
+	writePart(&b, "text/html", msg.HTML)
+	b.WriteString("\r\n--" + boundary + "--\r\n")
+	return []byte(b.String())
+}
+
+func writePart(b *strings.Builder, contentType string, body string) {
+	b.WriteString("Content-Type: " + contentType + "; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+}
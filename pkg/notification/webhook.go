@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with the
+// receiver's secret
+const WebhookSignatureHeader = "X-Signature"
+
+var ErrWebhookAddress = errors.New("webhook url must not point to a private, loopback or link-local address")
+
+// webhookClient only connects to public addresses. The check runs on the address actually
+// dialed, so a host that resolves differently after CheckWebhookURL cannot get around it.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				addrPort, err := netip.ParseAddrPort(address)
+				if err != nil || !isPublicAddr(addrPort.Addr()) {
+					return ErrWebhookAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// CheckWebhookURL accepts https urls whose host resolves to public addresses only
+func CheckWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("webhook url must be an https url")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("webhook host %s cannot be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return ErrWebhookAddress
+		}
+	}
+	return nil
+}
+
+// carrier-grade NAT, not covered by netip.Addr.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+func (c notificationClient) SendWebhook(rawUrl string, secret string, body []byte) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Scheme != "https" {
+		return errors.New("webhook: url must be an https url")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rawUrl, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: receiver answered %s", resp.Status)
+	}
+	return nil
+}
+
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,252 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"math"
+	"sync"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake webhook body
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeClient is an in-process PaymentClient for local development and tests.
+// It never touches the network and is fully deterministic: intents are created
+// pending and resolve to the configured outcome the first time their status is read.
+type FakeClient struct {
+	mu            sync.Mutex
+	outcome       IntentStatus
+	webhookSecret string
+	intents       map[string]*PaymentIntent
+	refunds       map[string]float64 // refunded amount per intent
+	refundKeys    map[string]*RefundResult
+}
+
+// NewFakeClient verifies webhooks with webhookSecret, without one every webhook is rejected
+func NewFakeClient(outcome IntentStatus, webhookSecret string) *FakeClient {
+	if outcome == "" {
+		outcome = IntentStatusSucceeded
+	}
+	return &FakeClient{
+		outcome:       outcome,
+		webhookSecret: webhookSecret,
+		intents:       map[string]*PaymentIntent{},
+		refunds:       map[string]float64{},
+		refundKeys:    map[string]*RefundResult{},
+	}
+}
+
+// CreatePayment implements [PaymentClient].
+func (f *FakeClient) CreatePayment(amount float64, userId uint, orderId string) (*PaymentIntent, error) {
+
+	if math.Round(amount*100) <= 0 {
+		return nil, errors.New("invalid payment amount")
+	}
+
+	id := "fake_pi_" + orderId
+	intent := &PaymentIntent{
+		ID:           id,
+		ClientSecret: id + "_secret",
+		Amount:       amount,
+		Currency:     "usd",
+		Status:       IntentStatusPending,
+		OrderId:      orderId,
+	}
+
+	f.mu.Lock()
+	f.intents[id] = intent
+	f.mu.Unlock()
+
+	return f.snapshot(intent), nil
+}
+
+// GetPaymentStatus implements [PaymentClient].
+func (f *FakeClient) GetPaymentStatus(pId string) (*PaymentIntent, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[pId]
+	if !ok {
+		// intents do not survive a restart, treat unknown ids as already resolved
+		intent = &PaymentIntent{ID: pId, Currency: "usd", Status: f.outcome}
+		f.intents[pId] = intent
+	}
+	if intent.Status == IntentStatusPending {
+		intent.Status = f.outcome
+	}
+
+	return f.snapshot(intent), nil
+}
+
+// CancelPayment implements [PaymentClient].
+// Intents that succeeded or are processing are left as they are.
+func (f *FakeClient) CancelPayment(pId string) (*PaymentIntent, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[pId]
+	if !ok {
+		intent = &PaymentIntent{ID: pId, Currency: "usd", Status: IntentStatusPending}
+		f.intents[pId] = intent
+	}
+	if intent.Status != IntentStatusSucceeded && intent.Status != IntentStatusProcessing {
+		intent.Status = IntentStatusCanceled
+	}
+
+	return f.snapshot(intent), nil
+}
+
+// RefundPayment implements [PaymentClient].
+// Refunds succeed immediately unless they exceed the amount of a known intent, which fails them.
+func (f *FakeClient) RefundPayment(pId string, amount float64, reason string, idempotencyKey string) (*RefundResult, error) {
+
+	if math.Round(amount*100) <= 0 {
+		return nil, errors.New("invalid refund amount")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if result, ok := f.refundKeys[idempotencyKey]; ok && idempotencyKey != "" {
+		return result, nil
+	}
+
+	if intent, ok := f.intents[pId]; ok && intent.Amount > 0 {
+		decline := ""
+		if intent.Status != IntentStatusSucceeded {
+			decline = "payment was not captured"
+		} else if math.Round((f.refunds[pId]+amount)*100) > math.Round(intent.Amount*100) {
+			decline = "refund exceeds captured amount"
+		}
+		if decline != "" {
+			result := &RefundResult{Amount: amount, Status: RefundStatusFailed}
+			result.Raw, _ = json.Marshal(struct {
+				Provider string `json:"provider"`
+				Error    string `json:"error"`
+			}{"fake", decline})
+			return result, nil
+		}
+	}
+
+	f.refunds[pId] += amount
+	result := &RefundResult{
+		ID:     fmt.Sprintf("fake_re_%s_%d", pId, int64(math.Round(f.refunds[pId]*100))),
+		Amount: amount,
+		Status: RefundStatusSucceeded,
+	}
+	result.Raw, _ = json.Marshal(struct {
+		Provider string `json:"provider"`
+		Reason   string `json:"reason"`
+		RefundResult
+	}{"fake", reason, *result})
+
+	if idempotencyKey != "" {
+		f.refundKeys[idempotencyKey] = result
+	}
+	return result, nil
+}
+
+// SetStatus forces the status of an intent, e.g. to simulate a decline
+func (f *FakeClient) SetStatus(pId string, status IntentStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[pId]
+	if !ok {
+		return fmt.Errorf("unknown payment intent %s", pId)
+	}
+	intent.Status = status
+	return nil
+}
+
+// ParseWebhookEvent implements [PaymentClient].
+// Payloads use the Stripe event shape, {"id", "type", "data": {"object": {"id", "status"}}},
+// so the same fixtures can be used for both providers. The object is a payment intent or,
+// for refund events, a refund with its idempotency key in "metadata".
+func (f *FakeClient) ParseWebhookEvent(payload []byte, header func(key string) string) (*WebhookEvent, error) {
+
+	if f.webhookSecret == "" {
+		return nil, errors.New("webhook secret is not configured")
+	}
+
+	expected := f.Sign(payload)
+	if !hmac.Equal([]byte(expected), []byte(header(FakeSignatureHeader))) {
+		return nil, errors.New("invalid webhook signature")
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID       string            `json:"id"`
+				Status   string            `json:"status"`
+				Metadata map[string]string `json:"metadata"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.New("invalid webhook payload")
+	}
+
+	result := &WebhookEvent{
+		ID:      event.ID,
+		Type:    event.Type,
+		Payload: payload,
+	}
+
+	switch event.Type {
+	case WebhookPaymentSucceeded, WebhookPaymentFailed, WebhookPaymentCanceled:
+		result.PaymentId = event.Data.Object.ID
+		result.Status = IntentStatus(event.Data.Object.Status)
+		if event.Data.Object.Status == "requires_payment_method" {
+			result.Status = IntentStatusPending
+		}
+	case WebhookRefundUpdated, WebhookRefundFailed, WebhookChargeRefundUpdated:
+		result.RefundId = event.Data.Object.ID
+		result.RefundKey = event.Data.Object.Metadata["idempotency_key"]
+		result.RefundStatus = RefundStatus(event.Data.Object.Status)
+		if event.Data.Object.Status == "canceled" {
+			result.RefundStatus = RefundStatusFailed
+		}
+	}
+
+	return result, nil
+}
+
+// Sign returns the FakeSignatureHeader value for a webhook payload
+func (f *FakeClient) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.webhookSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *FakeClient) snapshot(intent *PaymentIntent) *PaymentIntent {
+	copied := *intent
+	copied.Raw, _ = json.Marshal(struct {
+		Provider string `json:"provider"`
+		PaymentIntent
+	}{"fake", *intent})
+	return &copied
+}
+
+func newFakeClient(cfg config.AppConfig) (PaymentClient, error) {
+	outcome := IntentStatus(cfg.FakePaymentOutcome)
+	switch outcome {
+	case "", IntentStatusSucceeded, IntentStatusFailed, IntentStatusCanceled, IntentStatusProcessing:
+	default:
+		return nil, fmt.Errorf("unsupported fake payment outcome %q", outcome)
+	}
+	// the webhook endpoint is public, a well known secret would let anyone confirm payments
+	if cfg.StripeWebhookSecret == "" {
+		return nil, errors.New("the fake payment provider needs STRIPE_WEBHOOK_SECRET to verify webhooks")
+	}
+	return NewFakeClient(outcome, cfg.StripeWebhookSecret), nil
+}
@@ -0,0 +1,154 @@
+package payment
+
+import (
+	"fmt"
+	"go-ecommerce-app/config"
+	"testing"
+)
+
+func fakeHeader(signature string) func(string) string {
+	return func(key string) string {
+		if key == FakeSignatureHeader {
+			return signature
+		}
+		return ""
+	}
+}
+
+func fakeEvent(eventType string, objectId string, status string) []byte {
+	return fmt.Appendf(nil, `{"id":"evt_fake","type":%q,"data":{"object":{"id":%q,"status":%q}}}`, eventType, objectId, status)
+}
+
+func TestFakeParseWebhookEventRejectsBadSignatures(t *testing.T) {
+	client := NewFakeClient(IntentStatusSucceeded, testWebhookSecret)
+	payload := fakeEvent(WebhookPaymentSucceeded, "fake_pi_ORD1", "succeeded")
+	other := NewFakeClient(IntentStatusSucceeded, "whsec_other")
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"missing", ""},
+		{"not hex", "not-a-signature"},
+		{"other secret", other.Sign(payload)},
+		{"other payload", client.Sign(fakeEvent(WebhookPaymentSucceeded, "fake_pi_ORD2", "succeeded"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ParseWebhookEvent(payload, fakeHeader(tt.signature)); err == nil {
+				t.Fatal("ParseWebhookEvent accepted the event")
+			}
+		})
+	}
+}
+
+func TestFakeParseWebhookEventWithoutSecret(t *testing.T) {
+	client := NewFakeClient(IntentStatusSucceeded, "")
+	payload := fakeEvent(WebhookPaymentSucceeded, "fake_pi_ORD1", "succeeded")
+
+	if _, err := client.ParseWebhookEvent(payload, fakeHeader(client.Sign(payload))); err == nil {
+		t.Fatal("ParseWebhookEvent accepted an event without a webhook secret")
+	}
+}
+
+func TestNewFakeClientRequiresWebhookSecret(t *testing.T) {
+	if _, err := NewPaymentClient(config.AppConfig{PaymentProvider: "fake"}); err == nil {
+		t.Fatal("fake provider was created without a webhook secret")
+	}
+	client, err := NewPaymentClient(config.AppConfig{PaymentProvider: "fake", StripeWebhookSecret: testWebhookSecret})
+	if err != nil {
+		t.Fatalf("NewPaymentClient: %v", err)
+	}
+	if _, ok := client.(*FakeClient); !ok {
+		t.Fatalf("NewPaymentClient returned %T", client)
+	}
+}
+
+func TestFakePaymentFlow(t *testing.T) {
+	client := NewFakeClient(IntentStatusSucceeded, testWebhookSecret)
+
+	intent, err := client.CreatePayment(25.99, 7, "ORD1")
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	if intent.Status != IntentStatusPending || intent.OrderId != "ORD1" || intent.Amount != 25.99 {
+		t.Fatalf("created intent = %+v", intent)
+	}
+
+	got, err := client.GetPaymentStatus(intent.ID)
+	if err != nil {
+		t.Fatalf("GetPaymentStatus: %v", err)
+	}
+	if got.Status != IntentStatusSucceeded {
+		t.Fatalf("status = %s, want %s", got.Status, IntentStatusSucceeded)
+	}
+
+	payload := fakeEvent(WebhookPaymentSucceeded, intent.ID, string(got.Status))
+	event, err := client.ParseWebhookEvent(payload, fakeHeader(client.Sign(payload)))
+	if err != nil {
+		t.Fatalf("ParseWebhookEvent: %v", err)
+	}
+	if event.Type != WebhookPaymentSucceeded || event.PaymentId != intent.ID || event.Status != IntentStatusSucceeded {
+		t.Fatalf("event = %+v", event)
+	}
+
+	// a succeeded intent can no longer be canceled
+	canceled, err := client.CancelPayment(intent.ID)
+	if err != nil {
+		t.Fatalf("CancelPayment: %v", err)
+	}
+	if canceled.Status != IntentStatusSucceeded {
+		t.Fatalf("status after cancel = %s, want %s", canceled.Status, IntentStatusSucceeded)
+	}
+
+	refund, err := client.RefundPayment(intent.ID, 10, "damaged", "refund_1")
+	if err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	if refund.Status != RefundStatusSucceeded {
+		t.Fatalf("refund status = %s, want %s", refund.Status, RefundStatusSucceeded)
+	}
+
+	// a retried request pays out once
+	retried, err := client.RefundPayment(intent.ID, 10, "damaged", "refund_1")
+	if err != nil {
+		t.Fatalf("RefundPayment retry: %v", err)
+	}
+	if retried.ID != refund.ID {
+		t.Fatalf("retry created refund %s, want %s", retried.ID, refund.ID)
+	}
+
+	declined, err := client.RefundPayment(intent.ID, 16, "damaged", "refund_2")
+	if err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	if declined.Status != RefundStatusFailed {
+		t.Fatal("RefundPayment refunded more than was captured")
+	}
+}
+
+func TestFakePaymentCanceledBeforePaying(t *testing.T) {
+	client := NewFakeClient(IntentStatusSucceeded, testWebhookSecret)
+
+	intent, err := client.CreatePayment(10, 7, "ORD2")
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	canceled, err := client.CancelPayment(intent.ID)
+	if err != nil {
+		t.Fatalf("CancelPayment: %v", err)
+	}
+	if canceled.Status != IntentStatusCanceled {
+		t.Fatalf("status after cancel = %s, want %s", canceled.Status, IntentStatusCanceled)
+	}
+
+	// the configured outcome only applies to intents still pending
+	got, err := client.GetPaymentStatus(intent.ID)
+	if err != nil {
+		t.Fatalf("GetPaymentStatus: %v", err)
+	}
+	if got.Status != IntentStatusCanceled {
+		t.Fatalf("status = %s, want %s", got.Status, IntentStatusCanceled)
+	}
+}
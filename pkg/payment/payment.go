@@ -0,0 +1,94 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ecommerce-app/config"
+	"strings"
+)
+
+// IntentStatus is the provider independent state of a payment intent
+type IntentStatus string
+
+const (
+	IntentStatusPending    IntentStatus = "pending" // waiting for the buyer
+	IntentStatusProcessing IntentStatus = "processing"
+	IntentStatusSucceeded  IntentStatus = "succeeded"
+	IntentStatusFailed     IntentStatus = "failed" // given up on, Stripe cancels instead
+	IntentStatusCanceled   IntentStatus = "canceled"
+)
+
+// Webhook event types, named after the Stripe events they originate from
+const (
+	WebhookPaymentSucceeded = "payment_intent.succeeded"
+	WebhookPaymentFailed    = "payment_intent.payment_failed" // one attempt was declined, the buyer may retry
+	WebhookPaymentCanceled  = "payment_intent.canceled"
+	// refund events settle refunds the provider first reported as pending
+	WebhookRefundUpdated       = "refund.updated"
+	WebhookRefundFailed        = "refund.failed"
+	WebhookChargeRefundUpdated = "charge.refund.updated"
+)
+
+type PaymentIntent struct {
+	ID           string          `json:"id"`
+	ClientSecret string          `json:"client_secret"`
+	Amount       float64         `json:"amount"`
+	Currency     string          `json:"currency"`
+	Status       IntentStatus    `json:"status"`
+	OrderId      string          `json:"order_id"`
+	Raw          json.RawMessage `json:"raw,omitempty"` // provider response, kept for payment logs
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+type RefundResult struct {
+	ID     string          `json:"id"`
+	Amount float64         `json:"amount"`
+	Status RefundStatus    `json:"status"`
+	Raw    json.RawMessage `json:"raw,omitempty"`
+}
+
+// WebhookEvent is the part of a provider event the app acts on. Payment intent events set
+// PaymentId and Status, refund events RefundId, RefundKey and RefundStatus.
+type WebhookEvent struct {
+	ID           string
+	Type         string
+	PaymentId    string
+	Status       IntentStatus
+	RefundId     string
+	RefundKey    string // idempotency key the refund was created with, empty when made elsewhere
+	RefundStatus RefundStatus
+	Payload      []byte
+}
+
+type PaymentClient interface {
+	CreatePayment(amount float64, userId uint, orderId string) (*PaymentIntent, error)
+	GetPaymentStatus(pId string) (*PaymentIntent, error)
+	// CancelPayment stops an intent from being paid. An intent that can no longer be
+	// canceled, e.g. because it succeeded meanwhile, is returned with its current status.
+	CancelPayment(pId string) (*PaymentIntent, error)
+	// RefundPayment returns amount (in the payment currency) of a captured payment to the buyer.
+	// Calls with the same idempotencyKey create a single refund. A refund the provider rejects
+	// is returned as failed; an error means its outcome is unknown and the call may be retried.
+	RefundPayment(pId string, amount float64, reason string, idempotencyKey string) (*RefundResult, error)
+	// ParseWebhookEvent authenticates a webhook delivery; header returns request header values
+	ParseWebhookEvent(payload []byte, header func(key string) string) (*WebhookEvent, error)
+}
+
+// NewPaymentClient returns the provider configured in cfg.PaymentProvider, stripe by default
+func NewPaymentClient(cfg config.AppConfig) (PaymentClient, error) {
+	switch name := strings.ToLower(cfg.PaymentProvider); name {
+	case "", "stripe":
+		return newStripeClient(cfg)
+	case "fake":
+		return newFakeClient(cfg)
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q, available: fake, stripe", name)
+	}
+}
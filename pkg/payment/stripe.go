@@ -1,22 +1,23 @@
 package payment
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"go-ecommerce-app/config"
 	"log"
 	"math"
+	"net/http"
 
 	"github.com/stripe/stripe-go/v78"
 	"github.com/stripe/stripe-go/v78/paymentintent"
+	"github.com/stripe/stripe-go/v78/refund"
+	"github.com/stripe/stripe-go/v78/webhook"
 )
 
-type PaymentClient interface {
-	CreatePayment(amount float64, userId uint, orderId string) (*stripe.PaymentIntent, error)
-	GetPaymentStatus(pId string) (*stripe.PaymentIntent, error)
-}
-
 type payment struct {
 	stripeSecretKey string
+	webhookSecret   string
 }
 
 // CreatePayment implements [PaymentClient].
@@ -24,7 +25,7 @@ func (p *payment) CreatePayment(
 	amount float64,
 	userId uint,
 	orderId string,
-) (*stripe.PaymentIntent, error) {
+) (*PaymentIntent, error) {
 
 	stripe.Key = p.stripeSecretKey
 
@@ -48,11 +49,11 @@ func (p *payment) CreatePayment(
 		return nil, errors.New("payment intent creation failed")
 	}
 
-	return pi, nil
+	return toPaymentIntent(pi), nil
 }
 
 // GetPaymentStatus implements [PaymentClient].
-func (p *payment) GetPaymentStatus(pId string) (*stripe.PaymentIntent, error) {
+func (p *payment) GetPaymentStatus(pId string) (*PaymentIntent, error) {
 
 	stripe.Key = p.stripeSecretKey
 
@@ -62,33 +63,174 @@ func (p *payment) GetPaymentStatus(pId string) (*stripe.PaymentIntent, error) {
 		return nil, errors.New("get payment intent failed")
 	}
 
+	return toPaymentIntent(result), nil
+}
+
+// CancelPayment implements [PaymentClient].
+func (p *payment) CancelPayment(pId string) (*PaymentIntent, error) {
+
+	stripe.Key = p.stripeSecretKey
+
+	pi, err := paymentintent.Cancel(pId, nil)
+	if err != nil {
+		// succeeded, processing and canceled intents cannot be canceled, report where it stands
+		log.Printf("stripe cancel error: %v", err)
+		current, getErr := p.GetPaymentStatus(pId)
+		if getErr != nil {
+			return nil, errors.New("cancel payment intent failed")
+		}
+		return current, nil
+	}
+
+	return toPaymentIntent(pi), nil
+}
+
+// RefundPayment implements [PaymentClient].
+func (p *payment) RefundPayment(pId string, amount float64, reason string, idempotencyKey string) (*RefundResult, error) {
+
+	stripe.Key = p.stripeSecretKey
+
+	amountInCents := int64(math.Round(amount * 100))
+	if amountInCents <= 0 {
+		return nil, errors.New("invalid refund amount")
+	}
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(pId),
+		Amount:        stripe.Int64(amountInCents),
+	}
+	params.AddMetadata("reason", reason)
+	params.AddMetadata("idempotency_key", idempotencyKey)
+	params.SetIdempotencyKey(idempotencyKey)
+
+	re, err := refund.New(params)
+	if err != nil {
+		log.Printf("stripe refund error: %v", err)
+		if stripeErr, ok := refundRejected(err); ok {
+			raw, _ := json.Marshal(stripeErr)
+			return &RefundResult{Amount: amount, Status: RefundStatusFailed, Raw: raw}, nil
+		}
+		return nil, errors.New("refund creation failed")
+	}
+
+	raw, _ := json.Marshal(re)
+	return &RefundResult{
+		ID:     re.ID,
+		Amount: float64(re.Amount) / 100,
+		Status: toRefundStatus(re),
+		Raw:    raw,
+	}, nil
+}
+
+// ParseWebhookEvent implements [PaymentClient].
+// It verifies the Stripe-Signature header against the endpoint secret and does not call the Stripe API.
+func (p *payment) ParseWebhookEvent(payload []byte, header func(key string) string) (*WebhookEvent, error) {
+
+	if p.webhookSecret == "" {
+		return nil, errors.New("webhook secret is not configured")
+	}
+
+	event, err := webhook.ConstructEventWithOptions(payload, header("Stripe-Signature"), p.webhookSecret, webhook.ConstructEventOptions{
+		// the endpoint may be pinned to a different API version, we only read stable fields
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		log.Printf("webhook signature error: %v", err)
+		return nil, errors.New("invalid webhook signature")
+	}
+
+	result := &WebhookEvent{
+		ID:      event.ID,
+		Type:    string(event.Type),
+		Payload: payload,
+	}
+
+	switch result.Type {
+	case WebhookPaymentSucceeded, WebhookPaymentFailed, WebhookPaymentCanceled:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			log.Printf("webhook payload error: %v", err)
+			return nil, errors.New("invalid webhook payload")
+		}
+		result.PaymentId = pi.ID
+		result.Status = toIntentStatus(&pi)
+	case WebhookRefundUpdated, WebhookRefundFailed, WebhookChargeRefundUpdated:
+		var re stripe.Refund
+		if err := json.Unmarshal(event.Data.Raw, &re); err != nil {
+			log.Printf("webhook payload error: %v", err)
+			return nil, errors.New("invalid webhook payload")
+		}
+		result.RefundId = re.ID
+		result.RefundKey = re.Metadata["idempotency_key"]
+		result.RefundStatus = toRefundStatus(&re)
+	}
+
 	return result, nil
 }
 
-func NewPaymentClient(stripeSecretKey string) PaymentClient {
-	return &payment{
-		stripeSecretKey: stripeSecretKey,
+func toPaymentIntent(pi *stripe.PaymentIntent) *PaymentIntent {
+	raw, _ := json.Marshal(pi)
+	intent := &PaymentIntent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Amount:       float64(pi.Amount) / 100,
+		Currency:     string(pi.Currency),
+		Status:       toIntentStatus(pi),
+		Raw:          raw,
+	}
+	if pi.Metadata != nil {
+		intent.OrderId = pi.Metadata["order_id"]
 	}
+	return intent
 }
 
-// params := &stripe.CheckoutSessionParams{
-// 	PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
-
-// 	PaymentMethodOptions: &stripe.CheckoutSessionPaymentMethodOptionsParams{
-// 		Card: &stripe.CheckoutSessionPaymentMethodOptionsCardParams{
-// 			SetupFutureUsage: stripe.String("none"), // 👈 disables Link
-// 		},
-// 	},
-
-// 	LineItems: []*stripe.CheckoutSessionLineItemParams{
-// 		{
-// 			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-// 				UnitAmount: stripe.Int64(amountInCents),
-// 				Currency:   stripe.String("usd"),
-// 				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-// 					Name: stripe.String("Order Payment"),
-// 				},
-// 			},
-// 			Quantity: stripe.Int64(1),
-// 		},
-// 	},
+func toIntentStatus(pi *stripe.PaymentIntent) IntentStatus {
+	switch pi.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		return IntentStatusSucceeded
+	case stripe.PaymentIntentStatusCanceled:
+		return IntentStatusCanceled
+	case stripe.PaymentIntentStatusProcessing:
+		return IntentStatusProcessing
+	}
+	// a declined attempt sends the intent back to requires_payment_method, the buyer may
+	// still pay it with another card so it stays pending until it is canceled
+	return IntentStatusPending
+}
+
+// refundRejected reports whether Stripe answered a refund request and turned it down. Network
+// errors, rate limits, idempotency conflicts and server errors leave the outcome unknown.
+func refundRejected(err error) (*stripe.Error, bool) {
+	var stripeErr *stripe.Error
+	if !errors.As(err, &stripeErr) {
+		return nil, false
+	}
+	switch code := stripeErr.HTTPStatusCode; {
+	case code == http.StatusConflict, code == http.StatusTooManyRequests:
+		return nil, false
+	case code >= 400 && code < 500:
+		return stripeErr, true
+	}
+	return nil, false
+}
+
+// toRefundStatus maps pending and requires_action to pending, they settle later
+func toRefundStatus(re *stripe.Refund) RefundStatus {
+	switch re.Status {
+	case stripe.RefundStatusSucceeded:
+		return RefundStatusSucceeded
+	case stripe.RefundStatusFailed, stripe.RefundStatusCanceled:
+		return RefundStatusFailed
+	}
+	return RefundStatusPending
+}
+
+func newStripeClient(cfg config.AppConfig) (PaymentClient, error) {
+	if cfg.StripeSecret == "" {
+		log.Println("stripe secret is not configured, payment calls will fail")
+	}
+	return &payment{
+		stripeSecretKey: cfg.StripeSecret,
+		webhookSecret:   cfg.StripeWebhookSecret,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package payment
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/webhook"
+)
+
+const testWebhookSecret = "whsec_test_secret"
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	payload, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s: %v", name, err)
+	}
+	return payload
+}
+
+// signedHeader returns header values as Stripe sends them for payload
+func signedHeader(payload []byte, secret string, at time.Time) func(string) string {
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    secret,
+		Timestamp: at,
+	})
+	return func(key string) string {
+		if key == "Stripe-Signature" {
+			return signed.Header
+		}
+		return ""
+	}
+}
+
+func TestStripeParseWebhookEvent(t *testing.T) {
+	client := &payment{webhookSecret: testWebhookSecret}
+
+	tests := []struct {
+		fixture string
+		want    WebhookEvent
+	}{
+		{
+			fixture: "payment_intent.succeeded.json",
+			want:    WebhookEvent{ID: "evt_3PqSucceeded", Type: WebhookPaymentSucceeded, PaymentId: "pi_3PqSucceeded", Status: IntentStatusSucceeded},
+		},
+		{
+			fixture: "payment_intent.payment_failed.json",
+			want:    WebhookEvent{ID: "evt_3PqDeclined", Type: WebhookPaymentFailed, PaymentId: "pi_3PqDeclined", Status: IntentStatusPending},
+		},
+		{
+			fixture: "refund.updated.json",
+			want:    WebhookEvent{ID: "evt_3PqRefund", Type: WebhookRefundUpdated, RefundId: "re_3PqRefund", RefundKey: "refund_42", RefundStatus: RefundStatusSucceeded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			payload := readFixture(t, tt.fixture)
+
+			event, err := client.ParseWebhookEvent(payload, signedHeader(payload, testWebhookSecret, time.Now()))
+			if err != nil {
+				t.Fatalf("ParseWebhookEvent: %v", err)
+			}
+			if event.ID != tt.want.ID || event.Type != tt.want.Type {
+				t.Errorf("event = %s %s, want %s %s", event.ID, event.Type, tt.want.ID, tt.want.Type)
+			}
+			if event.PaymentId != tt.want.PaymentId || event.Status != tt.want.Status {
+				t.Errorf("payment = %q %q, want %q %q", event.PaymentId, event.Status, tt.want.PaymentId, tt.want.Status)
+			}
+			if event.RefundId != tt.want.RefundId || event.RefundKey != tt.want.RefundKey || event.RefundStatus != tt.want.RefundStatus {
+				t.Errorf("refund = %q %q %q, want %q %q %q", event.RefundId, event.RefundKey, event.RefundStatus, tt.want.RefundId, tt.want.RefundKey, tt.want.RefundStatus)
+			}
+		})
+	}
+}
+
+func TestStripeParseWebhookEventRejectsBadSignatures(t *testing.T) {
+	payload := readFixture(t, "payment_intent.succeeded.json")
+
+	tests := []struct {
+		name   string
+		client *payment
+		header func(string) string
+	}{
+		{"wrong secret", &payment{webhookSecret: testWebhookSecret}, signedHeader(payload, "whsec_other", time.Now())},
+		{"expired", &payment{webhookSecret: testWebhookSecret}, signedHeader(payload, testWebhookSecret, time.Now().Add(-time.Hour))},
+		{"missing", &payment{webhookSecret: testWebhookSecret}, func(string) string { return "" }},
+		{"no secret configured", &payment{}, signedHeader(payload, "", time.Now())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.client.ParseWebhookEvent(payload, tt.header); err == nil {
+				t.Fatal("ParseWebhookEvent accepted the event")
+			}
+		})
+	}
+}
+
+func TestStripeParseWebhookEventRejectsTamperedPayload(t *testing.T) {
+	client := &payment{webhookSecret: testWebhookSecret}
+	payload := readFixture(t, "payment_intent.succeeded.json")
+	header := signedHeader(payload, testWebhookSecret, time.Now())
+
+	tampered := append([]byte{}, payload...)
+	tampered[len(tampered)-2] = ' '
+	if _, err := client.ParseWebhookEvent(tampered, header); err == nil {
+		t.Fatal("ParseWebhookEvent accepted a payload that does not match its signature")
+	}
+}
+
+func TestRefundRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		rejected bool
+	}{
+		{"already refunded", &stripe.Error{HTTPStatusCode: 400, Code: stripe.ErrorCodeChargeAlreadyRefunded}, true},
+		{"card error", &stripe.Error{HTTPStatusCode: 402}, true},
+		{"idempotency conflict", &stripe.Error{HTTPStatusCode: 409}, false},
+		{"rate limited", &stripe.Error{HTTPStatusCode: 429}, false},
+		{"server error", &stripe.Error{HTTPStatusCode: 500}, false},
+		{"network", errors.New("dial tcp: i/o timeout"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, rejected := refundRejected(tt.err); rejected != tt.rejected {
+				t.Errorf("refundRejected = %v, want %v", rejected, tt.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"go-ecommerce-app/config"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage keeps files on disk under Dir, the server exposes them under BaseUrl
+type LocalStorage struct {
+	Dir     string
+	BaseUrl string
+}
+
+func NewLocalStorage(dir string, baseUrl string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Dir: dir, BaseUrl: baseUrl}, nil
+}
+
+func newLocalStorage(cfg config.AppConfig) (Storage, error) {
+	return NewLocalStorage(cfg.StorageLocalDir, cfg.StoragePublicUrl)
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+
+	// write next to the target and rename, readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+
+	return publicUrl(s.BaseUrl, key), nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(s.Dir, filepath.FromSlash(key)))
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
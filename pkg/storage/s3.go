@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Storage talks to any S3 compatible object store (AWS, MinIO, R2...) with path style
+// requests signed with AWS signature v4
+type S3Storage struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicUrl string // base url objects are served from, defaults to endpoint/bucket
+	client    *http.Client
+}
+
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey, publicUrl string) (*S3Storage, error) {
+	if endpoint == "" || bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, errors.New("s3 storage needs an endpoint, bucket and credentials")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+	if publicUrl == "" {
+		publicUrl = endpoint + "/" + bucket
+	}
+	return &S3Storage{
+		Endpoint:  endpoint,
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PublicUrl: publicUrl,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func newS3Storage(cfg config.AppConfig) (Storage, error) {
+	return NewS3Storage(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PublicUrl)
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	// the payload hash is part of the signature, uploads are small enough to buffer
+	payload, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(key), bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if err := s.do(req, payload); err != nil {
+		return "", err
+	}
+
+	return publicUrl(s.PublicUrl, key), nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectUrl(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.send(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectUrl(key), nil)
+	if err != nil {
+		return err
+	}
+	// deleting a missing object succeeds on S3
+	return s.do(req, nil)
+}
+
+func (s *S3Storage) objectUrl(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return s.Endpoint + "/" + url.PathEscape(s.Bucket) + "/" + strings.Join(segments, "/")
+}
+
+func (s *S3Storage) do(req *http.Request, payload []byte) error {
+	resp, err := s.send(req, payload)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// send signs and performs req, non 2xx responses are turned into errors
+func (s *S3Storage) send(req *http.Request, payload []byte) (*http.Response, error) {
+	s.sign(req, payload, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s failed with %d: %s", req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// unsignedHeaders are left out of the signature, proxies and the http client may change them
+var unsignedHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"transfer-encoding": true,
+	"content-length":    true,
+}
+
+// sign adds an AWS signature v4 Authorization header to req. The host and every header set on
+// req are signed.
+func (s *S3Storage) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headerValues := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if unsignedHeaders[name] {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headerValues[name] = strings.Join(trimmed, ",")
+	}
+	signedHeaders := make([]string, 0, len(headerValues))
+	for name := range headerValues {
+		signedHeaders = append(signedHeaders, name)
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		canonicalHeaders.WriteString(h + ":" + headerValues[h] + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// canonicalPath encodes every path segment once, as S3 expects
+func canonicalPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the query parameters by name then value, parameters without a value
+// are signed with an empty one
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return uriEncode(names[i]) < uriEncode(names[j]) })
+
+	params := make([]string, 0, len(query))
+	for _, name := range names {
+		values := make([]string, 0, len(query[name]))
+		for _, v := range query[name] {
+			values = append(values, uriEncode(v))
+		}
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(name)+"="+v)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent encodes everything but the unreserved characters of RFC 3986
+func uriEncode(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
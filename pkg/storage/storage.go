@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-ecommerce-app/config"
+	"io"
+	"path"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage keeps uploaded files and tells where they can be downloaded from
+type Storage interface {
+	// Put stores body under key and returns its public url
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStorage returns the backend configured in cfg.StorageProvider, local by default
+func NewStorage(cfg config.AppConfig) (Storage, error) {
+	switch name := strings.ToLower(cfg.StorageProvider); name {
+	case "", "local":
+		return newLocalStorage(cfg)
+	case "s3":
+		return newS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage provider %q, available: local, s3", name)
+	}
+}
+
+// cleanKey rejects keys that could escape the storage root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// publicUrl joins a base url and a key
+func publicUrl(base string, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}